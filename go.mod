@@ -11,8 +11,10 @@ require (
 	github.com/resend/resend-go/v2 v2.18.0
 	github.com/samborkent/uuidv7 v0.0.0-20231110121620-f2e19d87e48b
 	github.com/stretchr/testify v1.10.0
-	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da
+	golang.org/x/sync v0.13.0
+	golang.org/x/time v0.11.0
 	google.golang.org/api v0.230.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -85,14 +87,11 @@ require (
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/oauth2 v0.29.0 // indirect
-	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
-	golang.org/x/time v0.11.0 // indirect
 	google.golang.org/genproto v0.0.0-20250428153025-10db94c68c34 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250428153025-10db94c68c34 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250428153025-10db94c68c34 // indirect
 	google.golang.org/grpc v1.72.0
 	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
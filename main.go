@@ -3,8 +3,6 @@ package main
 import (
 	"context"
 	"log"
-	"os"
-	"strings"
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
@@ -14,10 +12,14 @@ import (
 
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
 	resend "github.com/nvbf/tournament-sync/repos/resend"
+	storage "github.com/nvbf/tournament-sync/repos/storage"
 
+	access "github.com/nvbf/tournament-sync/pkg/accessCode"
 	auth "github.com/nvbf/tournament-sync/pkg/auth"
+	config "github.com/nvbf/tournament-sync/pkg/config"
 
 	admin "github.com/nvbf/tournament-sync/services/admin"
+	custom "github.com/nvbf/tournament-sync/services/custom"
 	matches "github.com/nvbf/tournament-sync/services/matches"
 	stats "github.com/nvbf/tournament-sync/services/stats"
 	sync "github.com/nvbf/tournament-sync/services/sync"
@@ -26,17 +28,19 @@ import (
 func main() {
 	ctx := context.Background()
 
-	projectID := os.Getenv("FIRESTORE_PROJECT_ID")
-	credentialsJSON := os.Getenv("FIRESTORE_CREDENTIAL_JSON")
-	profixioHost := os.Getenv("PROFIXIO_HOST")
-	port := os.Getenv("PORT")
-	firestoreDb := os.Getenv("FIRESTORE_DATABASE_ID")
-	allowOrigins := os.Getenv("CORS_HOSTS")
-	hostURL := os.Getenv("HOST_URL")
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	profixioSources, err := profixio.ParseSources(cfg.Profixio.Sources)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	credentialsOption := option.WithCredentialsJSON([]byte(credentialsJSON))
+	credentialsOption := option.WithCredentialsJSON([]byte(cfg.Firestore.CredentialsJSON))
 
-	firestoreClient, err := firestore.NewClientWithDatabase(ctx, projectID, firestoreDb, credentialsOption)
+	firestoreClient, err := firestore.NewClientWithDatabase(ctx, cfg.Firestore.ProjectID, cfg.Firestore.DatabaseID, credentialsOption)
 	if err != nil {
 		log.Fatalf("Failed to create Firestore client: %v", err)
 	}
@@ -47,31 +51,59 @@ func main() {
 		log.Fatalf("error initializing app: %v\n", err)
 	}
 
-	profixioService := profixio.NewService(firestoreClient, profixioHost)
-	resendService := resend.NewService(firestoreClient, hostURL)
+	verifier, err := auth.NewVerifier(ctx, firebaseApp, auth.WithRevocationCheck(cfg.Auth.CheckRevoked))
+	if err != nil {
+		log.Fatalf("error initializing Firebase Auth: %v\n", err)
+	}
 
-	adminService := admin.NewAdminService(firestoreClient, firebaseApp, resendService)
-	syncService := sync.NewSyncService(firestoreClient, firebaseApp, profixioService)
-	matchesService := matches.NewMatchesService(firestoreClient, firebaseApp, profixioService)
-	statsService := stats.NewStatsService(firestoreClient, firebaseApp)
+	store := storage.NewFirestore(firestoreClient)
 
-	config := cors.DefaultConfig()
-	config.AllowOrigins = strings.Split(allowOrigins, ",")
-	config.AllowCredentials = true
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Access-Control-Allow-Origin"}
+	profixioService := profixio.NewService(store, cfg.Profixio.Host,
+		profixio.WithAPIKey(cfg.Profixio.APIKey),
+		profixio.WithSources(profixioSources...),
+	)
+	resendService := resend.NewService(cfg.Resend.APIKey, cfg.HostURL)
+
+	accessCodes := access.NewSigner([]byte(cfg.AccessCode.SigningKey), cfg.HostURL)
+	permissions := auth.NewPermissions(store)
+	adminService := admin.NewAdminService(store, permissions, firebaseApp, resendService, accessCodes, cfg.AccessCode.TTL)
+	syncService := sync.NewSyncService(store, firebaseApp, profixioService)
+	matchesService := matches.NewMatchesService(store, firebaseApp, profixioService)
+	statsService := stats.NewStatsService(store, firebaseApp)
+	customService := custom.NewCustomService(store, permissions, profixioService)
+
+	if cfg.Scheduler.Enabled {
+		go sync.NewScheduler(syncService, sync.DefaultSchedulerOptions()).Run(ctx)
+	}
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.CORSHosts
+	corsConfig.AllowCredentials = true
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Access-Control-Allow-Origin"}
 
 	router := gin.Default()
 	router.Use(corsMiddleware())
 
 	adminRouter := router.Group("/admin/v1")
-	adminRouter.Use(auth.AuthMiddleware(firebaseApp)) // Apply the middleware here
+	adminRouter.Use(verifier.Middleware()) // Apply the middleware here
 
 	matchesRouter := router.Group("/match/v1")
-	matchesRouter.Use(auth.AuthMiddleware(firebaseApp)) // Apply the middleware here
+	matchesRouter.Use(verifier.Middleware()) // Apply the middleware here
+
+	customRouter := router.Group("/custom/v1")
+	customRouter.Use(verifier.Middleware())
+
+	serviceAuth := auth.ServiceAuthMiddleware(verifier, auth.ServiceOptions{
+		Audience:        cfg.ServiceAuth.Audience,
+		ServiceAccounts: cfg.ServiceAuth.ServiceAccounts,
+		Tokens:          cfg.ServiceAuth.Tokens,
+	})
 
 	syncRouter := router.Group("/sync/v1")
+	syncRouter.Use(serviceAuth)
 
 	statsRouter := router.Group("/stats/v1")
+	statsRouter.Use(serviceAuth)
 
 	admin.NewHTTPHandler(admin.HTTPOptions{
 		Service: adminService,
@@ -81,19 +113,34 @@ func main() {
 	matches.NewHTTPHandler(matches.HTTPOptions{
 		Service: matchesService,
 		Router:  matchesRouter,
+		Guard:   permissions.Guard(matchesService.Scope),
+	})
+
+	custom.NewHTTPHandler(custom.HTTPOptions{
+		Service: customService,
+		Router:  customRouter,
 	})
 
 	sync.NewHTTPHandler(sync.HTTPOptions{
 		Service: syncService,
 		Router:  syncRouter,
+		Guard:   permissions.Guard(auth.SlugParam("slug_id")),
 	})
 
 	stats.NewHTTPHandler(stats.HTTPOptions{
 		Service: statsService,
 		Router:  statsRouter,
+		Guard:   permissions.Guard(auth.SlugParam("slug_id")),
 	})
+	if cfg.Stats.Public {
+		stats.NewHTTPHandler(stats.HTTPOptions{
+			Service: statsService,
+			Router:  router.Group("/public/stats/v1"),
+			Public:  true,
+		})
+	}
 
-	log.Fatal(router.Run(":" + port))
+	log.Fatal(router.Run(":" + cfg.Port))
 }
 
 func corsMiddleware() gin.HandlerFunc {
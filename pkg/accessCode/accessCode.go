@@ -1,35 +1,109 @@
+// Package accessCode issues and verifies the codes mailed to organisers to
+// get access to a tournament. A code is an HMAC-signed token naming the
+// tournament, the intended email and an expiry, so it reveals no tournament
+// secret and cannot be forged or extended.
 package accessCode
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+)
 
-	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+var (
+	// ErrInvalidCode is returned for codes that are malformed, not signed
+	// with the key or issued by someone else.
+	ErrInvalidCode = errors.New("invalid access code")
+	// ErrExpiredCode is returned for codes past their expiry.
+	ErrExpiredCode = errors.New("access code expired")
 )
 
-func GenerateCode(slug, secret string) string {
-	code := fmt.Sprintf("%s|%s", slug, secret)
+// Claims are the signed contents of an access code.
+type Claims struct {
+	Issuer    string    `json:"iss"`
+	Slug      string    `json:"slug"`
+	Email     string    `json:"email"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+	// Nonce identifies the code, so it can be used once.
+	Nonce string `json:"nonce"`
+}
 
-	// Encoding the string
-	encodedString := base64.StdEncoding.EncodeToString([]byte(code))
-	log.Printf("Encoded: %s", encodedString)
+// Signer issues and verifies access codes with a secret key.
+type Signer struct {
+	key    []byte
+	issuer string
+	now    func() time.Time
+}
+
+// NewSigner creates a Signer for codes issued by issuer, such as the host URL.
+func NewSigner(key []byte, issuer string) *Signer {
+	return &Signer{key: key, issuer: issuer, now: time.Now}
+}
+
+// Issue creates a code for email to access the tournament for ttl.
+func (s *Signer) Issue(slug, email string, ttl time.Duration) (string, Claims, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", Claims{}, err
+	}
+
+	now := s.now().UTC().Truncate(time.Second)
+	claims := Claims{
+		Issuer:    s.issuer,
+		Slug:      slug,
+		Email:     email,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+		Nonce:     hex.EncodeToString(nonce),
+	}
 
-	return encodedString
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), claims, nil
 }
 
-func Decode(code string) (slug, uniqueID string, err error) {
-	// Decoding the string
-	decodedBytes, err := base64.StdEncoding.DecodeString(code)
+// Verify checks the signature, issuer and expiry of a code and returns its
+// claims.
+func (s *Signer) Verify(code string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(code, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCode)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(encoded)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidCode)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
 	if err != nil {
-		log.Printf("Error decoding: %v", err)
-		return "", "", err
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCode)
 	}
-	decodedString := string(decodedBytes)
-	log.Printf("Decoded: %s", decodedString)
-	res := strings.Split(decodedString, "|")
-	if len(res) != 2 {
-		return "", "", fmt.Errorf("not correct format")
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCode)
 	}
-	return res[0], res[1], nil
+	if claims.Issuer != s.issuer || claims.Slug == "" || claims.Nonce == "" {
+		return nil, fmt.Errorf("%w: wrong issuer or missing claims", ErrInvalidCode)
+	}
+	if !s.now().Before(claims.ExpiresAt) {
+		return nil, fmt.Errorf("%w at %s", ErrExpiredCode, claims.ExpiresAt.Format(time.RFC3339))
+	}
+	return &claims, nil
+}
+
+func (s *Signer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
 }
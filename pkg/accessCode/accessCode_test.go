@@ -1,35 +1,67 @@
 package accessCode
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGenerateCode(t *testing.T) {
-	slug := "exampleSlug"
-	uuid := "stringsy"
-	encodedCode := GenerateCode(slug, uuid)
-	assert.NotEmpty(t, encodedCode, "Encoded code should not be empty")
-}
+var key = []byte("0123456789abcdef0123456789abcdef")
 
-func TestDecode(t *testing.T) {
-	// First, generate a code
-	slug := "testSlug"
-	uuid := "stringsy"
-	encodedCode := GenerateCode(slug, uuid)
+func TestIssueAndVerify(t *testing.T) {
+	signer := NewSigner(key, "https://scoreboard.example")
+	code, issued, err := signer.Issue("testSlug", "organiser@example.com", time.Hour)
+	assert.Nil(t, err, "Should not have an error during issuing")
+	assert.NotEmpty(t, code, "Code should not be empty")
+	assert.NotContains(t, code, "/", "Code should be safe in a URL path")
 
-	// Now, decode the encoded code
-	decodedSlug, decodedUUID, err := Decode(encodedCode)
+	claims, err := signer.Verify(code)
+	assert.Nil(t, err, "Should not have an error during verifying")
+	assert.Equal(t, issued, *claims, "Verified claims should match the issued claims")
+	assert.Equal(t, "testSlug", claims.Slug)
+	assert.Equal(t, "organiser@example.com", claims.Email)
+	assert.Len(t, claims.Nonce, 32)
+}
 
-	// Check if there are any errors
-	assert.Nil(t, err, "Should not have an error during decoding")
-	assert.Equal(t, slug, decodedSlug, "Decoded slug should match the original")
-	assert.Equal(t, uuid, decodedUUID, "Decoded UUID should match the original")
+func TestIssueUsesNewNonces(t *testing.T) {
+	signer := NewSigner(key, "https://scoreboard.example")
+	_, first, _ := signer.Issue("testSlug", "organiser@example.com", time.Hour)
+	_, second, _ := signer.Issue("testSlug", "organiser@example.com", time.Hour)
+	assert.NotEqual(t, first.Nonce, second.Nonce, "Each code should have its own nonce")
 }
 
-func TestDecode_ErrorHandling(t *testing.T) {
-	// Pass an incorrectly formatted string
-	_, _, err := Decode("this is not a base64 string")
-	assert.NotNil(t, err, "Expected an error for incorrect base64 string")
+func TestVerify_Errors(t *testing.T) {
+	signer := NewSigner(key, "https://scoreboard.example")
+	code, _, err := signer.Issue("testSlug", "organiser@example.com", time.Hour)
+	assert.Nil(t, err)
+	payload, signature, _ := strings.Cut(code, ".")
+	other, _, _ := signer.Issue("otherSlug", "organiser@example.com", time.Hour)
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	expired := NewSigner(key, "https://scoreboard.example")
+	expired.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	cases := []struct {
+		name     string
+		signer   *Signer
+		code     string
+		expected error
+	}{
+		{name: "not a code", signer: signer, code: "this is not a code", expected: ErrInvalidCode},
+		{name: "old base64 code", signer: signer, code: "dGVzdFNsdWd8c3RyaW5nc3k=", expected: ErrInvalidCode},
+		{name: "swapped payload", signer: signer, code: otherPayload + "." + signature, expected: ErrInvalidCode},
+		{name: "other key", signer: NewSigner([]byte("another key"), "https://scoreboard.example"), code: code, expected: ErrInvalidCode},
+		{name: "other issuer", signer: NewSigner(key, "https://other.example"), code: code, expected: ErrInvalidCode},
+		{name: "expired", signer: expired, code: code, expected: ErrExpiredCode},
+		{name: "valid", signer: signer, code: payload + "." + signature},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := c.signer.Verify(c.code)
+			assert.ErrorIs(t, err, c.expected)
+		})
+	}
 }
@@ -3,40 +3,108 @@ package auth
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	firebase "firebase.google.com/go/v4"
-
+	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(firebaseApp *firebase.App) gin.HandlerFunc {
+// TokenVerifier verifies Firebase ID tokens. *auth.Client implements it.
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+	VerifyIDTokenAndCheckRevoked(ctx context.Context, idToken string) (*auth.Token, error)
+}
+
+// Verifier authenticates requests by their Firebase ID token, with one auth
+// client shared by every request.
+type Verifier struct {
+	client       TokenVerifier
+	checkRevoked bool
+}
+
+// VerifierOption configures a Verifier.
+type VerifierOption func(*Verifier)
+
+// WithRevocationCheck also rejects tokens that were revoked or belong to
+// disabled users. It costs a lookup of the user on every request.
+func WithRevocationCheck(enabled bool) VerifierOption {
+	return func(v *Verifier) {
+		v.checkRevoked = enabled
+	}
+}
+
+// NewVerifier creates the Firebase auth client once and returns a Verifier
+// using it.
+func NewVerifier(ctx context.Context, firebaseApp *firebase.App, opts ...VerifierOption) (*Verifier, error) {
+	client, err := firebaseApp.Auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newVerifier(client, opts...), nil
+}
+
+func newVerifier(client TokenVerifier, opts ...VerifierOption) *Verifier {
+	v := &Verifier{client: client}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Middleware aborts requests without a valid Firebase ID token and attaches
+// the Principal of the user to the others.
+func (v *Verifier) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
-			return
-		}
-		idToken := authHeader[len("Bearer "):]
-
-		ctx := context.Background()
-		authClient, err := firebaseApp.Auth(ctx)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to initialize Firebase Auth"})
-			c.Abort()
-			return
+		if v.verify(c) {
+			c.Next()
 		}
+	}
+}
 
-		token, err := authClient.VerifyIDToken(ctx, idToken)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid ID token"})
-			c.Abort()
-			return
-		}
+// verify attaches the principal of the request's ID token to the context.
+// It aborts the request and returns false if the token is missing or invalid.
+func (v *Verifier) verify(c *gin.Context) bool {
+	idToken, ok := bearerToken(c.GetHeader("Authorization"))
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be a Bearer token"})
+		c.Abort()
+		return false
+	}
+
+	var token *auth.Token
+	var err error
+	if v.checkRevoked {
+		token, err = v.client.VerifyIDTokenAndCheckRevoked(c.Request.Context(), idToken)
+	} else {
+		token, err = v.client.VerifyIDToken(c.Request.Context(), idToken)
+	}
+	switch {
+	case auth.IsIDTokenRevoked(err):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ID token has been revoked"})
+		c.Abort()
+		return false
+	case auth.IsUserDisabled(err):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user is disabled"})
+		c.Abort()
+		return false
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid ID token"})
+		c.Abort()
+		return false
+	}
 
-		// Attach token to the context
-		c.Set("token", token)
+	SetPrincipal(c, NewPrincipal(token))
+	return true
+}
 
-		c.Next()
+// bearerToken returns the token of a "Bearer <token>" Authorization header.
+// The scheme is case-insensitive.
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
 	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
 }
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeTokenVerifier accepts the ID tokens in tokens. Tokens in revoked are
+// only rejected when revocation is checked.
+type fakeTokenVerifier struct {
+	tokens  map[string]*auth.Token
+	revoked map[string]bool
+}
+
+func (v *fakeTokenVerifier) VerifyIDToken(_ context.Context, idToken string) (*auth.Token, error) {
+	token, ok := v.tokens[idToken]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return token, nil
+}
+
+func (v *fakeTokenVerifier) VerifyIDTokenAndCheckRevoked(ctx context.Context, idToken string) (*auth.Token, error) {
+	if v.revoked[idToken] {
+		return nil, errors.New("revoked token")
+	}
+	return v.VerifyIDToken(ctx, idToken)
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	tokens := map[string]*auth.Token{
+		"user-token": {UID: "user-1", Claims: map[string]interface{}{
+			"email":          "referee@example.com",
+			TournamentsClaim: map[string]interface{}{"beach-cup": "referee", "other-cup": "owner"},
+		}},
+		"admin-token":   {UID: "admin-1", Claims: map[string]interface{}{AdminClaim: true}},
+		"revoked-token": {UID: "user-2", Claims: map[string]interface{}{}},
+	}
+
+	cases := []struct {
+		name           string
+		header         string
+		checkRevoked   bool
+		expectedStatus int
+		expectedUser   string
+	}{
+		{name: "missing header", expectedStatus: http.StatusUnauthorized},
+		{name: "short header", header: "Bea", expectedStatus: http.StatusUnauthorized},
+		{name: "other scheme", header: "Basic user-token", expectedStatus: http.StatusUnauthorized},
+		{name: "empty token", header: "Bearer ", expectedStatus: http.StatusUnauthorized},
+		{name: "invalid token", header: "Bearer forged", expectedStatus: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer user-token", expectedStatus: http.StatusOK, expectedUser: "user-1"},
+		{name: "lowercase scheme", header: "bearer user-token", expectedStatus: http.StatusOK, expectedUser: "user-1"},
+		{name: "revoked without check", header: "Bearer revoked-token", expectedStatus: http.StatusOK, expectedUser: "user-2"},
+		{name: "revoked with check", header: "Bearer revoked-token", checkRevoked: true, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := &fakeTokenVerifier{tokens: tokens, revoked: map[string]bool{"revoked-token": true}}
+			verifier := newVerifier(client, WithRevocationCheck(c.checkRevoked))
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			var principal *Principal
+			r.GET("/admin", verifier.Middleware(), func(ctx *gin.Context) {
+				principal = MustPrincipal(ctx)
+				ctx.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body.String())
+			}
+			if c.expectedUser != "" && principal.UserID != c.expectedUser {
+				t.Fatalf("expected user %q, got %+v", c.expectedUser, principal)
+			}
+		})
+	}
+}
+
+func TestNewPrincipal(t *testing.T) {
+	principal := NewPrincipal(&auth.Token{UID: "user-1", Claims: map[string]interface{}{
+		"email":          "referee@example.com",
+		TournamentsClaim: map[string]interface{}{"beach-cup": "referee", "other-cup": "owner"},
+	}})
+	if principal.UserID != "user-1" || principal.Email != "referee@example.com" || principal.IsAdmin() {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+	if len(principal.TournamentRoles) != 1 || principal.TournamentRoles["beach-cup"] != "referee" {
+		t.Fatalf("expected only the valid tournament role, got %v", principal.TournamentRoles)
+	}
+
+	admin := NewPrincipal(&auth.Token{UID: "admin-1", Claims: map[string]interface{}{AdminClaim: true}})
+	if !admin.IsAdmin() {
+		t.Fatalf("expected the admin claim to make an admin, got %+v", admin)
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/samborkent/uuidv7"
+)
+
+// ErrForbidden is returned when the user's role in the tournament does not
+// allow the action.
+var ErrForbidden = errors.New("no access to tournament")
+
+// Action is something a member of a tournament can do.
+type Action string
+
+const (
+	// ActionManage covers members, access codes and the tournament details.
+	ActionManage Action = "manage"
+	// ActionSync covers syncing the tournament's matches from Profixio.
+	ActionSync Action = "sync"
+	// ActionReport covers reporting and finalizing match results.
+	ActionReport Action = "report"
+	// ActionView covers reading timelines and sync jobs.
+	ActionView Action = "view"
+)
+
+var roleActions = map[string][]Action{
+	storage.RoleDirector:     {ActionManage, ActionSync, ActionReport, ActionView},
+	storage.RoleReferee:      {ActionReport, ActionView},
+	storage.RoleScorekeeper:  {ActionSync, ActionReport, ActionView},
+	storage.RoleStatistician: {ActionView},
+}
+
+// ValidRole reports whether role is one of the member roles.
+func ValidRole(role string) bool {
+	_, ok := roleActions[role]
+	return ok
+}
+
+// Allows reports whether the member may perform action in scope. Referees
+// with Fields may only act on matches on those courts.
+func Allows(member storage.Member, action Action, scope Scope) bool {
+	if !slices.Contains(roleActions[member.Role], action) {
+		return false
+	}
+	if courtScoped(member.Role, action) && len(member.Fields) > 0 {
+		return slices.Contains(member.Fields, scope.Field)
+	}
+	return true
+}
+
+// courtScoped reports whether the role's permission for action can be limited
+// to the Fields of the member.
+func courtScoped(role string, action Action) bool {
+	return role == storage.RoleReferee && action == ActionReport
+}
+
+// Scope is the tournament, and the court within it, a request acts on.
+type Scope struct {
+	Slug  string
+	Field string
+}
+
+// ScopeFunc resolves the scope of a request.
+type ScopeFunc func(c *gin.Context) (Scope, error)
+
+// SlugParam returns a ScopeFunc reading the tournament slug from the named
+// route parameter.
+func SlugParam(name string) ScopeFunc {
+	return func(c *gin.Context) (Scope, error) {
+		return Scope{Slug: c.Param(name)}, nil
+	}
+}
+
+// Guard returns the middleware checking that the caller may perform action.
+type Guard func(action Action) gin.HandlerFunc
+
+// PermissionStore is the storage Permissions reads memberships from and
+// records denied attempts in.
+type PermissionStore interface {
+	GetMember(ctx context.Context, slug string, userID string) (*storage.Member, error)
+	GetTournamentSecrets(ctx context.Context, slug string) (*storage.TournamentSecrets, error)
+	RecordAudit(ctx context.Context, entry storage.AuditEntry) error
+}
+
+// Permissions checks the role of users in tournaments.
+type Permissions struct {
+	store PermissionStore
+}
+
+// NewPermissions creates Permissions reading memberships from store.
+func NewPermissions(store PermissionStore) *Permissions {
+	return &Permissions{store: store}
+}
+
+// Member returns the user's membership of the tournament. Users in the
+// tournament's allowed users without a membership are directors. It returns
+// ErrForbidden for users who are not members.
+func (p *Permissions) Member(ctx context.Context, slug string, userID string) (*storage.Member, error) {
+	member, err := p.store.GetMember(ctx, slug, userID)
+	if err == nil {
+		return member, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	secrets, err := p.store.GetTournamentSecrets(ctx, slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, slug)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(secrets.AllowedUsers, userID) {
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, slug)
+	}
+	return &storage.Member{UserID: userID, Role: storage.RoleDirector}, nil
+}
+
+// Authorize returns nil if the principal may perform action in scope, and an
+// error wrapping ErrForbidden if not. Services and federation admins may
+// perform every action, and actions outside a tournament, with an empty slug,
+// are left to them. Roles from the tournaments claim count as well as
+// memberships.
+func (p *Permissions) Authorize(ctx context.Context, principal *Principal, action Action, scope Scope) error {
+	if principal.IsAdmin() {
+		return nil
+	}
+	if scope.Slug == "" {
+		return fmt.Errorf("%w: admin access required", ErrForbidden)
+	}
+	// The tournaments claim carries no courts, so it does not grant actions
+	// that may be limited to courts; those are left to the stored membership.
+	if role, ok := principal.TournamentRoles[scope.Slug]; ok && !courtScoped(role, action) && Allows(storage.Member{Role: role}, action, scope) {
+		return nil
+	}
+
+	member, err := p.Member(ctx, scope.Slug, principal.UserID)
+	if err != nil {
+		return err
+	}
+	if !Allows(*member, action, scope) {
+		return fmt.Errorf("%w: %s may not %s in %s", ErrForbidden, member.Role, action, scope.Slug)
+	}
+	return nil
+}
+
+// Enforce is Authorize that also records denied attempts on resource in the
+// audit trail.
+func (p *Permissions) Enforce(ctx context.Context, principal *Principal, action Action, scope Scope, resource string) error {
+	err := p.Authorize(ctx, principal, action, scope)
+	if !errors.Is(err, ErrForbidden) {
+		return err
+	}
+
+	entry := storage.AuditEntry{
+		ID:       uuidv7.New().String(),
+		At:       time.Now(),
+		UserID:   principal.UserID,
+		Slug:     scope.Slug,
+		Field:    scope.Field,
+		Action:   string(action),
+		Resource: resource,
+		Reason:   err.Error(),
+	}
+	if auditErr := p.store.RecordAudit(ctx, entry); auditErr != nil {
+		log.Error("failed to record audit entry", auditErr, log.Fields{"slug": scope.Slug, "userID": principal.UserID, "action": string(action), "resource": resource})
+	}
+	return err
+}
+
+// Guard returns a Guard checking actions in the scope resolved by scope.
+func (p *Permissions) Guard(scope ScopeFunc) Guard {
+	return func(action Action) gin.HandlerFunc {
+		return p.Require(action, scope)
+	}
+}
+
+// Require returns middleware that aborts the request unless the principal of
+// the request may perform action in the scope resolved by scope. Services
+// and federation admins may perform every action. Denied requests are
+// recorded in the audit trail. It must run after Verifier.Middleware or
+// ServiceAuthMiddleware.
+func (p *Permissions) Require(action Action, scope ScopeFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
+			c.Abort()
+			return
+		}
+		if principal.IsAdmin() {
+			c.Next()
+			return
+		}
+
+		resolved, err := scope(c)
+		if err == nil {
+			err = p.Enforce(c, principal, action, resolved, c.Request.URL.Path)
+		}
+		switch {
+		case errors.Is(err, ErrForbidden):
+			log.Warning("request forbidden", log.WithRequest(c, log.Fields{"path": c.FullPath(), "slug": resolved.Slug, "action": string(action), "userID": principal.UserID}))
+			c.JSON(http.StatusForbidden, gin.H{"error": ErrForbidden.Error()})
+			c.Abort()
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			c.Abort()
+			return
+		case err != nil:
+			log.Error("authorization failed", err, log.WithRequest(c, log.Fields{"path": c.FullPath(), "action": string(action)}))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
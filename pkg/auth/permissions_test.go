@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func TestAuthorize(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup", AllowedUsers: []string{"legacy"}})
+	members := []storage.Member{
+		{UserID: "director", Role: storage.RoleDirector},
+		{UserID: "referee", Role: storage.RoleReferee, Fields: []string{"Bane 1"}},
+		{UserID: "any-court-referee", Role: storage.RoleReferee},
+		{UserID: "scorekeeper", Role: storage.RoleScorekeeper},
+		{UserID: "statistician", Role: storage.RoleStatistician},
+	}
+	for _, member := range members {
+		_ = store.PutMember(context.Background(), "beach-cup", member)
+	}
+	permissions := NewPermissions(store)
+
+	cases := []struct {
+		userID  string
+		action  Action
+		field   string
+		allowed bool
+	}{
+		{userID: "director", action: ActionManage, allowed: true},
+		{userID: "legacy", action: ActionManage, allowed: true},
+		{userID: "referee", action: ActionReport, field: "Bane 1", allowed: true},
+		{userID: "referee", action: ActionReport, field: "Bane 2"},
+		{userID: "referee", action: ActionSync},
+		{userID: "referee", action: ActionView, allowed: true},
+		{userID: "any-court-referee", action: ActionReport, field: "Bane 2", allowed: true},
+		{userID: "scorekeeper", action: ActionSync, allowed: true},
+		{userID: "scorekeeper", action: ActionManage},
+		{userID: "statistician", action: ActionView, allowed: true},
+		{userID: "statistician", action: ActionReport, field: "Bane 1"},
+		{userID: "stranger", action: ActionView},
+	}
+	for _, c := range cases {
+		t.Run(c.userID+"/"+string(c.action), func(t *testing.T) {
+			err := permissions.Authorize(context.Background(), &Principal{UserID: c.userID}, c.action, Scope{Slug: "beach-cup", Field: c.field})
+			if c.allowed && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !c.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("expected %v, got %v", ErrForbidden, err)
+			}
+		})
+	}
+
+	if err := permissions.Authorize(context.Background(), &Principal{UserID: "director"}, ActionView, Scope{Slug: "missing"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v for an unknown tournament, got %v", ErrForbidden, err)
+	}
+}
+
+func TestAuthorizeClaimRoles(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup"})
+	_ = store.PutMember(context.Background(), "beach-cup", storage.Member{UserID: "court-referee", Role: storage.RoleReferee, Fields: []string{"Bane 1"}})
+	permissions := NewPermissions(store)
+
+	cases := []struct {
+		name    string
+		userID  string
+		role    string
+		action  Action
+		field   string
+		allowed bool
+	}{
+		{name: "claim referee views", userID: "claim-referee", role: storage.RoleReferee, action: ActionView, allowed: true},
+		{name: "claim referee reports", userID: "claim-referee", role: storage.RoleReferee, action: ActionReport, field: "Bane 2"},
+		{name: "member referee reports on own court", userID: "court-referee", role: storage.RoleReferee, action: ActionReport, field: "Bane 1", allowed: true},
+		{name: "member referee reports on other court", userID: "court-referee", role: storage.RoleReferee, action: ActionReport, field: "Bane 2"},
+		{name: "claim scorekeeper syncs", userID: "claim-scorekeeper", role: storage.RoleScorekeeper, action: ActionSync, allowed: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			principal := &Principal{UserID: c.userID, TournamentRoles: map[string]string{"beach-cup": c.role}}
+			err := permissions.Authorize(context.Background(), principal, c.action, Scope{Slug: "beach-cup", Field: c.field})
+			if c.allowed && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !c.allowed && !errors.Is(err, ErrForbidden) {
+				t.Fatalf("expected %v, got %v", ErrForbidden, err)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup"})
+	_ = store.PutMember(context.Background(), "beach-cup", storage.Member{UserID: "statistician", Role: storage.RoleStatistician})
+	guard := NewPermissions(store).Guard(SlugParam("slug_id"))
+
+	cases := []struct {
+		name           string
+		userID         string
+		admin          bool
+		path           string
+		action         Action
+		expectedStatus int
+	}{
+		{name: "no token", action: ActionView, expectedStatus: http.StatusUnauthorized},
+		{name: "allowed", userID: "statistician", action: ActionView, expectedStatus: http.StatusOK},
+		{name: "role does not allow", userID: "statistician", action: ActionSync, expectedStatus: http.StatusForbidden},
+		{name: "not a member", userID: "stranger", action: ActionView, expectedStatus: http.StatusForbidden},
+		{name: "admin", userID: "admin", admin: true, action: ActionSync, expectedStatus: http.StatusOK},
+		{name: "outside a tournament", userID: "statistician", path: "/tournaments", action: ActionView, expectedStatus: http.StatusForbidden},
+		{name: "admin outside a tournament", userID: "admin", admin: true, path: "/tournaments", action: ActionSync, expectedStatus: http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			if c.userID != "" {
+				r.Use(func(ctx *gin.Context) {
+					SetPrincipal(ctx, NewPrincipal(&auth.Token{UID: c.userID, Claims: map[string]interface{}{AdminClaim: c.admin}}))
+				})
+			}
+			ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+			r.GET("/tournament/:slug_id", guard(c.action), ok)
+			r.GET("/tournaments", guard(c.action), ok)
+
+			path := c.path
+			if path == "" {
+				path = "/tournament/beach-cup"
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
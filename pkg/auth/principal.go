@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// TournamentsClaim is the Firebase custom claim mapping tournament slugs to
+// member roles, such as {"beach-cup": "referee"}.
+const TournamentsClaim = "tournaments"
+
+// principalKey is the context key of the authenticated Principal.
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request: a Firebase user or a
+// service.
+type Principal struct {
+	UserID string
+	Email  string
+	// Service names the authenticated service. It is empty for users.
+	Service string
+	// FederationAdmin is set by the admin custom claim.
+	FederationAdmin bool
+	// TournamentRoles are the member roles granted by the tournaments custom
+	// claim, keyed by tournament slug. The claim carries no courts, so a
+	// referee still needs a membership to report results.
+	TournamentRoles map[string]string
+	// Token is the verified Firebase ID token of users.
+	Token *auth.Token
+}
+
+// NewPrincipal reads the user and custom claims of a verified ID token.
+func NewPrincipal(token *auth.Token) *Principal {
+	principal := &Principal{
+		UserID:          token.UID,
+		Token:           token,
+		TournamentRoles: map[string]string{},
+	}
+	principal.Email, _ = token.Claims["email"].(string)
+	principal.FederationAdmin, _ = token.Claims[AdminClaim].(bool)
+	if roles, ok := token.Claims[TournamentsClaim].(map[string]interface{}); ok {
+		for slug, role := range roles {
+			if role, ok := role.(string); ok && ValidRole(role) {
+				principal.TournamentRoles[slug] = role
+			}
+		}
+	}
+	return principal
+}
+
+// IsAdmin reports whether the principal is a service or a federation admin,
+// who may act on every tournament.
+func (p *Principal) IsAdmin() bool {
+	return p.Service != "" || p.FederationAdmin
+}
+
+// SetPrincipal attaches the principal to the request context.
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// PrincipalFrom returns the principal attached to the request context.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok && principal != nil
+}
+
+// MustPrincipal returns the principal attached to the request context and
+// panics if there is none. Use it in handlers behind the auth middleware.
+func MustPrincipal(c *gin.Context) *Principal {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		panic("auth: no principal on the request context")
+	}
+	return principal
+}
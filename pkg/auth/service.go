@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/idtoken"
+)
+
+// AdminClaim is the Firebase custom claim marking federation admins, who
+// may call the sync and stats endpoints of every tournament.
+const AdminClaim = "admin"
+
+// validateOIDC verifies Google-signed OIDC ID tokens. Tests replace it.
+var validateOIDC = idtoken.Validate
+
+// ServiceOptions configures the services accepted by ServiceAuthMiddleware.
+type ServiceOptions struct {
+	// Audience is the audience expected in OIDC ID tokens, usually the URL
+	// of the service. OIDC tokens are rejected when it is empty.
+	Audience string
+	// ServiceAccounts are the emails of the service accounts, such as the
+	// one Cloud Scheduler uses, that may call with OIDC ID tokens.
+	ServiceAccounts []string
+	// Tokens are static bearer tokens accepted from services.
+	Tokens []string
+}
+
+// ServiceAuthMiddleware authenticates services by a static service token or
+// an OIDC ID token from one of the allowed service accounts, and users by
+// their Firebase ID token. Routes behind it check what the caller may do with
+// a Guard.
+func ServiceAuthMiddleware(verifier *Verifier, opts ServiceOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer, _ := bearerToken(c.GetHeader("Authorization"))
+
+		for i, token := range opts.Tokens {
+			if bearer != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				SetPrincipal(c, &Principal{Service: fmt.Sprintf("service-token-%d", i+1)})
+				c.Next()
+				return
+			}
+		}
+
+		if issuer, ok := tokenIssuer(bearer); ok && isGoogleIssuer(issuer) {
+			if opts.Audience == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid ID token"})
+				c.Abort()
+				return
+			}
+			payload, err := validateOIDC(c, bearer, opts.Audience)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid ID token"})
+				c.Abort()
+				return
+			}
+			email, _ := payload.Claims["email"].(string)
+			verified, _ := payload.Claims["email_verified"].(bool)
+			if !verified || !slices.Contains(opts.ServiceAccounts, email) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "service account is not allowed"})
+				c.Abort()
+				return
+			}
+			SetPrincipal(c, &Principal{Service: email, Email: email})
+			c.Next()
+			return
+		}
+
+		if verifier.verify(c) {
+			c.Next()
+		}
+	}
+}
+
+// tokenIssuer reads the iss claim of a JWT without verifying it, to tell
+// OIDC tokens from Firebase ID tokens.
+func tokenIssuer(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", false
+	}
+	return claims.Issuer, true
+}
+
+func isGoogleIssuer(issuer string) bool {
+	return issuer == "https://accounts.google.com" || issuer == "accounts.google.com"
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"google.golang.org/api/idtoken"
+)
+
+// oidcToken builds an unsigned JWT with a Google issuer. validateOIDC is
+// replaced in the tests, so the signature is never checked.
+func oidcToken(subject string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(`{"iss":"https://accounts.google.com","sub":"`+subject+`"}`)) + ".signature"
+}
+
+// stubOIDC accepts OIDC tokens for the scheduler and other service accounts
+// with the test audience until the returned func is called.
+func stubOIDC() func() {
+	validateOIDC = func(_ context.Context, token string, audience string) (*idtoken.Payload, error) {
+		if audience != "https://tournament-sync.example" {
+			return nil, errors.New("wrong audience")
+		}
+		emails := map[string]string{
+			oidcToken("scheduler"): "scheduler@project.iam.gserviceaccount.com",
+			oidcToken("other"):     "other@project.iam.gserviceaccount.com",
+		}
+		email, ok := emails[token]
+		if !ok {
+			return nil, errors.New("invalid token")
+		}
+		return &idtoken.Payload{Claims: map[string]interface{}{"email": email, "email_verified": true}}, nil
+	}
+	return func() { validateOIDC = idtoken.Validate }
+}
+
+func TestServiceAuthMiddleware(t *testing.T) {
+	defer stubOIDC()()
+
+	opts := ServiceOptions{
+		Audience:        "https://tournament-sync.example",
+		ServiceAccounts: []string{"scheduler@project.iam.gserviceaccount.com"},
+		Tokens:          []string{"static-service-token-0123456789abcdef"},
+	}
+	cases := []struct {
+		name            string
+		header          string
+		opts            ServiceOptions
+		expectedStatus  int
+		expectedService string
+	}{
+		{name: "service token", header: "Bearer static-service-token-0123456789abcdef", opts: opts, expectedStatus: http.StatusOK, expectedService: "service-token-1"},
+		{name: "allowed service account", header: "Bearer " + oidcToken("scheduler"), opts: opts, expectedStatus: http.StatusOK, expectedService: "scheduler@project.iam.gserviceaccount.com"},
+		{name: "other service account", header: "Bearer " + oidcToken("other"), opts: opts, expectedStatus: http.StatusForbidden},
+		{name: "forged OIDC token", header: "Bearer " + oidcToken("forged"), opts: opts, expectedStatus: http.StatusUnauthorized},
+		{name: "OIDC without audience", header: "Bearer " + oidcToken("scheduler"), opts: ServiceOptions{ServiceAccounts: opts.ServiceAccounts}, expectedStatus: http.StatusUnauthorized},
+		{name: "no header", opts: opts, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			var service string
+			r.GET("/sync", ServiceAuthMiddleware(newVerifier(&fakeTokenVerifier{}), c.opts), func(ctx *gin.Context) {
+				service = MustPrincipal(ctx).Service
+				ctx.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/sync", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body.String())
+			}
+			if c.expectedService != "" && service != c.expectedService {
+				t.Fatalf("expected service %q, got %q", c.expectedService, service)
+			}
+		})
+	}
+}
+
+// TestServiceRoutes wires the sync and stats routers the way main does:
+// ServiceAuthMiddleware on the group and a Guard on every route.
+func TestServiceRoutes(t *testing.T) {
+	defer stubOIDC()()
+
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup"})
+	verifier := newVerifier(&fakeTokenVerifier{tokens: map[string]*auth.Token{
+		"admin-token": {UID: "admin-1", Claims: map[string]interface{}{AdminClaim: true}},
+		"user-token":  {UID: "user-1", Claims: map[string]interface{}{}},
+	}})
+	opts := ServiceOptions{
+		Audience:        "https://tournament-sync.example",
+		ServiceAccounts: []string{"scheduler@project.iam.gserviceaccount.com"},
+		Tokens:          []string{"static-service-token-0123456789abcdef"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	group := r.Group("/sync/v1")
+	group.Use(ServiceAuthMiddleware(verifier, opts))
+	guard := NewPermissions(store).Guard(SlugParam("slug_id"))
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	group.GET("/tournaments", guard(ActionSync), ok)
+	group.GET("/tournament/:slug_id", guard(ActionSync), ok)
+
+	cases := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{name: "service token", header: "Bearer static-service-token-0123456789abcdef", expectedStatus: http.StatusOK},
+		{name: "OIDC token", header: "Bearer " + oidcToken("scheduler"), expectedStatus: http.StatusOK},
+		{name: "Firebase admin", header: "Bearer admin-token", expectedStatus: http.StatusOK},
+		{name: "Firebase user", header: "Bearer user-token", expectedStatus: http.StatusForbidden},
+		{name: "no header", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		for _, path := range []string{"/sync/v1/tournaments", "/sync/v1/tournament/beach-cup"} {
+			t.Run(c.name+path, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if c.header != "" {
+					req.Header.Set("Authorization", c.header)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != c.expectedStatus {
+					t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package config loads the service settings from the environment and an
+// optional YAML file, and validates them at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable pointing at an optional YAML file.
+// Environment variables override the values in the file.
+const FileEnv = "CONFIG_FILE"
+
+const defaultPort = "8080"
+
+const (
+	defaultAccessCodeTTL = 72 * time.Hour
+	// minSigningKeyLength is the shortest access code signing key accepted,
+	// the size of an HMAC-SHA256 key.
+	minSigningKeyLength = 32
+	// minServiceTokenLength is the shortest static service token accepted.
+	minServiceTokenLength = 32
+)
+
+// Config holds every setting the service reads at startup.
+type Config struct {
+	Port        string      `yaml:"port"`
+	HostURL     string      `yaml:"hostURL"`
+	CORSHosts   []string    `yaml:"corsHosts"`
+	Firestore   Firestore   `yaml:"firestore"`
+	Profixio    Profixio    `yaml:"profixio"`
+	Resend      Resend      `yaml:"resend"`
+	Scheduler   Scheduler   `yaml:"scheduler"`
+	AccessCode  AccessCode  `yaml:"accessCode"`
+	ServiceAuth ServiceAuth `yaml:"serviceAuth"`
+	Stats       Stats       `yaml:"stats"`
+	Auth        Auth        `yaml:"auth"`
+}
+
+type Firestore struct {
+	ProjectID       string `yaml:"projectID"`
+	DatabaseID      string `yaml:"databaseID"`
+	CredentialsJSON string `yaml:"credentialsJSON"`
+}
+
+type Profixio struct {
+	Host   string `yaml:"host"`
+	APIKey string `yaml:"apiKey"`
+	// Sources are "organisation/sport" IDs, such as "NVBF.NO.VB/SVB". The
+	// NVBF beach listing is synced when empty.
+	Sources []string `yaml:"sources"`
+}
+
+type Resend struct {
+	APIKey string `yaml:"apiKey"`
+}
+
+type Scheduler struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type AccessCode struct {
+	// SigningKey signs the access codes mailed to organisers. Changing it
+	// invalidates every code issued.
+	SigningKey string `yaml:"signingKey"`
+	// TTL is how long an access code is valid, 72 hours by default.
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type ServiceAuth struct {
+	// Audience is the audience of the OIDC ID tokens sent by Cloud
+	// Scheduler, usually the URL of this service.
+	Audience string `yaml:"audience"`
+	// ServiceAccounts are the emails of the service accounts allowed to call
+	// the sync and stats endpoints with OIDC ID tokens.
+	ServiceAccounts []string `yaml:"serviceAccounts"`
+	// Tokens are static bearer tokens accepted from other services.
+	Tokens []string `yaml:"tokens"`
+}
+
+type Auth struct {
+	// CheckRevoked rejects Firebase ID tokens that were revoked or belong to
+	// disabled users, at the cost of a user lookup per request.
+	CheckRevoked bool `yaml:"checkRevoked"`
+}
+
+type Stats struct {
+	// Public serves the aggregate tournament stats without authentication
+	// under /public/stats/v1. Player stats always require authentication.
+	Public bool `yaml:"public"`
+}
+
+// FromEnv loads the configuration from the process environment.
+func FromEnv() (*Config, error) {
+	return Load(os.LookupEnv)
+}
+
+// Load reads the YAML file named by CONFIG_FILE, if any, applies the
+// environment variables found by lookup on top and validates the result.
+func Load(lookup func(key string) (string, bool)) (*Config, error) {
+	cfg := &Config{}
+
+	if path, ok := lookup(FileEnv); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: read %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(lookup); err != nil {
+		return nil, err
+	}
+	if cfg.Port == "" {
+		cfg.Port = defaultPort
+	}
+	if cfg.AccessCode.TTL == 0 {
+		cfg.AccessCode.TTL = defaultAccessCodeTTL
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(lookup func(key string) (string, bool)) error {
+	values := map[string]*string{
+		"PORT":                      &c.Port,
+		"HOST_URL":                  &c.HostURL,
+		"FIRESTORE_PROJECT_ID":      &c.Firestore.ProjectID,
+		"FIRESTORE_DATABASE_ID":     &c.Firestore.DatabaseID,
+		"FIRESTORE_CREDENTIAL_JSON": &c.Firestore.CredentialsJSON,
+		"PROFIXIO_HOST":             &c.Profixio.Host,
+		"PROFIXIO_KEY":              &c.Profixio.APIKey,
+		"RESEND_KEY":                &c.Resend.APIKey,
+		"ACCESS_CODE_SIGNING_KEY":   &c.AccessCode.SigningKey,
+		"SERVICE_AUTH_AUDIENCE":     &c.ServiceAuth.Audience,
+	}
+	for key, field := range values {
+		if value, ok := lookup(key); ok && value != "" {
+			*field = value
+		}
+	}
+
+	lists := map[string]*[]string{
+		"CORS_HOSTS":            &c.CORSHosts,
+		"PROFIXIO_SOURCES":      &c.Profixio.Sources,
+		"SERVICE_AUTH_ACCOUNTS": &c.ServiceAuth.ServiceAccounts,
+		"SERVICE_AUTH_TOKENS":   &c.ServiceAuth.Tokens,
+	}
+	for key, field := range lists {
+		if value, ok := lookup(key); ok && value != "" {
+			*field = splitList(value)
+		}
+	}
+
+	if value, ok := lookup("SCHEDULER_ENABLED"); ok && value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: SCHEDULER_ENABLED must be true or false, got %q", value)
+		}
+		c.Scheduler.Enabled = enabled
+	}
+
+	if value, ok := lookup("AUTH_CHECK_REVOKED"); ok && value != "" {
+		checkRevoked, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: AUTH_CHECK_REVOKED must be true or false, got %q", value)
+		}
+		c.Auth.CheckRevoked = checkRevoked
+	}
+
+	if value, ok := lookup("STATS_PUBLIC"); ok && value != "" {
+		public, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: STATS_PUBLIC must be true or false, got %q", value)
+		}
+		c.Stats.Public = public
+	}
+
+	if value, ok := lookup("ACCESS_CODE_TTL"); ok && value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("config: ACCESS_CODE_TTL must be a duration such as 72h, got %q", value)
+		}
+		c.AccessCode.TTL = ttl
+	}
+	return nil
+}
+
+// Validate reports every missing or malformed setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"HOST_URL", c.HostURL},
+		{"FIRESTORE_PROJECT_ID", c.Firestore.ProjectID},
+		{"FIRESTORE_CREDENTIAL_JSON", c.Firestore.CredentialsJSON},
+		{"PROFIXIO_HOST", c.Profixio.Host},
+		{"PROFIXIO_KEY", c.Profixio.APIKey},
+		{"RESEND_KEY", c.Resend.APIKey},
+		{"ACCESS_CODE_SIGNING_KEY", c.AccessCode.SigningKey},
+	}
+	for _, setting := range required {
+		if strings.TrimSpace(setting.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", setting.key))
+		}
+	}
+
+	if _, err := strconv.ParseUint(c.Port, 10, 16); err != nil {
+		errs = append(errs, fmt.Errorf("PORT must be a port number, got %q", c.Port))
+	}
+	if key := c.AccessCode.SigningKey; strings.TrimSpace(key) != "" && len(key) < minSigningKeyLength {
+		errs = append(errs, fmt.Errorf("ACCESS_CODE_SIGNING_KEY must be at least %d characters", minSigningKeyLength))
+	}
+	if c.AccessCode.TTL < 0 {
+		errs = append(errs, fmt.Errorf("ACCESS_CODE_TTL must be positive, got %s", c.AccessCode.TTL))
+	}
+	if len(c.ServiceAuth.ServiceAccounts) > 0 && strings.TrimSpace(c.ServiceAuth.Audience) == "" {
+		errs = append(errs, errors.New("SERVICE_AUTH_AUDIENCE is required with SERVICE_AUTH_ACCOUNTS"))
+	}
+	for _, token := range c.ServiceAuth.Tokens {
+		if len(token) < minServiceTokenLength {
+			errs = append(errs, fmt.Errorf("SERVICE_AUTH_TOKENS entries must be at least %d characters", minServiceTokenLength))
+			break
+		}
+	}
+	for _, source := range c.Profixio.Sources {
+		organisation, sport, ok := strings.Cut(source, "/")
+		if !ok || organisation == "" || sport == "" || strings.Contains(sport, "/") {
+			errs = append(errs, fmt.Errorf("PROFIXIO_SOURCES entry %q must be organisation/sport", source))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("config: invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func requiredEnv() map[string]string {
+	return map[string]string{
+		"HOST_URL":                  "https://scoreboard.example",
+		"FIRESTORE_PROJECT_ID":      "project",
+		"FIRESTORE_CREDENTIAL_JSON": "{}",
+		"PROFIXIO_HOST":             "www.profixio.com",
+		"PROFIXIO_KEY":              "profixio-key",
+		"RESEND_KEY":                "resend-key",
+		"ACCESS_CODE_SIGNING_KEY":   "0123456789abcdef0123456789abcdef",
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	values := requiredEnv()
+	values["CORS_HOSTS"] = "https://a.example, https://b.example"
+	values["PROFIXIO_SOURCES"] = "NVBF.NO.VB/SVB,NVBF.NO.VB/VB"
+	values["SCHEDULER_ENABLED"] = "true"
+	values["ACCESS_CODE_TTL"] = "24h"
+	values["SERVICE_AUTH_AUDIENCE"] = "https://tournament-sync.example"
+	values["SERVICE_AUTH_ACCOUNTS"] = "scheduler@project.iam.gserviceaccount.com"
+	values["STATS_PUBLIC"] = "true"
+	values["AUTH_CHECK_REVOKED"] = "true"
+
+	cfg, err := Load(env(values))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := &Config{
+		Port:      defaultPort,
+		HostURL:   "https://scoreboard.example",
+		CORSHosts: []string{"https://a.example", "https://b.example"},
+		Firestore: Firestore{ProjectID: "project", CredentialsJSON: "{}"},
+		Profixio: Profixio{
+			Host:    "www.profixio.com",
+			APIKey:  "profixio-key",
+			Sources: []string{"NVBF.NO.VB/SVB", "NVBF.NO.VB/VB"},
+		},
+		Resend:     Resend{APIKey: "resend-key"},
+		Scheduler:  Scheduler{Enabled: true},
+		AccessCode: AccessCode{SigningKey: "0123456789abcdef0123456789abcdef", TTL: 24 * time.Hour},
+		ServiceAuth: ServiceAuth{
+			Audience:        "https://tournament-sync.example",
+			ServiceAccounts: []string{"scheduler@project.iam.gserviceaccount.com"},
+		},
+		Stats: Stats{Public: true},
+		Auth:  Auth{CheckRevoked: true},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestLoadFileWithEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `
+port: "9090"
+hostURL: https://file.example
+firestore:
+  projectID: file-project
+  databaseID: file-db
+  credentialsJSON: "{}"
+profixio:
+  host: www.profixio.com
+  apiKey: file-key
+  sources:
+    - NVBF.NO.VB/VB
+resend:
+  apiKey: resend-key
+scheduler:
+  enabled: true
+accessCode:
+  signingKey: file-signing-key-0123456789abcdef
+  ttl: 48h
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(env(map[string]string{
+		FileEnv:        path,
+		"PROFIXIO_KEY": "env-key",
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != "9090" || cfg.Firestore.DatabaseID != "file-db" || !cfg.Scheduler.Enabled {
+		t.Fatalf("expected values from the file, got %+v", cfg)
+	}
+	if cfg.AccessCode.TTL != 48*time.Hour {
+		t.Fatalf("expected the access code TTL from the file, got %s", cfg.AccessCode.TTL)
+	}
+	if cfg.Profixio.APIKey != "env-key" {
+		t.Fatalf("expected the environment to override the file, got %q", cfg.Profixio.APIKey)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      func(values map[string]string)
+		expected []string
+	}{
+		{
+			name: "missing required values",
+			env: func(values map[string]string) {
+				delete(values, "PROFIXIO_KEY")
+				delete(values, "RESEND_KEY")
+			},
+			expected: []string{"PROFIXIO_KEY is required", "RESEND_KEY is required"},
+		},
+		{
+			name:     "bad port",
+			env:      func(values map[string]string) { values["PORT"] = "http" },
+			expected: []string{`PORT must be a port number, got "http"`},
+		},
+		{
+			name:     "bad source",
+			env:      func(values map[string]string) { values["PROFIXIO_SOURCES"] = "NVBF.NO.VB" },
+			expected: []string{`PROFIXIO_SOURCES entry "NVBF.NO.VB" must be organisation/sport`},
+		},
+		{
+			name:     "bad scheduler flag",
+			env:      func(values map[string]string) { values["SCHEDULER_ENABLED"] = "sometimes" },
+			expected: []string{"SCHEDULER_ENABLED must be true or false"},
+		},
+		{
+			name:     "short signing key",
+			env:      func(values map[string]string) { values["ACCESS_CODE_SIGNING_KEY"] = "secret" },
+			expected: []string{"ACCESS_CODE_SIGNING_KEY must be at least 32 characters"},
+		},
+		{
+			name:     "bad access code ttl",
+			env:      func(values map[string]string) { values["ACCESS_CODE_TTL"] = "3 days" },
+			expected: []string{"ACCESS_CODE_TTL must be a duration"},
+		},
+		{
+			name: "service accounts without audience",
+			env: func(values map[string]string) {
+				values["SERVICE_AUTH_ACCOUNTS"] = "scheduler@project.iam.gserviceaccount.com"
+			},
+			expected: []string{"SERVICE_AUTH_AUDIENCE is required with SERVICE_AUTH_ACCOUNTS"},
+		},
+		{
+			name:     "short service token",
+			env:      func(values map[string]string) { values["SERVICE_AUTH_TOKENS"] = "token" },
+			expected: []string{"SERVICE_AUTH_TOKENS entries must be at least 32 characters"},
+		},
+		{
+			name:     "missing file",
+			env:      func(values map[string]string) { values[FileEnv] = "/does/not/exist.yaml" },
+			expected: []string{"config: read /does/not/exist.yaml"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values := requiredEnv()
+			c.env(values)
+
+			_, err := Load(env(values))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, message := range c.expected {
+				if !strings.Contains(err.Error(), message) {
+					t.Errorf("expected %q in %q", message, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package replay replays the events of a match, so every service that reads a
+// scoreboard agrees on which events count.
+package replay
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// EventUndo is the type of an event that undoes the event it references.
+const EventUndo = "UNDO"
+
+// ActiveEvents returns the events sorted by time, without the events that are
+// undone by an active UNDO event. An UNDO can itself be undone, which makes the
+// event it references active again. Active UNDO events are kept in the result;
+// callers that only count plays skip them.
+func ActiveEvents(events []storage.Event) []storage.Event {
+	sorted := make([]storage.Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	undoesByReference := make(map[string][]string)
+	for _, event := range sorted {
+		if event.EventType == EventUndo && event.Reference != "" {
+			undoesByReference[event.Reference] = append(undoesByReference[event.Reference], event.ID)
+		}
+	}
+
+	activeByID := make(map[string]bool, len(sorted))
+	visiting := make(map[string]bool)
+	var isActive func(eventID string) bool
+	isActive = func(eventID string) bool {
+		if active, ok := activeByID[eventID]; ok {
+			return active
+		}
+		if visiting[eventID] {
+			return true
+		}
+
+		visiting[eventID] = true
+		active := true
+		for _, undoID := range undoesByReference[eventID] {
+			if isActive(undoID) {
+				active = false
+				break
+			}
+		}
+		delete(visiting, eventID)
+		activeByID[eventID] = active
+		return active
+	}
+
+	active := make([]storage.Event, 0, len(sorted))
+	for _, event := range sorted {
+		if isActive(event.ID) {
+			active = append(active, event)
+		}
+	}
+
+	return active
+}
+
+// EventTime converts an event timestamp, which older scoreboards send in
+// seconds and newer ones in milliseconds.
+func EventTime(timestamp int64) time.Time {
+	if timestamp > 1_000_000_000_000 {
+		return time.UnixMilli(timestamp)
+	}
+
+	return time.Unix(timestamp, 0)
+}
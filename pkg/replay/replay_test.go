@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func TestActiveEvents(t *testing.T) {
+	cases := []struct {
+		name     string
+		events   []storage.Event
+		expected []string
+	}{
+		{
+			name: "sorted by time",
+			events: []storage.Event{
+				{ID: "b", EventType: "SCORE", Timestamp: 2},
+				{ID: "a", EventType: "SCORE", Timestamp: 1},
+			},
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "undone event",
+			events: []storage.Event{
+				{ID: "a", EventType: "SCORE", Timestamp: 1},
+				{ID: "undo", EventType: EventUndo, Reference: "a", Timestamp: 2},
+			},
+			expected: []string{"undo"},
+		},
+		{
+			name: "undone undo",
+			events: []storage.Event{
+				{ID: "a", EventType: "SCORE", Timestamp: 1},
+				{ID: "undo", EventType: EventUndo, Reference: "a", Timestamp: 2},
+				{ID: "redo", EventType: EventUndo, Reference: "undo", Timestamp: 3},
+			},
+			expected: []string{"a", "redo"},
+		},
+		{
+			name: "undo cycle",
+			events: []storage.Event{
+				{ID: "x", EventType: EventUndo, Reference: "y", Timestamp: 1},
+				{ID: "y", EventType: EventUndo, Reference: "x", Timestamp: 2},
+			},
+			expected: []string{"x"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var ids []string
+			for _, event := range ActiveEvents(c.events) {
+				ids = append(ids, event.ID)
+			}
+			if !reflect.DeepEqual(ids, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, ids)
+			}
+		})
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	cases := []struct {
+		name      string
+		timestamp int64
+		expected  time.Time
+	}{
+		{name: "seconds", timestamp: 1_700_000_000, expected: time.Unix(1_700_000_000, 0)},
+		{name: "milliseconds", timestamp: 1_700_000_000_123, expected: time.UnixMilli(1_700_000_000_123)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := EventTime(c.timestamp); !got.Equal(c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
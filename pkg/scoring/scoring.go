@@ -0,0 +1,196 @@
+// Package scoring describes how volleyball matches are scored, so results can
+// be validated for beach, indoor and custom formats alike.
+package scoring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidRules is returned when scoring rules cannot decide a match.
+var ErrInvalidRules = errors.New("invalid scoring rules")
+
+// Names of the preset rules.
+const (
+	PresetBeach     = "beach"
+	PresetIndoor    = "indoor"
+	PresetYouth     = "youth"
+	PresetSingleSet = "single-set"
+	PresetCustom    = "custom"
+)
+
+// Rules describe the sets of a match and the points needed to win them.
+type Rules struct {
+	// Name is the preset the rules come from, or "custom".
+	Name string `json:"name" firestore:"name"`
+	// BestOf is the maximum number of sets. A team wins the match by winning
+	// more than half of them.
+	BestOf int `json:"bestOf" firestore:"bestOf"`
+	// SetPoints is the score that wins a set.
+	SetPoints int `json:"setPoints" firestore:"setPoints"`
+	// DecidingSetPoints is the score that wins the last possible set.
+	DecidingSetPoints int `json:"decidingSetPoints" firestore:"decidingSetPoints"`
+	// MinLead is the lead a team needs to win a set.
+	MinLead int `json:"minLead" firestore:"minLead"`
+	// PointCap ends a set when a team reaches it, whatever the lead. Zero
+	// means sets are played until a team has the lead.
+	PointCap int `json:"pointCap" firestore:"pointCap"`
+	// SetTimeLimitMinutes allows sets to be ended by the referee when time
+	// runs out, with the leading team winning. Zero means no time limit.
+	SetTimeLimitMinutes int `json:"setTimeLimitMinutes" firestore:"setTimeLimitMinutes"`
+	// SideSwitchPoints and DecidingSideSwitchPoints are how many points are
+	// played between side switches. Zero means teams do not switch mid-set.
+	SideSwitchPoints         int `json:"sideSwitchPoints" firestore:"sideSwitchPoints"`
+	DecidingSideSwitchPoints int `json:"decidingSideSwitchPoints" firestore:"decidingSideSwitchPoints"`
+	// TechnicalTimeoutPoints is the combined score of the technical timeout
+	// in sets other than the deciding set. Zero means none.
+	TechnicalTimeoutPoints int `json:"technicalTimeoutPoints" firestore:"technicalTimeoutPoints"`
+}
+
+var presets = map[string]Rules{
+	PresetBeach:     {Name: PresetBeach, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, SideSwitchPoints: 7, DecidingSideSwitchPoints: 5, TechnicalTimeoutPoints: 21},
+	PresetIndoor:    {Name: PresetIndoor, BestOf: 5, SetPoints: 25, DecidingSetPoints: 15, MinLead: 2},
+	PresetYouth:     {Name: PresetYouth, BestOf: 3, SetPoints: 15, DecidingSetPoints: 15, MinLead: 2, SideSwitchPoints: 5, DecidingSideSwitchPoints: 5},
+	PresetSingleSet: {Name: PresetSingleSet, BestOf: 1, SetPoints: 21, DecidingSetPoints: 21, MinLead: 2, SideSwitchPoints: 7, DecidingSideSwitchPoints: 7},
+}
+
+// Beach returns the rules of beach volleyball: best of 3 sets to 21 with a
+// deciding set to 15. Matches without rules of their own use these.
+func Beach() Rules {
+	return presets[PresetBeach]
+}
+
+// Preset returns the named preset rules.
+func Preset(name string) (Rules, bool) {
+	rules, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	return rules, ok
+}
+
+// Presets returns the names of all preset rules.
+func Presets() []string {
+	return []string{PresetBeach, PresetIndoor, PresetYouth, PresetSingleSet}
+}
+
+// WithDefaults fills rules that only name a preset with the preset values,
+// so {"name": "indoor"} is enough to pick indoor rules. A point cap or time
+// limit can be added to a preset.
+func (r Rules) WithDefaults() Rules {
+	if r.BestOf != 0 || r.SetPoints != 0 || r.DecidingSetPoints != 0 || r.MinLead != 0 {
+		if r.Name == "" {
+			r.Name = PresetCustom
+		}
+		if r.DecidingSetPoints == 0 {
+			r.DecidingSetPoints = r.SetPoints
+		}
+		return r
+	}
+	if preset, ok := Preset(r.Name); ok {
+		preset.PointCap = r.PointCap
+		preset.SetTimeLimitMinutes = r.SetTimeLimitMinutes
+		if r.SideSwitchPoints != 0 || r.DecidingSideSwitchPoints != 0 || r.TechnicalTimeoutPoints != 0 {
+			preset.SideSwitchPoints = r.SideSwitchPoints
+			preset.DecidingSideSwitchPoints = r.DecidingSideSwitchPoints
+			preset.TechnicalTimeoutPoints = r.TechnicalTimeoutPoints
+		}
+		return preset
+	}
+	return r
+}
+
+// Validate reports whether the rules can decide a match.
+func (r Rules) Validate() error {
+	var problems []string
+	if r.Name != PresetCustom {
+		if _, ok := Preset(r.Name); !ok {
+			problems = append(problems, fmt.Sprintf("name must be one of %s or %s", strings.Join(Presets(), ", "), PresetCustom))
+		}
+	}
+	if r.BestOf < 1 || r.BestOf%2 == 0 {
+		problems = append(problems, "bestOf must be an odd number of at least 1")
+	}
+	if r.SetPoints < 1 {
+		problems = append(problems, "setPoints must be at least 1")
+	}
+	if r.DecidingSetPoints < 1 {
+		problems = append(problems, "decidingSetPoints must be at least 1")
+	}
+	if r.MinLead < 1 {
+		problems = append(problems, "minLead must be at least 1")
+	}
+	if r.PointCap != 0 && (r.PointCap < r.SetPoints || r.PointCap < r.DecidingSetPoints) {
+		problems = append(problems, "pointCap must be 0 or at least setPoints and decidingSetPoints")
+	}
+	if r.SetTimeLimitMinutes < 0 {
+		problems = append(problems, "setTimeLimitMinutes must not be negative")
+	}
+	if r.SideSwitchPoints < 0 || r.DecidingSideSwitchPoints < 0 || r.TechnicalTimeoutPoints < 0 {
+		problems = append(problems, "sideSwitchPoints, decidingSideSwitchPoints and technicalTimeoutPoints must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidRules, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SetsToWin is the number of sets that wins the match.
+func (r Rules) SetsToWin() int {
+	return r.BestOf/2 + 1
+}
+
+// PointsToWin is the score that wins the set with the given 0-based index.
+func (r Rules) PointsToWin(setIndex int) int {
+	if r.IsDecidingSet(setIndex) {
+		return r.DecidingSetPoints
+	}
+	return r.SetPoints
+}
+
+// IsDecidingSet reports whether the set with the given 0-based index is the
+// last possible set of a match of more than one set.
+func (r Rules) IsDecidingSet(setIndex int) bool {
+	return r.BestOf > 1 && setIndex == r.BestOf-1
+}
+
+// IsSideSwitch reports whether teams switch sides at the combined score of
+// the set with the given 0-based index.
+func (r Rules) IsSideSwitch(setIndex int, pointsPlayed int) bool {
+	every := r.SideSwitchPoints
+	if r.IsDecidingSet(setIndex) {
+		every = r.DecidingSideSwitchPoints
+	}
+	return every > 0 && pointsPlayed > 0 && pointsPlayed%every == 0
+}
+
+// IsTechnicalTimeout reports whether the combined score of the set with the
+// given 0-based index calls for a technical timeout.
+func (r Rules) IsTechnicalTimeout(setIndex int, pointsPlayed int) bool {
+	return r.TechnicalTimeoutPoints > 0 && !r.IsDecidingSet(setIndex) && pointsPlayed == r.TechnicalTimeoutPoints
+}
+
+// IsSetWon reports whether a set with the given 0-based index ended with a
+// valid final score.
+func (r Rules) IsSetWon(setIndex int, home, away int) bool {
+	if r.PointCap > 0 {
+		if home > r.PointCap || away > r.PointCap {
+			return false
+		}
+		if (home == r.PointCap) != (away == r.PointCap) {
+			return true
+		}
+	}
+	target := r.PointsToWin(setIndex)
+	homeWon := home >= target && home >= away+r.MinLead
+	awayWon := away >= target && away >= home+r.MinLead
+	return homeWon || awayWon
+}
+
+// IsSetWonOnTime reports whether a set ended because time ran out has a
+// valid final score: the rules allow it and one team is leading.
+func (r Rules) IsSetWonOnTime(home, away int) bool {
+	if r.SetTimeLimitMinutes == 0 || home == away {
+		return false
+	}
+	return r.PointCap == 0 || (home <= r.PointCap && away <= r.PointCap)
+}
@@ -0,0 +1,120 @@
+package scoring
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWithDefaults(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    Rules
+		expected Rules
+	}{
+		{name: "preset name", rules: Rules{Name: "Indoor"}, expected: presets[PresetIndoor]},
+		{name: "custom values", rules: Rules{BestOf: 1, SetPoints: 25, MinLead: 2}, expected: Rules{Name: PresetCustom, BestOf: 1, SetPoints: 25, DecidingSetPoints: 25, MinLead: 2}},
+		{name: "unknown preset", rules: Rules{Name: "snow"}, expected: Rules{Name: "snow"}},
+		{name: "preset with cap and time limit", rules: Rules{Name: PresetBeach, PointCap: 25, SetTimeLimitMinutes: 20}, expected: Rules{Name: PresetBeach, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, PointCap: 25, SetTimeLimitMinutes: 20, SideSwitchPoints: 7, DecidingSideSwitchPoints: 5, TechnicalTimeoutPoints: 21}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.rules.WithDefaults(); got != c.expected {
+				t.Fatalf("expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, name := range Presets() {
+		rules, _ := Preset(name)
+		if err := rules.Validate(); err != nil {
+			t.Errorf("expected preset %s to be valid, got %v", name, err)
+		}
+	}
+
+	invalid := []Rules{
+		{Name: "snow", BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2},
+		{Name: PresetCustom, BestOf: 2, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2},
+		{Name: PresetCustom, BestOf: 3, SetPoints: 0, DecidingSetPoints: 15, MinLead: 2},
+		{Name: PresetCustom, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 0},
+		{Name: PresetCustom, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, PointCap: 20},
+		{Name: PresetCustom, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, SetTimeLimitMinutes: -1},
+	}
+	for _, rules := range invalid {
+		if err := rules.Validate(); !errors.Is(err, ErrInvalidRules) {
+			t.Errorf("expected %+v to be invalid, got %v", rules, err)
+		}
+	}
+}
+
+func TestIsSetWon(t *testing.T) {
+	capped := Beach()
+	capped.PointCap = 25
+
+	cases := []struct {
+		rules      Rules
+		setIndex   int
+		home, away int
+		expected   bool
+	}{
+		{rules: Beach(), setIndex: 0, home: 21, away: 19, expected: true},
+		{rules: Beach(), setIndex: 0, home: 21, away: 20},
+		{rules: Beach(), setIndex: 1, home: 28, away: 30, expected: true},
+		{rules: Beach(), setIndex: 1, home: 15, away: 13},
+		{rules: Beach(), setIndex: 2, home: 15, away: 13, expected: true},
+		{rules: Beach(), setIndex: 2, home: 14, away: 12},
+		{rules: capped, setIndex: 0, home: 24, away: 25, expected: true},
+		{rules: capped, setIndex: 0, home: 23, away: 21, expected: true},
+		{rules: capped, setIndex: 0, home: 26, away: 24},
+		{rules: capped, setIndex: 2, home: 15, away: 13, expected: true},
+	}
+
+	for _, c := range cases {
+		if got := c.rules.IsSetWon(c.setIndex, c.home, c.away); got != c.expected {
+			t.Errorf("%+v set %d %d-%d: expected won=%v, got %v", c.rules, c.setIndex+1, c.home, c.away, c.expected, got)
+		}
+	}
+}
+
+func TestSideSwitchesAndTechnicalTimeouts(t *testing.T) {
+	beach := Beach()
+	cases := []struct {
+		setIndex     int
+		pointsPlayed int
+		sideSwitch   bool
+		timeout      bool
+	}{
+		{setIndex: 0, pointsPlayed: 7, sideSwitch: true},
+		{setIndex: 0, pointsPlayed: 10},
+		{setIndex: 1, pointsPlayed: 21, sideSwitch: true, timeout: true},
+		{setIndex: 2, pointsPlayed: 5, sideSwitch: true},
+		{setIndex: 2, pointsPlayed: 7},
+		{setIndex: 2, pointsPlayed: 21},
+	}
+
+	for _, c := range cases {
+		if got := beach.IsSideSwitch(c.setIndex, c.pointsPlayed); got != c.sideSwitch {
+			t.Errorf("set %d at %d points: expected side switch=%v, got %v", c.setIndex+1, c.pointsPlayed, c.sideSwitch, got)
+		}
+		if got := beach.IsTechnicalTimeout(c.setIndex, c.pointsPlayed); got != c.timeout {
+			t.Errorf("set %d at %d points: expected technical timeout=%v, got %v", c.setIndex+1, c.pointsPlayed, c.timeout, got)
+		}
+	}
+}
+
+func TestIsSetWonOnTime(t *testing.T) {
+	timed := Beach()
+	timed.SetTimeLimitMinutes = 20
+
+	if !timed.IsSetWonOnTime(12, 10) {
+		t.Error("expected a leading team to win a set ended on time")
+	}
+	if timed.IsSetWonOnTime(12, 12) {
+		t.Error("expected a tied set not to end on time")
+	}
+	if Beach().IsSetWonOnTime(12, 10) {
+		t.Error("expected sets without a time limit not to end on time")
+	}
+}
@@ -0,0 +1,239 @@
+package profixio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"golang.org/x/time/rate"
+)
+
+// Defaults for the limits on how hard Profixio and storage are hit.
+const (
+	defaultPageWorkers  = 4
+	defaultWriteWorkers = 16
+	defaultRateLimit    = rate.Limit(5)
+	defaultRateBurst    = 5
+	defaultMaxRetries   = 4
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// TournamentSource is the read side of the Profixio integration: it pulls
+// tournaments and matches from Profixio and stores them.
+type TournamentSource interface {
+	Sources() []Source
+	FetchTournaments(ctx context.Context, pageId int, sourceIDs ...string) (SyncStats, error)
+	FetchMatches(ctx context.Context, pageId int, slug string, lastSync string, timeNow string) (SyncStats, error)
+	FetchMatch(ctx context.Context, tournamentSlug string, matchNumber string, tournamentID int, matchID int) error
+}
+
+// ResultSink is the write side of the Profixio integration: it reports
+// match results back to Profixio.
+type ResultSink interface {
+	PostResult(ctx context.Context, matchID string, tournamentID string, result MatchResult) error
+}
+
+var (
+	_ TournamentSource = (*Service)(nil)
+	_ ResultSink       = (*Service)(nil)
+)
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithHTTPClient sets the HTTP client used for all Profixio calls. Tests use it
+// to inject a custom transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(s *Service) {
+		s.httpClient = httpClient
+	}
+}
+
+// WithBaseURL overrides the Profixio API base URL, which defaults to
+// https://<profixioHost>/app/api.
+func WithBaseURL(baseURL string) Option {
+	return func(s *Service) {
+		s.baseURL = baseURL
+	}
+}
+
+// WithAPIKey sets the API secret sent to Profixio.
+func WithAPIKey(apiKey string) Option {
+	return func(s *Service) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithPageWorkers sets how many Profixio pages are fetched at once within a
+// single sync.
+func WithPageWorkers(workers int) Option {
+	return func(s *Service) {
+		s.pageWorkers = workers
+	}
+}
+
+// WithWriteWorkers sets how many tournaments or match pages are written to
+// storage at once across all syncs.
+func WithWriteWorkers(workers int) Option {
+	return func(s *Service) {
+		s.writeWorkers = workers
+	}
+}
+
+// MatchChangeHandler is called with the matches a sync created or changed.
+type MatchChangeHandler func(ctx context.Context, slug string, diffs []MatchDiff)
+
+// WithMatchChangeHandler sets the handler notified of changed matches, for
+// example to push updates to scoreboards.
+func WithMatchChangeHandler(handler MatchChangeHandler) Option {
+	return func(s *Service) {
+		s.onMatchChanges = handler
+	}
+}
+
+// WithSources sets the organisations and sports whose tournaments are synced.
+func WithSources(sources ...Source) Option {
+	return func(s *Service) {
+		s.sources = sources
+	}
+}
+
+// WithRateLimit sets the token bucket every Profixio request waits on.
+func WithRateLimit(limit rate.Limit, burst int) Option {
+	return func(s *Service) {
+		s.limiter = rate.NewLimiter(limit, burst)
+	}
+}
+
+// WithRetry sets how often a request answered with 429 or 503 is retried, and
+// the first backoff, which doubles on every attempt. A Retry-After header
+// takes precedence over the backoff.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(s *Service) {
+		s.maxRetries = maxRetries
+		s.retryBackoff = backoff
+	}
+}
+
+func defaultBaseURL(profixioHost string) string {
+	return fmt.Sprintf("https://%s/app/api", profixioHost)
+}
+
+func (s Service) tournamentsPath(source Source, pageId int) string {
+	return fmt.Sprintf("/organisations/%s/tournaments?limit=5&sportId=%s&page=%d", url.PathEscape(source.Organisation), url.QueryEscape(source.Sport), pageId)
+}
+
+func (s Service) matchesPath(tournamentID int, pageId int, lastSync string) string {
+	if lastSync != "" {
+		return fmt.Sprintf("/tournaments/%d/matches?limit=150&page=%d&updated=%s", tournamentID, pageId, url.QueryEscape(lastSync))
+	}
+	return fmt.Sprintf("/tournaments/%d/matches?limit=150&page=%d", tournamentID, pageId)
+}
+
+func (s Service) matchPath(tournamentID string, matchID string) string {
+	return fmt.Sprintf("/tournaments/%s/matches/%s", tournamentID, matchID)
+}
+
+// do sends a request to the Profixio API with the API secret attached. Every
+// attempt waits on the rate limiter, and 429 and 503 responses are retried
+// with backoff.
+func (s Service) do(ctx context.Context, method string, path string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("x-api-secret", s.apiKey)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		response, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !retryable(response.StatusCode) || attempt >= s.maxRetries {
+			return response, nil
+		}
+
+		delay := retryDelay(response.Header.Get("Retry-After"), s.retryBackoff, attempt, time.Now())
+		response.Body.Close()
+		log.Printf("profixio request throttled method=%s path=%s status=%d attempt=%d delay=%s", method, path, response.StatusCode, attempt+1, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// retryDelay honours a Retry-After header in seconds or as an HTTP date, and
+// otherwise backs off exponentially from backoff.
+func retryDelay(retryAfter string, backoff time.Duration, attempt int, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxRetryBackoff)
+	}
+	if at, err := http.ParseTime(retryAfter); err == nil {
+		return min(max(at.Sub(now), 0), maxRetryBackoff)
+	}
+	return min(backoff<<attempt, maxRetryBackoff)
+}
+
+// getJSON fetches path and decodes the response body into out. Errors wrap
+// ErrUpstreamUnavailable or ErrDecode.
+func (s Service) getJSON(ctx context.Context, path string, out any) error {
+	response, err := s.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return fmt.Errorf("%w: request %s: %v", ErrUpstreamUnavailable, path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%w: request %s: status %d", ErrUpstreamUnavailable, path, response.StatusCode)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: decode %s (status %d): %v", ErrDecode, path, response.StatusCode, err)
+	}
+	return nil
+}
+
+func (s Service) getTournamentPage(ctx context.Context, source Source, pageId int) (*TournamentResponse, error) {
+	var apiResponse TournamentResponse
+	if err := s.getJSON(ctx, s.tournamentsPath(source, pageId), &apiResponse); err != nil {
+		return nil, err
+	}
+	return &apiResponse, nil
+}
+
+func (s Service) getMatchesPage(ctx context.Context, tournamentID int, pageId int, lastSync string) (*MatchResponse, error) {
+	var apiResponse MatchResponse
+	if err := s.getJSON(ctx, s.matchesPath(tournamentID, pageId, lastSync), &apiResponse); err != nil {
+		return nil, err
+	}
+	return &apiResponse, nil
+}
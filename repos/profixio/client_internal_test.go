@@ -0,0 +1,34 @@
+package profixio
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		retryAfter string
+		attempt    int
+		expected   time.Duration
+	}{
+		{name: "seconds", retryAfter: "3", expected: 3 * time.Second},
+		{name: "http date", retryAfter: now.Add(5 * time.Second).Format(http.TimeFormat), expected: 5 * time.Second},
+		{name: "http date in the past", retryAfter: now.Add(-5 * time.Second).Format(http.TimeFormat), expected: 0},
+		{name: "first backoff", expected: 500 * time.Millisecond},
+		{name: "third backoff", attempt: 2, expected: 2 * time.Second},
+		{name: "backoff is capped", attempt: 10, expected: maxRetryBackoff},
+		{name: "retry-after is capped", retryAfter: "3600", expected: maxRetryBackoff},
+		{name: "invalid retry-after", retryAfter: "soon", attempt: 1, expected: time.Second},
+	}
+
+	for _, c := range cases {
+		got := retryDelay(c.retryAfter, 500*time.Millisecond, c.attempt, now)
+		if got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package profixio_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/xorcare/pointer"
+)
+
+func TestPostResult(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	service := profixio.NewService(nil, "", server.Options()...)
+
+	result := profixio.MatchResult{
+		Sets: []profixio.Result{
+			{Home: 21, Away: 17},
+			{Home: 21, Away: 19},
+		},
+		Result: profixio.Result{Home: 2, Away: 0},
+	}
+
+	if err := service.PostResult(context.Background(), "77", "2405", result); err != nil {
+		t.Fatalf("expected result to be posted, got %v", err)
+	}
+
+	results := server.Results()
+	if len(results) != 1 {
+		t.Fatalf("expected 1 posted result, got %d", len(results))
+	}
+	if results[0].TournamentID != "2405" || results[0].MatchID != "77" {
+		t.Fatalf("expected result for tournament 2405 match 77, got %+v", results[0])
+	}
+	if results[0].Result.Result != result.Result || len(results[0].Result.Sets) != 2 {
+		t.Fatalf("expected posted result %+v, got %+v", result, results[0].Result)
+	}
+}
+
+func TestPostResultRejected(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		options func(server *profixiotest.Server) []profixio.Option
+	}{
+		{
+			name:    "conflict",
+			status:  http.StatusConflict,
+			options: func(server *profixiotest.Server) []profixio.Option { return server.Options() },
+		},
+		{
+			name:   "wrong api key",
+			status: http.StatusNoContent,
+			options: func(server *profixiotest.Server) []profixio.Option {
+				return append(server.Options(), profixio.WithAPIKey("wrong"))
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := profixiotest.NewServer(t)
+			server.SetResultStatus(c.status)
+			service := profixio.NewService(nil, "", c.options(server)...)
+
+			err := service.PostResult(context.Background(), "77", "2405", profixio.MatchResult{})
+			if !errors.Is(err, profixio.ErrAlreadyRegistered) {
+				t.Fatalf("expected %v, got %v", profixio.ErrAlreadyRegistered, err)
+			}
+		})
+	}
+}
+
+func TestFetchMatchMissingInProfixio(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	service := profixio.NewService(nil, "", server.Options()...)
+
+	if err := service.FetchMatch(context.Background(), "beach-cup", "12", 2405, 77); err != nil {
+		t.Fatalf("expected missing match to be ignored, got %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0] != "GET /app/api/tournaments/2405/matches/77" {
+		t.Fatalf("expected a single match lookup, got %v", requests)
+	}
+}
+
+func matchesPage(lastPage int, matches ...profixio.Match) profixio.MatchResponse {
+	response := profixio.MatchResponse{Data: matches}
+	response.Meta.LastPage = lastPage
+	return response
+}
+
+func seedTournament(store *storage.Memory) {
+	store.SeedTournament(storage.Tournament{ID: 2405, Slug: "beach-cup", LastSynced: "2024-06-01 10:00:00"})
+}
+
+func TestFetchMatches(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	server.AddMatchesPage(2405, 1, matchesPage(2, profixio.Match{ID: pointer.Int64(1), Number: pointer.String("1")}))
+	server.AddMatchesPage(2405, 2, matchesPage(2, profixio.Match{ID: pointer.Int64(2), Number: pointer.String("2")}))
+	store := storage.NewMemory()
+	seedTournament(store)
+	service := profixio.NewService(store, "", server.Options()...)
+
+	stats, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats != (profixio.SyncStats{PagesFetched: 2, MatchesCreated: 2}) {
+		t.Fatalf("expected 2 pages and 2 created matches, got %+v", stats)
+	}
+
+	tournament, _ := store.GetTournament(context.Background(), "beach-cup")
+	if tournament.NumberOfMatches != 2 || tournament.LastSynced != "2024-06-02 10:00:00" {
+		t.Fatalf("expected 2 matches synced at 2024-06-02 10:00:00, got %+v", tournament)
+	}
+}
+
+func TestFetchMatchesIncremental(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	server.AddMatchesPage(2405, 1, matchesPage(1,
+		profixio.Match{ID: pointer.Int64(1), Number: pointer.String("1")},
+		profixio.Match{ID: pointer.Int64(2), Number: pointer.String("2")},
+	))
+	store := storage.NewMemory()
+	seedTournament(store)
+	service := profixio.NewService(store, "", server.Options()...)
+
+	if _, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	server.AddMatchesPage(2405, 1, matchesPage(1,
+		profixio.Match{ID: pointer.Int64(2), Number: pointer.String("2"), Name: pointer.String("Final")},
+		profixio.Match{ID: pointer.Int64(3), Number: pointer.String("3")},
+	))
+
+	stats, err := service.FetchMatches(context.Background(), 1, "beach-cup", "2024-06-02 10:00:00", "2024-06-02 10:05:00")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats != (profixio.SyncStats{PagesFetched: 1, MatchesCreated: 1, MatchesUpdated: 1}) {
+		t.Fatalf("expected 1 created and 1 updated match, got %+v", stats)
+	}
+
+	tournament, _ := store.GetTournament(context.Background(), "beach-cup")
+	if tournament.NumberOfMatches != 3 {
+		t.Fatalf("expected 3 matches, got %d", tournament.NumberOfMatches)
+	}
+}
+
+func TestFetchMatchesSkipsUnchangedMatches(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	server.AddMatchesPage(2405, 1, matchesPage(1,
+		profixio.Match{ID: pointer.Int64(1), Number: pointer.String("1"), Time: pointer.String("10:00:00")},
+		profixio.Match{ID: pointer.Int64(2), Number: pointer.String("2"), Time: pointer.String("11:00:00")},
+	))
+	store := storage.NewMemory()
+	seedTournament(store)
+
+	var changes []profixio.MatchDiff
+	service := profixio.NewService(store, "", append(server.Options(),
+		profixio.WithMatchChangeHandler(func(_ context.Context, slug string, diffs []profixio.MatchDiff) {
+			changes = append(changes, diffs...)
+		}),
+	)...)
+
+	if _, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	server.AddMatchesPage(2405, 1, matchesPage(1,
+		profixio.Match{ID: pointer.Int64(1), Number: pointer.String("1"), Time: pointer.String("10:00:00")},
+		profixio.Match{ID: pointer.Int64(2), Number: pointer.String("2"), Time: pointer.String("11:30:00")},
+	))
+	changes = nil
+
+	stats, err := service.FetchMatches(context.Background(), 1, "beach-cup", "2024-06-02 10:00:00", "2024-06-02 10:05:00")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats != (profixio.SyncStats{PagesFetched: 1, MatchesUpdated: 1, MatchesUnchanged: 1}) {
+		t.Fatalf("expected 1 updated and 1 unchanged match, got %+v", stats)
+	}
+	if writes := store.MatchWrites(); writes != 3 {
+		t.Fatalf("expected 3 match writes, got %d", writes)
+	}
+
+	expected := []profixio.MatchDiff{{Number: "2", Fields: []string{profixio.MatchFieldTime}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected changes %+v, got %+v", expected, changes)
+	}
+}
+
+func TestFetchMatchesFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		setup    func(server *profixiotest.Server)
+		options  []profixio.Option
+		expected error
+	}{
+		{
+			name: "html page",
+			setup: func(server *profixiotest.Server) {
+				server.AddMatchesPage(2405, 1, matchesPage(2))
+				server.AddRawMatchesPage(2405, 2, []byte("<html>Service Unavailable</html>"))
+			},
+			expected: profixio.ErrDecode,
+		},
+		{
+			name:     "unauthorized",
+			setup:    func(server *profixiotest.Server) {},
+			options:  []profixio.Option{profixio.WithAPIKey("wrong")},
+			expected: profixio.ErrUpstreamUnavailable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := profixiotest.NewServer(t)
+			c.setup(server)
+			store := storage.NewMemory()
+			seedTournament(store)
+			service := profixio.NewService(store, "", append(server.Options(), c.options...)...)
+
+			_, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00")
+			if !errors.Is(err, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, err)
+			}
+
+			tournament, _ := store.GetTournament(context.Background(), "beach-cup")
+			if tournament.LastSynced != "2024-06-01 10:00:00" {
+				t.Fatalf("expected LastSynced to be kept, got %s", tournament.LastSynced)
+			}
+		})
+	}
+}
+
+// lastSyncedStore fails to save LastSynced.
+type lastSyncedStore struct {
+	*storage.Memory
+}
+
+func (s lastSyncedStore) SetLastSynced(context.Context, string, string) error {
+	return errors.New("unavailable")
+}
+
+func TestFetchMatchesLastSyncedFailure(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	server.AddMatchesPage(2405, 1, matchesPage(1))
+	store := storage.NewMemory()
+	seedTournament(store)
+	service := profixio.NewService(lastSyncedStore{store}, "", server.Options()...)
+
+	_, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00")
+	if !errors.Is(err, profixio.ErrStorage) {
+		t.Fatalf("expected %v, got %v", profixio.ErrStorage, err)
+	}
+}
+
+func TestFetchMatchesUnknownTournament(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	service := profixio.NewService(storage.NewMemory(), "", server.Options()...)
+
+	_, err := service.FetchMatches(context.Background(), 1, "missing", "", "2024-06-02 10:00:00")
+	if !errors.Is(err, profixio.ErrStorage) {
+		t.Fatalf("expected %v, got %v", profixio.ErrStorage, err)
+	}
+}
+
+func TestFetchMatchesRetriesThrottledRequests(t *testing.T) {
+	cases := []struct {
+		name       string
+		throttles  int
+		status     int
+		retryAfter string
+		maxRetries int
+		expected   error
+	}{
+		{name: "429 with retry-after", throttles: 2, status: http.StatusTooManyRequests, retryAfter: "0", maxRetries: 3},
+		{name: "503 with backoff", throttles: 2, status: http.StatusServiceUnavailable, maxRetries: 3},
+		{name: "retries exhausted", throttles: 3, status: http.StatusTooManyRequests, retryAfter: "0", maxRetries: 2, expected: profixio.ErrUpstreamUnavailable},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := profixiotest.NewServer(t)
+			server.AddMatchesPage(2405, 1, matchesPage(1, profixio.Match{ID: pointer.Int64(1), Number: pointer.String("1")}))
+			server.Throttle(c.throttles, c.status, c.retryAfter)
+			store := storage.NewMemory()
+			seedTournament(store)
+			service := profixio.NewService(store, "", append(server.Options(), profixio.WithRetry(c.maxRetries, time.Millisecond))...)
+
+			_, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00")
+			if !errors.Is(err, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, err)
+			}
+
+			expectedRequests := min(c.throttles, c.maxRetries) + 1
+			if got := len(server.Requests()); got != expectedRequests {
+				t.Fatalf("expected %d requests, got %d", expectedRequests, got)
+			}
+		})
+	}
+}
+
+func TestFetchMatchesBoundsConcurrentPages(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	for page := 1; page <= 8; page++ {
+		server.AddMatchesPage(2405, page, matchesPage(8, profixio.Match{ID: pointer.Int64(int64(page)), Number: pointer.String(strconv.Itoa(page))}))
+	}
+	store := storage.NewMemory()
+	seedTournament(store)
+	service := profixio.NewService(store, "", append(server.Options(), profixio.WithPageWorkers(2), profixio.WithWriteWorkers(1))...)
+
+	stats, err := service.FetchMatches(context.Background(), 1, "beach-cup", "", "2024-06-02 10:00:00")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats.PagesFetched != 8 || stats.MatchesCreated != 8 {
+		t.Fatalf("expected 8 pages and 8 created matches, got %+v", stats)
+	}
+	if got := server.MaxInFlight(); got > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", got)
+	}
+}
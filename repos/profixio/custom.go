@@ -0,0 +1,134 @@
+package profixio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/xorcare/pointer"
+)
+
+// ErrInvalidCustomTournament is returned when a custom tournament upload
+// fails validation. The error is a *ValidationError listing the problems.
+var ErrInvalidCustomTournament = errors.New("invalid custom tournament")
+
+// MatchError is a problem with one match of a custom tournament upload.
+type MatchError struct {
+	// Index is the position of the match in the upload.
+	Index   int    `json:"index"`
+	Number  string `json:"number,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a custom tournament upload.
+type ValidationError struct {
+	Errors []MatchError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, matchError := range e.Errors {
+		messages = append(messages, fmt.Sprintf("match %d: %s: %s", matchError.Index, matchError.Field, matchError.Message))
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidCustomTournament, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidCustomTournament
+}
+
+// ProcessCustomTournament validates the upload and stores its matches. Every
+// problem is returned at once in a *ValidationError and nothing is stored.
+// Storing the same upload again changes nothing.
+func (s Service) ProcessCustomTournament(ctx context.Context, slug string, customTournament CustomTournament) (SyncStats, error) {
+	if err := ValidateCustomTournament(slug, customTournament); err != nil {
+		log.Printf("process custom tournament invalid slug=%s err=%v", slug, err)
+		return SyncStats{}, err
+	}
+
+	matches := make([]Match, 0, len(*customTournament.Matches))
+	for _, match := range *customTournament.Matches {
+		match.Number = pointer.String(strings.TrimSpace(*match.Number))
+		matches = append(matches, match)
+	}
+	log.Printf("process custom tournament start slug=%s matches=%d", slug, len(matches))
+
+	stats, err := s.processMatchList(ctx, slug, matches)
+	if err != nil {
+		log.Printf("process custom tournament failed slug=%s err=%v", slug, err)
+		return stats, err
+	}
+	log.Printf("process custom tournament done slug=%s created=%d updated=%d unchanged=%d", slug, stats.MatchesCreated, stats.MatchesUpdated, stats.MatchesUnchanged)
+	return stats, nil
+}
+
+// ValidateCustomTournament checks that every match has a unique number, both
+// teams and, when set, a YYYY-MM-DD date and a HH:MM or HH:MM:SS time.
+func ValidateCustomTournament(slug string, customTournament CustomTournament) error {
+	var errs []MatchError
+	if customTournament.Slug != nil && *customTournament.Slug != slug {
+		errs = append(errs, MatchError{Index: -1, Field: "slug", Message: fmt.Sprintf("does not match %s", slug)})
+	}
+	if customTournament.Matches == nil {
+		errs = append(errs, MatchError{Index: -1, Field: "matches", Message: "is required"})
+		return &ValidationError{Errors: errs}
+	}
+
+	seen := map[string]int{}
+	for i, match := range *customTournament.Matches {
+		number := ""
+		if match.Number != nil {
+			number = strings.TrimSpace(*match.Number)
+		}
+		fail := func(field string, message string) {
+			errs = append(errs, MatchError{Index: i, Number: number, Field: field, Message: message})
+		}
+
+		switch first, duplicate := seen[number]; {
+		case number == "":
+			fail("number", "is required")
+		case strings.Contains(number, "/"):
+			fail("number", "must not contain /")
+		case duplicate:
+			fail("number", fmt.Sprintf("duplicates match %d", first))
+		default:
+			seen[number] = i
+		}
+
+		if match.HomeTeam == nil || strings.TrimSpace(match.HomeTeam.Name) == "" {
+			fail("homeTeam", "is required")
+		}
+		if match.AwayTeam == nil || strings.TrimSpace(match.AwayTeam.Name) == "" {
+			fail("awayTeam", "is required")
+		}
+		if match.Date != nil && !validDate(*match.Date) {
+			fail("date", fmt.Sprintf("%q is not YYYY-MM-DD", *match.Date))
+		}
+		if match.Time != nil && !validTime(*match.Time) {
+			fail("time", fmt.Sprintf("%q is not HH:MM or HH:MM:SS", *match.Time))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func validDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+func validTime(value string) bool {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
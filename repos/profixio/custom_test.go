@@ -0,0 +1,85 @@
+package profixio_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/xorcare/pointer"
+)
+
+func customMatch(number string) profixio.Match {
+	return profixio.Match{
+		Number:   pointer.String(number),
+		Date:     pointer.String("2024-06-18"),
+		Time:     pointer.String("09:30"),
+		HomeTeam: &profixio.Team{Name: "Home"},
+		AwayTeam: &profixio.Team{Name: "Away"},
+	}
+}
+
+func TestValidateCustomTournament(t *testing.T) {
+	missingTeam := customMatch("3")
+	missingTeam.AwayTeam = nil
+	badDate := customMatch("4")
+	badDate.Date = pointer.String("18.06.2024")
+	badTime := customMatch("5")
+	badTime.Time = pointer.String("9.30")
+
+	cases := []struct {
+		name       string
+		tournament profixio.CustomTournament
+		expected   []profixio.MatchError
+	}{
+		{
+			name:       "valid",
+			tournament: profixio.CustomTournament{Matches: &[]profixio.Match{customMatch("1"), customMatch("2")}},
+		},
+		{
+			name:       "no matches",
+			tournament: profixio.CustomTournament{},
+			expected:   []profixio.MatchError{{Index: -1, Field: "matches", Message: "is required"}},
+		},
+		{
+			name:       "wrong slug",
+			tournament: profixio.CustomTournament{Slug: pointer.String("other"), Matches: &[]profixio.Match{}},
+			expected:   []profixio.MatchError{{Index: -1, Field: "slug", Message: "does not match cup"}},
+		},
+		{
+			name:       "duplicate number",
+			tournament: profixio.CustomTournament{Matches: &[]profixio.Match{customMatch("1"), customMatch(" 1 ")}},
+			expected:   []profixio.MatchError{{Index: 1, Number: "1", Field: "number", Message: "duplicates match 0"}},
+		},
+		{
+			name:       "bad fields",
+			tournament: profixio.CustomTournament{Matches: &[]profixio.Match{customMatch(""), missingTeam, badDate, badTime}},
+			expected: []profixio.MatchError{
+				{Index: 0, Field: "number", Message: "is required"},
+				{Index: 1, Number: "3", Field: "awayTeam", Message: "is required"},
+				{Index: 2, Number: "4", Field: "date", Message: `"18.06.2024" is not YYYY-MM-DD`},
+				{Index: 3, Number: "5", Field: "time", Message: `"9.30" is not HH:MM or HH:MM:SS`},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := profixio.ValidateCustomTournament("cup", c.tournament)
+			if c.expected == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *profixio.ValidationError
+			if !errors.As(err, &validationErr) || !errors.Is(err, profixio.ErrInvalidCustomTournament) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if !reflect.DeepEqual(validationErr.Errors, c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, validationErr.Errors)
+			}
+		})
+	}
+}
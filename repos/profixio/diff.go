@@ -0,0 +1,77 @@
+package profixio
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Groups of match fields reported in a MatchDiff. Fields outside these
+// groups are reported by their JSON name.
+const (
+	MatchFieldTime       = "time"
+	MatchFieldField      = "field"
+	MatchFieldTeams      = "teams"
+	MatchFieldSets       = "sets"
+	MatchFieldTimestamps = "timestamps"
+)
+
+var matchFieldGroups = map[string]string{
+	"Date":                 MatchFieldTime,
+	"Time":                 MatchFieldTime,
+	"Field":                MatchFieldField,
+	"HomeTeam":             MatchFieldTeams,
+	"AwayTeam":             MatchFieldTeams,
+	"Sets":                 MatchFieldSets,
+	"SettResultsFormatted": MatchFieldSets,
+	"HasWinner":            MatchFieldSets,
+	"WinnerTeam":           MatchFieldSets,
+	"MatchDataUpdated":     MatchFieldTimestamps,
+	"ResultsUpdated":       MatchFieldTimestamps,
+}
+
+// MatchDiff describes what storing a match from Profixio changed.
+type MatchDiff struct {
+	Number string
+	// Created is set when the match was not stored before.
+	Created bool
+	// Fields lists the changed field groups of an existing match, such as
+	// MatchFieldTime or MatchFieldSets, in declaration order.
+	Fields []string
+}
+
+// Unchanged reports whether storing the match was a no-op.
+func (d MatchDiff) Unchanged() bool {
+	return !d.Created && len(d.Fields) == 0
+}
+
+// DiffMatch compares an incoming match with the stored one. Like a match
+// update, only the fields set on incoming are compared.
+func DiffMatch(stored Match, incoming Match) MatchDiff {
+	diff := MatchDiff{}
+	if incoming.Number != nil {
+		diff.Number = *incoming.Number
+	}
+
+	storedValue := reflect.ValueOf(stored)
+	incomingValue := reflect.ValueOf(incoming)
+	matchType := incomingValue.Type()
+
+	seen := map[string]bool{}
+	for i := 0; i < incomingValue.NumField(); i++ {
+		field := incomingValue.Field(i)
+		if field.IsNil() || reflect.DeepEqual(field.Interface(), storedValue.Field(i).Interface()) {
+			continue
+		}
+
+		structField := matchType.Field(i)
+		group, ok := matchFieldGroups[structField.Name]
+		if !ok {
+			group, _, _ = strings.Cut(structField.Tag.Get("json"), ",")
+		}
+		if !seen[group] {
+			seen[group] = true
+			diff.Fields = append(diff.Fields, group)
+		}
+	}
+	return diff
+}
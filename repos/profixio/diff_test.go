@@ -0,0 +1,71 @@
+package profixio_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/xorcare/pointer"
+)
+
+func TestDiffMatch(t *testing.T) {
+	stored := profixio.Match{
+		Number:   pointer.String("12"),
+		Date:     pointer.String("2024-06-01"),
+		Time:     pointer.String("10:00:00"),
+		HomeTeam: &profixio.Team{Name: "Home"},
+		AwayTeam: &profixio.Team{Name: "Away"},
+		Field:    &profixio.Field{Name: pointer.String("Bane 1")},
+		Sets:     &[]profixio.Set{{Number: pointer.Int(1), PointsHomeTeam: pointer.Int(21), PointsAwayTeam: pointer.Int(18)}},
+	}
+
+	cases := []struct {
+		name     string
+		incoming profixio.Match
+		expected []string
+	}{
+		{
+			name:     "same values",
+			incoming: stored,
+		},
+		{
+			name:     "unset fields are ignored",
+			incoming: profixio.Match{Number: pointer.String("12")},
+		},
+		{
+			name:     "new time",
+			incoming: profixio.Match{Number: pointer.String("12"), Time: pointer.String("11:00:00")},
+			expected: []string{profixio.MatchFieldTime},
+		},
+		{
+			name: "new field, team and sets",
+			incoming: profixio.Match{
+				Number:   pointer.String("12"),
+				AwayTeam: &profixio.Team{Name: "Other"},
+				Field:    &profixio.Field{Name: pointer.String("Bane 2")},
+				Sets:     &[]profixio.Set{{Number: pointer.Int(1), PointsHomeTeam: pointer.Int(21), PointsAwayTeam: pointer.Int(19)}},
+			},
+			expected: []string{profixio.MatchFieldTeams, profixio.MatchFieldField, profixio.MatchFieldSets},
+		},
+		{
+			name:     "ungrouped field",
+			incoming: profixio.Match{Number: pointer.String("12"), Name: pointer.String("Final")},
+			expected: []string{"name"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diff := profixio.DiffMatch(stored, c.incoming)
+			if diff.Number != "12" || diff.Created {
+				t.Fatalf("expected an update of match 12, got %+v", diff)
+			}
+			if !reflect.DeepEqual(diff.Fields, c.expected) {
+				t.Fatalf("expected fields %v, got %v", c.expected, diff.Fields)
+			}
+			if diff.Unchanged() != (len(c.expected) == 0) {
+				t.Fatalf("expected unchanged to be %v", len(c.expected) == 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Package profixiotest provides an in-memory Profixio API for tests. It
+// replays recorded tournament and match pages and captures posted results.
+package profixiotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/nvbf/tournament-sync/repos/profixio"
+)
+
+// APIKey is the secret the fake server expects in the x-api-secret header.
+const APIKey = "profixiotest-key"
+
+// PostedResult is a match result received by the fake server.
+type PostedResult struct {
+	TournamentID string
+	MatchID      string
+	Result       profixio.MatchResult
+}
+
+// Server is a fake Profixio API backed by httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	tournamentPages map[string]map[int][]byte
+	matchPages      map[int]map[int][]byte
+	matches         map[int]map[string][]byte
+	results         []PostedResult
+	resultStatus    int
+	requests        []string
+	throttled       []throttle
+	inFlight        int
+	maxInFlight     int
+}
+
+type throttle struct {
+	status     int
+	retryAfter string
+}
+
+// NewServer starts a fake Profixio API that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		tournamentPages: map[string]map[int][]byte{},
+		matchPages:      map[int]map[int][]byte{},
+		matches:         map[int]map[string][]byte{},
+		resultStatus:    http.StatusNoContent,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /app/api/organisations/{organisation}/tournaments", s.tournamentsHandler)
+	mux.HandleFunc("GET /app/api/tournaments/{tournament}/matches", s.matchesHandler)
+	mux.HandleFunc("GET /app/api/tournaments/{tournament}/matches/{match}", s.matchHandler)
+	mux.HandleFunc("PUT /app/api/tournaments/{tournament}/matches/{match}", s.resultHandler)
+
+	s.Server = httptest.NewServer(s.authorize(mux))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// BaseURL is the value to pass to profixio.WithBaseURL.
+func (s *Server) BaseURL() string {
+	return s.URL + "/app/api"
+}
+
+// Options returns the profixio options that point a Service at this server.
+func (s *Server) Options() []profixio.Option {
+	return []profixio.Option{
+		profixio.WithBaseURL(s.BaseURL()),
+		profixio.WithAPIKey(APIKey),
+		profixio.WithHTTPClient(s.Client()),
+	}
+}
+
+// AddTournamentPage records the response for a page of a source's tournament
+// listing.
+func (s *Server) AddTournamentPage(source profixio.Source, page int, response profixio.TournamentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tournamentPages[source.ID()] == nil {
+		s.tournamentPages[source.ID()] = map[int][]byte{}
+	}
+	s.tournamentPages[source.ID()][page] = mustMarshal(response)
+}
+
+// AddMatchesPage records the response for a page of a tournament's matches.
+func (s *Server) AddMatchesPage(tournamentID int, page int, response profixio.MatchResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.matchPages[tournamentID] == nil {
+		s.matchPages[tournamentID] = map[int][]byte{}
+	}
+	s.matchPages[tournamentID][page] = mustMarshal(response)
+}
+
+// AddRawMatchesPage records a raw response body for a page of a tournament's
+// matches, for example an HTML error page.
+func (s *Server) AddRawMatchesPage(tournamentID int, page int, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.matchPages[tournamentID] == nil {
+		s.matchPages[tournamentID] = map[int][]byte{}
+	}
+	s.matchPages[tournamentID][page] = body
+}
+
+// AddMatch records a single match returned from the match detail endpoint.
+func (s *Server) AddMatch(tournamentID int, match profixio.Match) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.matches[tournamentID] == nil {
+		s.matches[tournamentID] = map[string][]byte{}
+	}
+	var matchID string
+	if match.ID != nil {
+		matchID = strconv.FormatInt(*match.ID, 10)
+	}
+	s.matches[tournamentID][matchID] = mustMarshal(profixio.SingleMatchResponse{Data: match})
+}
+
+// SetResultStatus sets the status code returned when a result is posted.
+func (s *Server) SetResultStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resultStatus = status
+}
+
+// Results returns the results posted so far.
+func (s *Server) Results() []PostedResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PostedResult(nil), s.results...)
+}
+
+// Throttle makes the next n requests fail with status, such as 429 or 503,
+// and the given Retry-After header if it is not empty.
+func (s *Server) Throttle(n int, status int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.throttled = append(s.throttled, throttle{status: status, retryAfter: retryAfter})
+	}
+}
+
+// MaxInFlight returns the largest number of requests handled at once.
+func (s *Server) MaxInFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxInFlight
+}
+
+// Requests returns the "METHOD /path?query" of every request received.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.inFlight++
+		s.maxInFlight = max(s.maxInFlight, s.inFlight)
+		var throttled *throttle
+		if len(s.throttled) > 0 {
+			throttled = &s.throttled[0]
+			s.throttled = s.throttled[1:]
+		}
+		s.mu.Unlock()
+
+		defer func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}()
+
+		if throttled != nil {
+			if throttled.retryAfter != "" {
+				w.Header().Set("Retry-After", throttled.retryAfter)
+			}
+			writeJSON(w, throttled.status, []byte(`{"message":"Too Many Attempts."}`))
+			return
+		}
+
+		if r.Header.Get("x-api-secret") != APIKey {
+			writeJSON(w, http.StatusUnauthorized, []byte(`{"message":"Unauthenticated."}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) tournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	source := profixio.Source{Organisation: r.PathValue("organisation"), Sport: r.URL.Query().Get("sportId")}
+	page := pageParam(r)
+
+	s.mu.Lock()
+	body, ok := s.tournamentPages[source.ID()][page]
+	s.mu.Unlock()
+
+	if !ok {
+		body = mustMarshal(profixio.TournamentResponse{})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) matchesHandler(w http.ResponseWriter, r *http.Request) {
+	tournamentID, err := strconv.Atoi(r.PathValue("tournament"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, []byte(`{"message":"Not found."}`))
+		return
+	}
+	page := pageParam(r)
+
+	s.mu.Lock()
+	body, ok := s.matchPages[tournamentID][page]
+	s.mu.Unlock()
+
+	if !ok {
+		body = mustMarshal(profixio.MatchResponse{})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) matchHandler(w http.ResponseWriter, r *http.Request) {
+	tournamentID, err := strconv.Atoi(r.PathValue("tournament"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, []byte(`{"message":"Not found."}`))
+		return
+	}
+
+	s.mu.Lock()
+	body, ok := s.matches[tournamentID][r.PathValue("match")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, []byte(`{"message":"Not found."}`))
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) resultHandler(w http.ResponseWriter, r *http.Request) {
+	var result profixio.MatchResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, []byte(`{"message":"Invalid body."}`))
+		return
+	}
+
+	s.mu.Lock()
+	s.results = append(s.results, PostedResult{
+		TournamentID: r.PathValue("tournament"),
+		MatchID:      r.PathValue("match"),
+		Result:       result,
+	})
+	status := s.resultStatus
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func mustMarshal(v any) []byte {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return body
+}
@@ -1,315 +1,238 @@
 package profixio
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
-	"os"
-	"sync"
+	"strconv"
+	"time"
 
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 
-	"cloud.google.com/go/firestore"
 	timehelper "github.com/nvbf/tournament-sync/pkg/timeHelper"
 	"github.com/samborkent/uuidv7"
 	"github.com/xorcare/pointer"
-	"golang.org/x/xerrors"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
-var ErrAlreadyRegistered = errors.New("already registered")
+var (
+	ErrAlreadyRegistered = errors.New("already registered")
+
+	// ErrUpstreamUnavailable is returned when Profixio cannot be reached or
+	// answers with an unexpected status.
+	ErrUpstreamUnavailable = errors.New("profixio unavailable")
+	// ErrDecode is returned when a Profixio response cannot be decoded, for
+	// example when an HTML error page is returned in place of JSON.
+	ErrDecode = errors.New("profixio response could not be decoded")
+	// ErrStorage is returned when reading or writing the synced data fails.
+	ErrStorage = errors.New("storage failure")
+)
+
+// SyncStats counts what a fetch did.
+type SyncStats struct {
+	PagesFetched     int
+	MatchesCreated   int
+	MatchesUpdated   int
+	MatchesUnchanged int
+}
+
+func (s *SyncStats) add(other SyncStats) {
+	s.PagesFetched += other.PagesFetched
+	s.MatchesCreated += other.MatchesCreated
+	s.MatchesUpdated += other.MatchesUpdated
+	s.MatchesUnchanged += other.MatchesUnchanged
+}
+
+// pageResult is the outcome of fetching and storing one page.
+type pageResult struct {
+	stats SyncStats
+	err   error
+}
 
 // Service represents the migration status of a single service.
 type Service struct {
-	Client       *firestore.Client
+	Store        Store
 	ProfixioHost string
+
+	httpClient   *http.Client
+	baseURL      string
+	apiKey       string
+	limiter      *rate.Limiter
+	maxRetries   int
+	retryBackoff time.Duration
+	pageWorkers  int
+	writeWorkers int
+	sources      []Source
+	// writeSlots bounds the storage writes in flight across all syncs.
+	writeSlots     chan struct{}
+	onMatchChanges MatchChangeHandler
 }
 
 // NewService creates a new empty service.
-func NewService(client *firestore.Client, profixioHost string) *Service {
-	return &Service{
-		Client:       client,
+func NewService(store Store, profixioHost string, opts ...Option) *Service {
+	s := &Service{
+		Store:        store,
 		ProfixioHost: profixioHost,
-	}
+		httpClient:   &http.Client{},
+		baseURL:      defaultBaseURL(profixioHost),
+		limiter:      rate.NewLimiter(defaultRateLimit, defaultRateBurst),
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		pageWorkers:  defaultPageWorkers,
+		writeWorkers: defaultWriteWorkers,
+		sources:      DefaultSources(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.writeSlots = make(chan struct{}, s.writeWorkers)
+	return s
 }
 
-func (s Service) FetchTournaments(ctx context.Context, pageId int) {
-	log.Printf("fetch tournaments start page=%d", pageId)
-
-	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/organisations/NVBF.NO.VB/tournaments?limit=5&sportId=SVB&page=%d", s.ProfixioHost, pageId)
-
-	// Create an HTTP client
-	httpClient := &http.Client{}
-
-	// Create an HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
+// FetchTournaments syncs the tournaments of the sources with the given IDs,
+// or of every configured source when none are given. A failing source does
+// not stop the others.
+func (s Service) FetchTournaments(ctx context.Context, pageId int, sourceIDs ...string) (SyncStats, error) {
+	sources, err := s.selectSources(sourceIDs)
 	if err != nil {
-		log.Fatalf("Failed to create HTTP request: %v", err)
+		return SyncStats{}, err
 	}
 
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
+	var stats SyncStats
+	var errs []error
+	for _, source := range sources {
+		sourceStats, err := s.fetchSourceTournaments(ctx, source, pageId)
+		stats.add(sourceStats)
+		errs = append(errs, err)
 	}
-	defer response.Body.Close()
-
-	// Parse the API response into the APIResponse struct
-	var apiResponse TournamentResponse
-	err = json.NewDecoder(response.Body).Decode(&apiResponse)
-	if err != nil {
-		log.Fatalf("Failed to parse API response for %s: %v", apiURL, err)
-	}
-
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Create a channel to receive tournament data from goroutines
-	tournamentCh := make(chan Tournament)
-
-	// Start concurrent goroutines to process tournaments
-	for _, tournament := range apiResponse.Data {
-		wg.Add(1)
-		go s.processTournament(ctx, tournament, tournamentCh, &wg)
-	}
-
-	// Close the channel when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(tournamentCh)
-	}()
+	return stats, errors.Join(errs...)
+}
 
-	// Iterate over the channel to receive tournament data
-	for tournament := range tournamentCh {
-		// Do something with the tournament data
-		log.Printf("tournament processed slug=%s", *tournament.Slug)
-	}
+func (s Service) fetchSourceTournaments(ctx context.Context, source Source, pageId int) (SyncStats, error) {
+	log.Printf("fetch tournaments start source=%s page=%d", source.ID(), pageId)
 
-	lastPage := apiResponse.Meta.LastPage
+	// Make the API call to fetch the tournaments
+	apiResponse, err := s.getTournamentPage(ctx, source, pageId)
 	if err != nil {
-		log.Println("request parse failed")
-		return
+		log.Printf("fetch tournament page failed source=%s page=%d err=%v", source.ID(), pageId, err)
+		return SyncStats{}, err
 	}
 
-	var wg2 sync.WaitGroup
-
-	for i := 2; i <= lastPage; i++ {
-		wg2.Add(1)
-		go s.fetchTournamentPage(ctx, i, &wg2)
-	}
-	wg2.Wait()
+	stats := SyncStats{PagesFetched: 1}
+	errs := []error{s.processTournaments(ctx, source, apiResponse.Data)}
 
-	log.Printf("fetch tournaments done startPage=%d lastPage=%d", pageId, lastPage)
-}
+	lastPage := apiResponse.Meta.LastPage
 
-func (s Service) ProcessCustomTournament(ctx context.Context, slug string, customTournament CustomTournament) {
-	matchCount := 0
-	if customTournament.Matches != nil {
-		matchCount = len(*customTournament.Matches)
+	results := forEach(remainingPages(lastPage), s.pageWorkers, func(pageId int) pageResult {
+		stats, err := s.fetchTournamentPage(ctx, source, pageId)
+		return pageResult{stats: stats, err: err}
+	})
+	for _, result := range results {
+		stats.add(result.stats)
+		errs = append(errs, result.err)
 	}
-	log.Printf("process custom tournament start slug=%s matches=%d", slug, matchCount)
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Create a channel to receive tournament data from goroutines
-	matchCh := make(chan Match)
 
-	// Start concurrent goroutines to process tournaments
-	for _, match := range *customTournament.Matches {
-		wg.Add(1)
-
-		go s.processMatches(ctx, slug, match, matchCh, &wg)
+	err = errors.Join(errs...)
+	if err != nil {
+		log.Printf("fetch tournaments failed source=%s startPage=%d lastPage=%d err=%v", source.ID(), pageId, lastPage, err)
+		return stats, err
 	}
 
-	// Close the channel when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(matchCh)
-		log.Printf("process custom tournament dispatched all matches slug=%s", slug)
-	}()
+	log.Printf("fetch tournaments done source=%s startPage=%d lastPage=%d", source.ID(), pageId, lastPage)
+	return stats, nil
 }
 
-func (s Service) SetCustomTournament(ctx context.Context, tournament Tournament) {
-	tournament.Type = pointer.String("Custom")
-	if tournament.Slug != nil {
-		log.Printf("set custom tournament slug=%s", *tournament.Slug)
-	}
-	s.storeTournament(ctx, tournament)
-}
-
-func (s Service) fetchTournamentPage(ctx context.Context, pageId int, wgx *sync.WaitGroup) {
-	defer wgx.Done()
-	log.Printf("fetch tournament page start page=%d", pageId)
+func (s Service) fetchTournamentPage(ctx context.Context, source Source, pageId int) (SyncStats, error) {
+	log.Printf("fetch tournament page start source=%s page=%d", source.ID(), pageId)
 
 	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/organisations/NVBF.NO.VB/tournaments?limit=5&sportId=SVB&page=%d", s.ProfixioHost, pageId)
-
-	// Create an HTTP client
-	httpClient := &http.Client{}
-
-	// Create an HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Fatalf("Failed to create HTTP request: %v", err)
-	}
-
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
-	}
-	defer response.Body.Close()
-
-	// Parse the API response into the APIResponse struct
-	var apiResponse TournamentResponse
-	err = json.NewDecoder(response.Body).Decode(&apiResponse)
+	apiResponse, err := s.getTournamentPage(ctx, source, pageId)
 	if err != nil {
-		log.Fatalf("Failed to parse API response for %s: %v", apiURL, err)
-	}
-
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Create a channel to receive tournament data from goroutines
-	tournamentCh := make(chan Tournament)
-
-	// Start concurrent goroutines to process tournaments
-	for _, tournament := range apiResponse.Data {
-		wg.Add(1)
-		go s.processTournament(ctx, tournament, tournamentCh, &wg)
+		log.Printf("fetch tournament page failed source=%s page=%d err=%v", source.ID(), pageId, err)
+		return SyncStats{}, err
 	}
 
-	// Close the channel when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(tournamentCh)
-	}()
-
-	log.Printf("fetch tournament page done page=%d records=%d", pageId, len(apiResponse.Data))
+	err = s.processTournaments(ctx, source, apiResponse.Data)
+	log.Printf("fetch tournament page done source=%s page=%d records=%d", source.ID(), pageId, len(apiResponse.Data))
+	return SyncStats{PagesFetched: 1}, err
+}
 
+// processTournaments tags the tournaments with their source, stores them
+// concurrently and returns the joined errors of the ones that failed.
+func (s Service) processTournaments(ctx context.Context, source Source, tournaments []Tournament) error {
+	errs := forEach(tournaments, s.writeWorkers, func(tournament Tournament) error {
+		tournament.Source = pointer.String(source.ID())
+		return s.processTournament(ctx, tournament)
+	})
+	return errors.Join(errs...)
 }
 
-func (s Service) processTournament(ctx context.Context, tournament Tournament, tournamentCh chan<- Tournament, wg *sync.WaitGroup) {
-	defer wg.Done()
+func (s Service) processTournament(ctx context.Context, tournament Tournament) error {
 	tournament.Type = pointer.String("Profixio")
 	if *tournament.EndDate > timehelper.GetTodaysDateString() {
 		log.Printf("tournament update eligible slug=%s endDate=%s today=%s", *tournament.Slug, *tournament.EndDate, timehelper.GetTodaysDateString())
-		s.storeTournament(ctx, tournament)
+		if err := s.storeTournament(ctx, tournament); err != nil {
+			return err
+		}
 	}
-	// Send the processed tournament to the channel
-	tournamentCh <- tournament
 	log.Printf("process tournament done slug=%s", *tournament.Slug)
+	return nil
 }
 
-func (s Service) storeTournament(ctx context.Context, tournament Tournament) {
+func (s Service) storeTournament(ctx context.Context, tournament Tournament) error {
 	if tournament.Slug != nil {
 		log.Printf("store tournament start slug=%s", *tournament.Slug)
 	}
 
+	// A new secret is only kept if the tournament does not have one already
+	newSecret := uuidv7.New().String()
 	tournamentSecrets := TournamentSecrets{
-		ID:   tournament.ID,
-		Slug: tournament.Slug,
+		ID:     tournament.ID,
+		Slug:   tournament.Slug,
+		Secret: &newSecret,
 	}
 
-	// Get a document
-	docRef := s.Client.Collection("Tournaments").Doc(*tournament.Slug)
-	secretDocRef := s.Client.Collection("TournamentSecrets").Doc(*tournamentSecrets.Slug)
+	release := s.acquireWriteSlot()
+	defer release()
 
-	doc, _ := docRef.Get(ctx)
-	secretDoc, _ := secretDocRef.Get(ctx)
-
-	if secret, ok := secretDoc.Data()["Secret"].(string); ok {
-		tournamentSecrets.Secret = &secret
-	} else {
-		newSecret := uuidv7.New().String()
-		tournamentSecrets.Secret = &newSecret
-	}
-
-	if doc.Exists() {
-
-		updates := createTournamentUpdates(&tournament)
-
-		// Write the tournament to Firestore
-		_, err := s.Client.Collection("Tournaments").Doc(*tournament.Slug).Update(ctx, updates)
-		if err != nil {
-			log.Printf("firestore update tournament failed slug=%s err=%v", *tournament.Slug, err)
-			return
-		}
-		log.Printf("updated tournament slug=%s", *tournament.Slug)
-	} else {
-		_, err := s.Client.Collection("Tournaments").Doc(*tournament.Slug).Set(ctx, tournament)
-		if err != nil {
-			log.Printf("firestore create tournament failed slug=%s err=%v", *tournament.Slug, err)
-			return
-		}
-		log.Printf("created tournament slug=%s", *tournament.Slug)
+	err := s.Store.PutTournament(ctx, tournament)
+	if err != nil {
+		log.Printf("firestore store tournament failed slug=%s err=%v", *tournament.Slug, err)
+		return fmt.Errorf("%w: store tournament %s: %v", ErrStorage, *tournament.Slug, err)
 	}
+	log.Printf("stored tournament slug=%s", *tournament.Slug)
 
-	if secretDoc.Exists() {
-
-		updates := createTournamentSecretUpdates(&tournamentSecrets)
-
-		// Write the tournament to Firestore
-		_, err := s.Client.Collection("TournamentSecrets").Doc(*tournamentSecrets.Slug).Update(ctx, updates)
-		if err != nil {
-			log.Printf("firestore update tournament secret failed slug=%s err=%v", *tournamentSecrets.Slug, err)
-			return
-		}
-		log.Printf("updated tournament secret slug=%s", *tournamentSecrets.Slug)
-	} else {
-		_, err := s.Client.Collection("TournamentSecrets").Doc(*tournamentSecrets.Slug).Set(ctx, tournamentSecrets)
-		if err != nil {
-			log.Printf("firestore create tournament secret failed slug=%s err=%v", *tournamentSecrets.Slug, err)
-			return
-		}
-		log.Printf("created tournament secret slug=%s", *tournamentSecrets.Slug)
+	err = s.Store.EnsureTournamentSecrets(ctx, tournamentSecrets)
+	if err != nil {
+		log.Printf("firestore store tournament secret failed slug=%s err=%v", *tournamentSecrets.Slug, err)
+		return fmt.Errorf("%w: store tournament secret %s: %v", ErrStorage, *tournamentSecrets.Slug, err)
 	}
+	log.Printf("stored tournament secret slug=%s", *tournamentSecrets.Slug)
+	return nil
 }
 
 func (s Service) FetchMatch(ctx context.Context, tournamentSlug string, matchNumber string, tournamentID int, matchID int) error {
 	log.Printf("fetch match start tournamentSlug=%s matchNumber=%s tournamentID=%d matchID=%d", tournamentSlug, matchNumber, tournamentID, matchID)
 
-	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/tournaments/%d/matches/%d", s.ProfixioHost, tournamentID, matchID)
-
-	// Create an HTTP client
-	httpClient := &http.Client{}
-
-	// Create an HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
+	// Make the API call to fetch the match
+	apiURL := s.matchPath(strconv.Itoa(tournamentID), strconv.Itoa(matchID))
+	response, err := s.do(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
-		log.Fatalf("Failed to create HTTP request: %v", err)
-	}
-
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
+		log.Printf("api request failed url=%s err=%v", apiURL, err)
+		return fmt.Errorf("%w: request %s: %v", ErrUpstreamUnavailable, apiURL, err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != 200 {
+		log.Printf("api request failed url=%s status=%d", apiURL, response.StatusCode)
 		if response.StatusCode == 404 {
 			log.Printf("match missing in profixio status=404 tournamentSlug=%s matchNumber=%s", tournamentSlug, matchNumber)
+			return nil
 		}
-		log.Printf("api request failed url=%s status=%d", apiURL, response.StatusCode)
-		return nil
+		return fmt.Errorf("%w: request %s: status %d", ErrUpstreamUnavailable, apiURL, response.StatusCode)
 	}
 	log.Printf("fetch match api success url=%s status=%d", apiURL, response.StatusCode)
 
@@ -317,384 +240,211 @@ func (s Service) FetchMatch(ctx context.Context, tournamentSlug string, matchNum
 	var apiResponse SingleMatchResponse
 	err = json.NewDecoder(response.Body).Decode(&apiResponse)
 	if err != nil {
-		log.Fatalf("Failed to parse API response for %s: %v", apiURL, err)
+		log.Printf("api response decode failed url=%s err=%v", apiURL, err)
+		return fmt.Errorf("%w: decode %s: %v", ErrDecode, apiURL, err)
 	}
 
-	updates := createMatchUpdates(&apiResponse.Data)
-
 	// Update the match in Firestore
-	_, err = s.Client.Collection("Tournaments").Doc(tournamentSlug).Collection("Matches").Doc(matchNumber).Update(ctx, updates)
+	err = s.Store.UpdateMatch(ctx, tournamentSlug, matchNumber, apiResponse.Data)
 	if err != nil {
 		log.Printf("firestore update match failed tournamentSlug=%s matchNumber=%s err=%v", tournamentSlug, matchNumber, err)
-		return err
+		return fmt.Errorf("%w: update match %s/%s: %v", ErrStorage, tournamentSlug, matchNumber, err)
 	}
 	log.Printf("fetch match stored tournamentSlug=%s matchNumber=%s", tournamentSlug, matchNumber)
 	return nil
 }
 
-func (s Service) FetchMatches(ctx context.Context, pageId int, slug string, lastSync string, timeNow string) {
+// FetchMatches syncs the tournament's matches updated since lastSync. Failing
+// pages do not stop the others; their errors are joined in the returned error
+// and LastSynced is only advanced when every page succeeded.
+func (s Service) FetchMatches(ctx context.Context, pageId int, slug string, lastSync string, timeNow string) (SyncStats, error) {
 	log.Printf("fetch matches start slug=%s page=%d lastSync=%s", slug, pageId, lastSync)
 
-	tournamentID, err := s.getTournamentId(ctx, slug)
+	var stats SyncStats
 
+	tournamentID, err := s.Store.GetTournamentID(ctx, slug)
 	if err != nil {
-		log.Printf("firestore tournament id lookup failed slug=%s", slug)
-		return
-	}
-
-	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/tournaments/%d/matches?limit=150&page=%d", s.ProfixioHost, tournamentID, pageId)
-	if lastSync != "" {
-		apiURL = fmt.Sprintf("https://%s/app/api/tournaments/%d/matches?limit=150&page=%d&updated=%s", s.ProfixioHost, tournamentID, pageId, url.QueryEscape(lastSync))
+		log.Printf("firestore tournament id lookup failed slug=%s err=%v", slug, err)
+		return stats, fmt.Errorf("%w: tournament id %s: %v", ErrStorage, slug, err)
 	}
 
-	// Create an HTTP client
-	httpClient := &http.Client{}
-
-	// Create an HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
+	// Make the API call to fetch the matches
+	apiResponse, err := s.getMatchesPage(ctx, tournamentID, pageId, lastSync)
 	if err != nil {
-		log.Fatalf("Failed to create HTTP request: %v", err)
+		log.Printf("fetch matches page failed slug=%s page=%d err=%v", slug, pageId, err)
+		return stats, err
 	}
 
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
-	}
-	defer response.Body.Close()
-
-	// Parse the API response into the APIResponse struct
-	var apiResponse MatchResponse
-	err = json.NewDecoder(response.Body).Decode(&apiResponse)
-	if err != nil {
-		log.Fatalf("Failed to parse API response for %s: %v", apiURL, err)
-	}
+	pageStats, err := s.processMatchList(ctx, slug, apiResponse.Data)
+	stats.PagesFetched++
+	stats.add(pageStats)
+	errs := []error{err}
 
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Create a channel to receive tournament data from goroutines
-	matchCh := make(chan Match)
-
-	// Start concurrent goroutines to process tournaments
-	for _, match := range apiResponse.Data {
-		wg.Add(1)
-
-		go s.processMatches(ctx, slug, match, matchCh, &wg)
-	}
-
-	// Close the channel when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(matchCh)
-	}()
+	lastPage := apiResponse.Meta.LastPage
 
-	// Iterate over the channel to receive tournament data
-	for match := range matchCh {
-		// Do something with the tournament data
-		log.Printf("match processed slug=%s number=%s", slug, *match.Number)
+	results := forEach(remainingPages(lastPage), s.pageWorkers, func(pageId int) pageResult {
+		stats, err := s.fetchMatchesPage(ctx, tournamentID, pageId, slug, lastSync)
+		return pageResult{stats: stats, err: err}
+	})
+	for _, result := range results {
+		stats.add(result.stats)
+		errs = append(errs, result.err)
 	}
 
-	lastPage := apiResponse.Meta.LastPage
-
-	var wg2 sync.WaitGroup
+	err = errors.Join(errs...)
 
-	for i := 2; i <= lastPage; i++ {
-		wg2.Add(1)
-		go s.fetchMatchesPage(ctx, i, slug, lastSync, timeNow, &wg2)
+	// A full sync recounts the matches; an incremental one only adds the
+	// matches it created, even if some pages failed.
+	if err == nil && lastSync == "" {
+		if countErr := s.recountMatches(ctx, slug); countErr != nil {
+			return stats, countErr
+		}
+	} else if stats.MatchesCreated > 0 {
+		if incErr := s.Store.IncrementNumberOfMatches(ctx, slug, stats.MatchesCreated); incErr != nil {
+			log.Printf("firestore increment number of matches failed slug=%s err=%v", slug, incErr)
+			errs = append(errs, fmt.Errorf("%w: increment number of matches %s: %v", ErrStorage, slug, incErr))
+			err = errors.Join(errs...)
+		}
 	}
-	wg2.Wait()
-
-	s.setLastSynced(ctx, slug, timeNow)
 
-	docRefs, err := s.Client.Collection("Tournaments").Doc(slug).Collection("Matches").DocumentRefs(ctx).GetAll()
 	if err != nil {
-		log.Fatalf("Failed to count matches for %s: %v", slug, err)
+		log.Printf("fetch matches failed slug=%s lastPage=%d err=%v", slug, lastPage, err)
+		return stats, err
 	}
 
-	_, err = s.Client.Collection("Tournaments").Doc(slug).Update(ctx,
-		[]firestore.Update{
-			{Path: "NumberOfMatches", Value: len(docRefs)},
-		},
-	)
-	if err != nil {
-		log.Fatalf("Failed to set number of matches for %s: %v", slug, err)
+	if err := s.setLastSynced(ctx, slug, timeNow); err != nil {
+		return stats, err
 	}
-	log.Printf("fetch matches done slug=%s lastPage=%d", slug, lastPage)
+
+	log.Printf("fetch matches done slug=%s lastPage=%d created=%d updated=%d unchanged=%d", slug, lastPage, stats.MatchesCreated, stats.MatchesUpdated, stats.MatchesUnchanged)
+	return stats, nil
 }
 
-func (s Service) fetchMatchesPage(ctx context.Context, pageId int, slug string, lastSync string, timeNow string, wgx *sync.WaitGroup) {
-	defer wgx.Done()
+func (s Service) fetchMatchesPage(ctx context.Context, tournamentID int, pageId int, slug string, lastSync string) (SyncStats, error) {
 	log.Printf("fetch matches page start slug=%s page=%d", slug, pageId)
 
-	tournamentID, err := s.getTournamentId(ctx, slug)
-
+	// Make the API call to fetch the matches
+	apiResponse, err := s.getMatchesPage(ctx, tournamentID, pageId, lastSync)
 	if err != nil {
-		log.Printf("firestore tournament id lookup failed slug=%s", slug)
-		return
-	}
-
-	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/tournaments/%d/matches?limit=150&page=%d", s.ProfixioHost, tournamentID, pageId)
-	if lastSync != "" {
-		apiURL = fmt.Sprintf("https://%s/app/api/tournaments/%d/matches?limit=150&page=%d&updated=%s", s.ProfixioHost, tournamentID, pageId, url.QueryEscape(lastSync))
+		log.Printf("fetch matches page failed slug=%s page=%d err=%v", slug, pageId, err)
+		return SyncStats{}, err
 	}
 
-	// Create an HTTP client
-	httpClient := &http.Client{}
+	stats, err := s.processMatchList(ctx, slug, apiResponse.Data)
+	stats.PagesFetched = 1
+	log.Printf("fetch matches page done slug=%s page=%d records=%d", slug, pageId, len(apiResponse.Data))
+	return stats, err
+}
 
-	// Create an HTTP request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Fatalf("Failed to create HTTP request: %v", err)
+// processMatchList stores the matches in one batched write, counts what
+// changed and reports the changed matches to the match change handler.
+func (s Service) processMatchList(ctx context.Context, slug string, matches []Match) (SyncStats, error) {
+	if len(matches) == 0 {
+		return SyncStats{}, nil
+	}
+
+	release := s.acquireWriteSlot()
+	defer release()
+
+	diffs, err := s.Store.PutMatches(ctx, slug, matches)
+
+	var stats SyncStats
+	var changed []MatchDiff
+	for _, diff := range diffs {
+		switch {
+		case diff.Created:
+			stats.MatchesCreated++
+			log.Printf("match created slug=%s number=%s", slug, diff.Number)
+		case diff.Unchanged():
+			stats.MatchesUnchanged++
+			continue
+		default:
+			stats.MatchesUpdated++
+			log.Printf("match updated slug=%s number=%s fields=%v", slug, diff.Number, diff.Fields)
+		}
+		changed = append(changed, diff)
 	}
+	log.Printf("stored matches slug=%s records=%d created=%d updated=%d unchanged=%d", slug, len(matches), stats.MatchesCreated, stats.MatchesUpdated, stats.MatchesUnchanged)
 
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
+	if len(changed) > 0 && s.onMatchChanges != nil {
+		s.onMatchChanges(ctx, slug, changed)
 	}
-	defer response.Body.Close()
-
-	// Parse the API response into the APIResponse struct
-	var apiResponse MatchResponse
-	err = json.NewDecoder(response.Body).Decode(&apiResponse)
 	if err != nil {
-		log.Fatalf("Failed to parse API response for %s: %v", apiURL, err)
+		log.Printf("firestore store matches failed slug=%s err=%v", slug, err)
+		return stats, fmt.Errorf("%w: store matches %s: %w", ErrStorage, slug, err)
 	}
-
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Create a channel to receive tournament data from goroutines
-	matchCh := make(chan Match)
-
-	// Start concurrent goroutines to process tournaments
-	for _, match := range apiResponse.Data {
-		wg.Add(1)
-
-		go s.processMatches(ctx, slug, match, matchCh, &wg)
-	}
-
-	// Close the channel when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(matchCh)
-	}()
-
-	// Iterate over the channel to receive tournament data
-	for match := range matchCh {
-		// Do something with the tournament data
-		log.Printf("match page item processed slug=%s page=%d number=%s", slug, pageId, *match.Number)
-	}
-	log.Printf("fetch matches page done slug=%s page=%d records=%d", slug, pageId, len(apiResponse.Data))
+	return stats, nil
 }
 
-func (s Service) processMatches(ctx context.Context, slug string, match Match, matchCh chan<- Match, wg *sync.WaitGroup) {
-	defer wg.Done()
-	log.Printf("process match start slug=%s number=%s", slug, *match.Number)
-	// Get a document
-	docRef := s.Client.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(*match.Number)
-	doc, _ := docRef.Get(ctx)
-
-	if doc.Exists() {
-		updates := createMatchUpdates(&match)
-
-		// Update the match in Firestore
-		_, err := s.Client.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(*match.Number).Update(ctx, updates)
-		if err != nil {
-			log.Printf("firestore update match failed slug=%s number=%s err=%v", slug, *match.Number, err)
-			return
-		}
-		log.Printf("updated match slug=%s number=%s", slug, *match.Number)
-	} else {
-		// Write the match to Firestore
-		_, err := s.Client.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(*match.Number).Set(ctx, match)
-		if err != nil {
-			log.Printf("firestore create match failed slug=%s number=%s err=%v", slug, *match.Number, err)
-			return
-		}
-		log.Printf("created match slug=%s number=%s", slug, *match.Number)
-	}
-
-	// Send the processed tournament to the channel
-	matchCh <- match
+// acquireWriteSlot blocks until a storage write may start and returns the
+// function that ends it.
+func (s Service) acquireWriteSlot() func() {
+	s.writeSlots <- struct{}{}
+	return func() { <-s.writeSlots }
 }
 
-func (s Service) getTournamentId(ctx context.Context, slug string) (int, error) {
-	var tournament Tournament
-	log.Printf("get tournament id start slug=%s", slug)
-
-	// Write the tournament to Firestore
-	doc, err := s.Client.Collection("Tournaments").Doc(slug).Get(ctx)
-	if err != nil {
-		log.Printf("firestore read tournament failed slug=%s err=%v", slug, err)
-		return -1, err
-	}
-
-	if err := doc.DataTo(&tournament); err != nil {
-		// If this fails, we have an inconsistency error as we control both the data written to
-		// Firestore and the shape of our `fsIntegration` struct.
-		log.Printf("tournament parse failed slug=%s err=%v", slug, err)
-		return -1, xerrors.Errorf(
-			"consistency error. Converting %+v to internal integration struct failed: %w",
-			doc,
-			err,
-		)
-	}
-	log.Printf("get tournament id done slug=%s tournamentID=%d", slug, *tournament.ID)
-	// Send the processed tournament to the channel
-	return *tournament.ID, nil
-}
+// forEach calls fn for every item with at most workers calls running at once,
+// and returns the results in the order of items.
+func forEach[T any, R any](items []T, workers int, fn func(T) R) []R {
+	results := make([]R, len(items))
 
-func (s Service) GetLastSynced(ctx context.Context, slug string) string {
-	log.Printf("get last synced start slug=%s", slug)
-	// Write the tournament to Firestore
-	doc, err := s.Client.Collection("Tournaments").Doc(slug).Get(ctx)
-	if err != nil {
-		log.Printf("firestore read tournament failed slug=%s err=%v", slug, err)
-		return ""
+	var group errgroup.Group
+	group.SetLimit(max(workers, 1))
+	for i, item := range items {
+		group.Go(func() error {
+			results[i] = fn(item)
+			return nil
+		})
 	}
+	group.Wait()
 
-	data := doc.Data()
-	fieldValue, ok := data["LastSynced"]
-	if !ok {
-		log.Printf("document field missing field=LastSynced slug=%s", slug)
-	}
+	return results
+}
 
-	fieldValueStr, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("document field type invalid field=LastSynced expected=string slug=%s", slug)
+// remainingPages lists the pages after the first up to lastPage.
+func remainingPages(lastPage int) []int {
+	var pages []int
+	for i := 2; i <= lastPage; i++ {
+		pages = append(pages, i)
 	}
-	log.Printf("get last synced done slug=%s value=%s", slug, fieldValueStr)
-
-	// Send the processed tournament to the channel
-	return fieldValueStr
+	return pages
 }
 
-func (s Service) GetLastRequest(ctx context.Context, slug string) string {
-	log.Printf("get last request start slug=%s", slug)
-	// Write the tournament to Firestore
-	doc, err := s.Client.Collection("Tournaments").Doc(slug).Get(ctx)
+func (s Service) recountMatches(ctx context.Context, slug string) error {
+	numberOfMatches, err := s.Store.CountMatches(ctx, slug)
 	if err != nil {
-		log.Printf("firestore read tournament failed slug=%s err=%v", slug, err)
-		return ""
+		log.Printf("firestore count matches failed slug=%s err=%v", slug, err)
+		return fmt.Errorf("%w: count matches %s: %v", ErrStorage, slug, err)
 	}
 
-	data := doc.Data()
-	fieldValue, ok := data["LastRequest"]
-	if !ok {
-		log.Printf("document field missing field=LastRequest slug=%s", slug)
-	}
-
-	fieldValueStr, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("document field type invalid field=LastRequest expected=string slug=%s", slug)
+	err = s.Store.SetNumberOfMatches(ctx, slug, numberOfMatches)
+	if err != nil {
+		log.Printf("firestore set number of matches failed slug=%s err=%v", slug, err)
+		return fmt.Errorf("%w: set number of matches %s: %v", ErrStorage, slug, err)
 	}
-	log.Printf("get last request done slug=%s value=%s", slug, fieldValueStr)
-
-	// Send the processed tournament to the channel
-	return fieldValueStr
+	return nil
 }
 
 func (s Service) setLastSynced(ctx context.Context, slug string, lastSynced string) error {
 	log.Printf("set last synced slug=%s value=%s", slug, lastSynced)
-	// Write the tournament to Firestore
-	_, err := s.Client.Collection("Tournaments").Doc(slug).Update(ctx, []firestore.Update{
-		{
-			Path:  "LastSynced",
-			Value: lastSynced,
-		},
-	})
+	err := s.Store.SetLastSynced(ctx, slug, lastSynced)
 	if err != nil {
-		// Handle any errors in an appropriate way, such as returning them.
 		log.Printf("set last synced failed slug=%s err=%v", slug, err)
+		return fmt.Errorf("%w: set last synced %s: %v", ErrStorage, slug, err)
 	}
-	// Send the processed tournament to the channel
-	return nil
-}
-
-func (s Service) IsCustomTournament(ctx context.Context, slug string) bool {
-	doc, err := s.Client.Collection("Tournaments").Doc(slug).Get(ctx)
-	if err != nil {
-		log.Printf("firestore read tournament failed slug=%s err=%v", slug, err)
-		return false
-	}
-
-	data := doc.Data()
-	fieldValue, ok := data["Type"]
-	if !ok {
-		log.Printf("document field missing field=Type slug=%s", slug)
-		return false
-	}
-
-	fieldValueStr, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("document field type invalid field=Type expected=string slug=%s", slug)
-		return false
-	}
-
-	if fieldValueStr == "Custom" {
-		return true
-	}
-
-	// Send the processed tournament to the channel
-	return false
-}
-
-func (s Service) SetLastRequest(ctx context.Context, slug string, lastRequest string) error {
-	log.Printf("set last request slug=%s value=%s", slug, lastRequest)
-	// Write the tournament to Firestore
-	_, err := s.Client.Collection("Tournaments").Doc(slug).Update(ctx, []firestore.Update{
-		{
-			Path:  "LastRequest",
-			Value: lastRequest,
-		},
-	})
-	if err != nil {
-		// Handle any errors in an appropriate way, such as returning them.
-		log.Printf("set last request failed slug=%s err=%v", slug, err)
-	}
-	// Send the processed tournament to the channel
 	return nil
 }
 
 func (s Service) PostResult(ctx context.Context, matchID string, tournamentID string, result MatchResult) error {
 	log.Printf("post result start tournamentID=%s matchID=%s", tournamentID, matchID)
-	// Make the API call to fetch the tournaments
-	apiURL := fmt.Sprintf("https://%s/app/api/tournaments/%s/matches/%s", s.ProfixioHost, tournamentID, matchID)
-
 	// Encode the data object to JSON
 	jsonData, err := json.Marshal(result)
 	if err != nil {
 		return err
 	}
 
-	// Create an HTTP client
-	httpClient := &http.Client{}
-
-	// Create an HTTP request with JSON data in the body
-	req, err := http.NewRequest("PUT", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-
-	// Add the API key as a header
-	apiKey := os.Getenv("PROFIXIO_KEY")
-	req.Header.Set("x-api-secret", apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the HTTP request
-	response, err := httpClient.Do(req)
+	// Send the result with JSON data in the body
+	response, err := s.do(ctx, http.MethodPut, s.matchPath(tournamentID, matchID), jsonData)
 	if err != nil {
 		return err
 	}
@@ -710,129 +460,3 @@ func (s Service) PostResult(ctx context.Context, matchID string, tournamentID st
 
 	return nil
 }
-
-func createTournamentUpdates(tournament *Tournament) []firestore.Update {
-	var updates []firestore.Update
-
-	if tournament.ID != nil {
-		updates = append(updates, firestore.Update{Path: "ID", Value: *tournament.ID})
-	}
-	if tournament.Name != nil {
-		updates = append(updates, firestore.Update{Path: "Name", Value: *tournament.Name})
-	}
-	if tournament.Slug != nil {
-		updates = append(updates, firestore.Update{Path: "Slug", Value: *tournament.Slug})
-	}
-	if tournament.StartDate != nil {
-		updates = append(updates, firestore.Update{Path: "StartDate", Value: *tournament.StartDate})
-	}
-	if tournament.EndDate != nil {
-		updates = append(updates, firestore.Update{Path: "EndDate", Value: *tournament.EndDate})
-	}
-	if tournament.Type != nil {
-		updates = append(updates, firestore.Update{Path: "Type", Value: *tournament.Type})
-	}
-	if !tournament.StatsWritten {
-		updates = append(updates, firestore.Update{Path: "StatsWritten", Value: tournament.StatsWritten})
-	}
-
-	return updates
-}
-
-func createTournamentSecretUpdates(tournament *TournamentSecrets) []firestore.Update {
-	var updates []firestore.Update
-
-	if tournament.ID != nil {
-		updates = append(updates, firestore.Update{Path: "ID", Value: *tournament.ID})
-	}
-	if tournament.Slug != nil {
-		updates = append(updates, firestore.Update{Path: "Slug", Value: *tournament.Slug})
-	}
-	if tournament.Secret != nil {
-		updates = append(updates, firestore.Update{Path: "Secret", Value: *tournament.Secret})
-	}
-
-	return updates
-}
-
-func createMatchUpdates(match *Match) []firestore.Update {
-	var updates []firestore.Update
-
-	if match.ID != nil {
-		updates = append(updates, firestore.Update{Path: "ID", Value: *match.ID})
-	}
-	if match.Txid != nil {
-		updates = append(updates, firestore.Update{Path: "Txid", Value: *match.Txid})
-	}
-	if match.TournamentID != nil {
-		updates = append(updates, firestore.Update{Path: "TournamentId", Value: *match.TournamentID})
-	}
-	if match.GameRound != nil {
-		updates = append(updates, firestore.Update{Path: "GameRound", Value: *match.GameRound})
-	}
-	if match.PlayoffLevel != nil {
-		updates = append(updates, firestore.Update{Path: "PlayoffLevel", Value: *match.PlayoffLevel})
-	}
-	if match.Number != nil {
-		updates = append(updates, firestore.Update{Path: "Number", Value: *match.Number})
-	}
-	if match.Name != nil {
-		updates = append(updates, firestore.Update{Path: "Name", Value: *match.Name})
-	}
-	if match.Date != nil {
-		updates = append(updates, firestore.Update{Path: "Date", Value: *match.Date})
-	}
-	if match.Time != nil {
-		updates = append(updates, firestore.Update{Path: "Time", Value: *match.Time})
-	}
-	if match.WinnerTeam != nil {
-		updates = append(updates, firestore.Update{Path: "WinnerTeam", Value: *match.WinnerTeam})
-	}
-	if match.SettResultsFormatted != nil {
-		updates = append(updates, firestore.Update{Path: "SettResultsFormatted", Value: *match.SettResultsFormatted})
-	}
-	if match.MatchDataUpdated != nil {
-		updates = append(updates, firestore.Update{Path: "MatchDataUpdated", Value: *match.MatchDataUpdated})
-	}
-	if match.ResultsUpdated != nil {
-		updates = append(updates, firestore.Update{Path: "ResultsUpdated", Value: *match.ResultsUpdated})
-	}
-	if match.HasWinner != nil {
-		updates = append(updates, firestore.Update{Path: "HasWinner", Value: *match.HasWinner})
-	}
-	if match.IsHidden != nil {
-		updates = append(updates, firestore.Update{Path: "IsHidden", Value: *match.IsHidden})
-	}
-	if match.IsGroupPlay != nil {
-		updates = append(updates, firestore.Update{Path: "IsGroupPlay", Value: *match.IsGroupPlay})
-	}
-	if match.IsPlayoff != nil {
-		updates = append(updates, firestore.Update{Path: "IsPlayoff", Value: *match.IsPlayoff})
-	}
-	if match.IncludedInTableCalculation != nil {
-		updates = append(updates, firestore.Update{Path: "IncludedInTableCalculation", Value: *match.IncludedInTableCalculation})
-	}
-	if match.HomeTeam != nil {
-		updates = append(updates, firestore.Update{Path: "HomeTeam", Value: match.HomeTeam})
-	}
-	if match.AwayTeam != nil {
-		updates = append(updates, firestore.Update{Path: "AwayTeam", Value: match.AwayTeam})
-	}
-	if match.Field != nil {
-		updates = append(updates, firestore.Update{Path: "Field", Value: match.Field})
-	}
-	if match.MatchGroup != nil {
-		updates = append(updates, firestore.Update{Path: "MatchGroup", Value: match.MatchGroup})
-	}
-	if match.MatchCategory != nil {
-		updates = append(updates, firestore.Update{Path: "MatchCategory", Value: match.MatchCategory})
-	}
-	if match.Sets != nil {
-		updates = append(updates, firestore.Update{Path: "Sets", Value: match.Sets})
-	}
-	if match.RefereesTX != nil {
-		updates = append(updates, firestore.Update{Path: "RefereesTX", Value: match.RefereesTX})
-	}
-
-	return updates
-}
@@ -0,0 +1,93 @@
+package profixio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownSource is returned when a sync asks for a source that is not
+// configured.
+var ErrUnknownSource = errors.New("unknown tournament source")
+
+// Source is an organisation and sport whose tournaments are synced from
+// Profixio.
+type Source struct {
+	Organisation string
+	Sport        string
+}
+
+// ID identifies the source, for example "NVBF.NO.VB/SVB". Synced tournaments
+// are tagged with it.
+func (s Source) ID() string {
+	return s.Organisation + "/" + s.Sport
+}
+
+// DefaultSources is the NVBF beach volleyball listing.
+func DefaultSources() []Source {
+	return []Source{{Organisation: "NVBF.NO.VB", Sport: "SVB"}}
+}
+
+// ParseSource parses a source ID such as "NVBF.NO.VB/SVB".
+func ParseSource(id string) (Source, error) {
+	organisation, sport, ok := strings.Cut(id, "/")
+	if !ok || organisation == "" || sport == "" || strings.Contains(sport, "/") {
+		return Source{}, fmt.Errorf("invalid tournament source %q: expected organisation/sport", id)
+	}
+	return Source{Organisation: organisation, Sport: sport}, nil
+}
+
+// ParseSources parses source IDs such as "NVBF.NO.VB/SVB", skipping blank and
+// repeated IDs. It returns the DefaultSources when no IDs are given.
+func ParseSources(ids []string) ([]Source, error) {
+	var sources []Source
+	seen := map[string]bool{}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		source, err := ParseSource(id)
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return DefaultSources(), nil
+	}
+	return sources, nil
+}
+
+// Sources returns the configured sources.
+func (s Service) Sources() []Source {
+	return append([]Source(nil), s.sources...)
+}
+
+// selectSources returns the configured sources with the given IDs, or all of
+// them when no IDs are given.
+func (s Service) selectSources(ids []string) ([]Source, error) {
+	if len(ids) == 0 {
+		return s.sources, nil
+	}
+
+	selected := make([]Source, 0, len(ids))
+	for _, id := range ids {
+		found := false
+		for _, source := range s.sources {
+			if source.ID() == id {
+				selected = append(selected, source)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSource, id)
+		}
+	}
+	return selected, nil
+}
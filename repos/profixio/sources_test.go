@@ -0,0 +1,106 @@
+package profixio_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/xorcare/pointer"
+)
+
+var (
+	beach  = profixio.Source{Organisation: "NVBF.NO.VB", Sport: "SVB"}
+	indoor = profixio.Source{Organisation: "NVBF.NO.VB", Sport: "VB"}
+)
+
+func TestParseSources(t *testing.T) {
+	cases := []struct {
+		name     string
+		ids      []string
+		expected []profixio.Source
+		err      bool
+	}{
+		{name: "single", ids: []string{"NVBF.NO.VB/SVB"}, expected: []profixio.Source{beach}},
+		{name: "several", ids: []string{" NVBF.NO.VB/SVB", " NVBF.NO.VB/VB", "NVBF.NO.VB/SVB "}, expected: []profixio.Source{beach, indoor}},
+		{name: "missing sport", ids: []string{"NVBF.NO.VB"}, err: true},
+		{name: "empty sport", ids: []string{"NVBF.NO.VB/"}, err: true},
+		{name: "blank", ids: []string{" ", ""}, expected: profixio.DefaultSources()},
+		{name: "none", expected: profixio.DefaultSources()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sources, err := profixio.ParseSources(c.ids)
+			if (err != nil) != c.err {
+				t.Fatalf("expected error %v, got %v", c.err, err)
+			}
+			if !reflect.DeepEqual(sources, c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, sources)
+			}
+		})
+	}
+}
+
+func tournamentPage(slug string, id int) profixio.TournamentResponse {
+	var response profixio.TournamentResponse
+	response.Data = []profixio.Tournament{{
+		ID:        pointer.Int(id),
+		Slug:      pointer.String(slug),
+		StartDate: pointer.String("2999-01-01"),
+		EndDate:   pointer.String("2999-01-02"),
+	}}
+	response.Meta.LastPage = 1
+	return response
+}
+
+func TestFetchTournamentsSources(t *testing.T) {
+	cases := []struct {
+		name     string
+		sources  []string
+		expected map[string]string
+		err      error
+	}{
+		{
+			name:     "all sources",
+			expected: map[string]string{"beach-cup": beach.ID(), "indoor-cup": indoor.ID()},
+		},
+		{
+			name:     "one source",
+			sources:  []string{indoor.ID()},
+			expected: map[string]string{"indoor-cup": indoor.ID()},
+		},
+		{
+			name:    "unknown source",
+			sources: []string{"NVBF.NO.VB/SAND"},
+			err:     profixio.ErrUnknownSource,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := profixiotest.NewServer(t)
+			server.AddTournamentPage(beach, 1, tournamentPage("beach-cup", 1))
+			server.AddTournamentPage(indoor, 1, tournamentPage("indoor-cup", 2))
+			store := storage.NewMemory()
+			service := profixio.NewService(store, "", append(server.Options(), profixio.WithSources(beach, indoor))...)
+
+			_, err := service.FetchTournaments(context.Background(), 1, c.sources...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			tournaments, _ := store.ListTournaments(context.Background(), storage.TournamentFilter{})
+			got := map[string]string{}
+			for _, tournament := range tournaments {
+				got[tournament.Slug] = tournament.Source
+			}
+			if len(got) != len(c.expected) || (len(c.expected) > 0 && !reflect.DeepEqual(got, c.expected)) {
+				t.Fatalf("expected tournaments %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package profixio
+
+import "context"
+
+// Store is the persistence the Profixio sync writes tournaments and matches
+// to. It is implemented by storage.Store.
+type Store interface {
+	GetTournamentID(ctx context.Context, slug string) (int, error)
+	PutTournament(ctx context.Context, tournament Tournament) error
+	EnsureTournamentSecrets(ctx context.Context, secrets TournamentSecrets) error
+	PutMatches(ctx context.Context, slug string, matches []Match) ([]MatchDiff, error)
+	UpdateMatch(ctx context.Context, slug string, number string, match Match) error
+	SetLastSynced(ctx context.Context, slug string, lastSynced string) error
+	CountMatches(ctx context.Context, slug string) (int, error)
+	SetNumberOfMatches(ctx context.Context, slug string, numberOfMatches int) error
+	IncrementNumberOfMatches(ctx context.Context, slug string, delta int) error
+}
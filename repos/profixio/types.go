@@ -36,6 +36,8 @@ type Tournament struct {
 	StartDate    *string `json:"startDate"`
 	EndDate      *string `json:"endDate"`
 	StatsWritten bool    `json:"StatsWritten"`
+	// Source is the ID of the Source the tournament was synced from.
+	Source *string `json:"source"`
 }
 
 type TournamentPublic struct {
@@ -133,6 +135,8 @@ type Referee struct {
 type MatchResult struct {
 	Sets   []Result `json:"sets"`
 	Result Result   `json:"result"`
+	// Walkover marks a match won by default, after a forfeit or no-show.
+	Walkover bool `json:"walkover,omitempty"`
 }
 
 type Result struct {
@@ -3,27 +3,23 @@ package resend
 import (
 	"context"
 	"fmt"
-	"os"
 
-	"cloud.google.com/go/firestore"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	resend "github.com/resend/resend-go/v2"
 )
 
 // Service represents the migration status of a single service.
 type Service struct {
-	firebaseClient *firestore.Client
-	rebaseClient   *resend.Client
-	hostURL        string
+	rebaseClient *resend.Client
+	hostURL      string
 }
 
-// NewService creates a new empty service.
-func NewService(firestoreClient *firestore.Client, hostURL string) *Service {
-	resendKey := os.Getenv("RESEND_KEY")
+// NewService creates a service that sends mail with the given Resend API key
+// and links to hostURL.
+func NewService(apiKey string, hostURL string) *Service {
 	return &Service{
-		firebaseClient: firestoreClient,
-		rebaseClient:   resend.NewClient(resendKey),
-		hostURL:        hostURL,
+		rebaseClient: resend.NewClient(apiKey),
+		hostURL:      hostURL,
 	}
 }
 
@@ -45,62 +41,6 @@ func (s Service) SendMail(ctx context.Context, request AccessRequest, accessCode
 	return nil
 }
 
-func (s Service) GrantAccess(ctx context.Context, slug, userID string) error {
-	// Get a reference to the document
-	docRef := s.firebaseClient.Collection("TournamentSecrets").Doc(slug)
-
-	// Transaction to ensure that the update is atomic
-	// attempt to retrieve the document
-	// Check if UID already has access
-	// User already has access
-	// Add UID to the authorized users array if not present
-	err := grantAccessToDoc(ctx, s, docRef, userID)
-
-	if err != nil {
-		log.Printf("Failed to update document: %v", err)
-		return err
-	}
-
-	return nil
-}
-
-func grantAccessToDoc(ctx context.Context, s Service, docRef *firestore.DocumentRef, userID string) error {
-	err := s.firebaseClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		doc, err := tx.Get(docRef)
-		if err != nil {
-			return err
-		}
-
-		var allowedUsers []string
-		// Retrieve the allowedUsers field from the document, if it exists
-		if data, err := doc.DataAt("allowedUsers"); err == nil {
-			// Type assert the data to a slice of interface{}
-			if users, ok := data.([]interface{}); ok {
-				// Convert the slice of interface{} to a slice of strings
-				for _, user := range users {
-					if userStr, ok := user.(string); ok {
-						allowedUsers = append(allowedUsers, userStr)
-					}
-				}
-			}
-		}
-
-		// Check if the userID already exists in the allowedUsers list
-		for _, user := range allowedUsers {
-			if user == userID {
-				// User already has access, so return nil to indicate no update needed
-				return nil
-			}
-		}
-
-		updatedUsers := append(allowedUsers, userID)
-		return tx.Update(docRef, []firestore.Update{
-			{Path: "allowedUsers", Value: updatedUsers},
-		})
-	})
-	return err
-}
-
 func getEmailTemplate(url string) string {
 	return fmt.Sprintf(`<!DOCTYPE html>
 <html>
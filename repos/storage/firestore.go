@@ -0,0 +1,1011 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	firestorepb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+)
+
+const (
+	tournamentsCollection       = "Tournaments"
+	tournamentSecretsCollection = "TournamentSecrets"
+	matchesCollection           = "Matches"
+	eventsCollection            = "events"
+	syncJobsCollection          = "SyncJobs"
+	leasesCollection            = "Leases"
+	schedulerStateCollection    = "SchedulerState"
+	tournamentStatsCollection   = "TournamentStats"
+	accessCodesCollection       = "AccessCodes"
+	membersCollection           = "Members"
+	auditLogCollection          = "AuditLog"
+)
+
+// maxBatchSize is the most writes sent to Firestore as one batch.
+const maxBatchSize = 500
+
+// Firestore is the Store backed by Cloud Firestore.
+type Firestore struct {
+	client *firestore.Client
+}
+
+var _ Store = (*Firestore)(nil)
+
+// NewFirestore creates a Store on top of the given Firestore client.
+func NewFirestore(client *firestore.Client) *Firestore {
+	return &Firestore{client: client}
+}
+
+func (s *Firestore) tournaments() *firestore.CollectionRef {
+	return s.client.Collection(tournamentsCollection)
+}
+
+func (s *Firestore) tournamentMatches(slug string) *firestore.CollectionRef {
+	return s.tournaments().Doc(slug).Collection(matchesCollection)
+}
+
+func (s *Firestore) secrets() *firestore.CollectionRef {
+	return s.client.Collection(tournamentSecretsCollection)
+}
+
+func (s *Firestore) members(slug string) *firestore.CollectionRef {
+	return s.secrets().Doc(slug).Collection(membersCollection)
+}
+
+func (s *Firestore) scoreboards() *firestore.CollectionRef {
+	return s.client.Collection(matchesCollection)
+}
+
+func (s *Firestore) GetTournament(ctx context.Context, slug string) (*Tournament, error) {
+	doc, err := s.tournaments().Doc(slug).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[Tournament](doc)
+}
+
+func (s *Firestore) GetTournamentID(ctx context.Context, slug string) (int, error) {
+	tournament, err := s.GetTournament(ctx, slug)
+	if err != nil {
+		return -1, err
+	}
+	return tournament.ID, nil
+}
+
+func (s *Firestore) ListTournaments(ctx context.Context, filter TournamentFilter) ([]*Tournament, error) {
+	docs, err := s.tournamentQuery(filter).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	tournaments := make([]*Tournament, 0, len(docs))
+	for _, doc := range docs {
+		tournament, err := docTo[Tournament](doc)
+		if err != nil {
+			return nil, err
+		}
+		if filter.ActiveOn != "" && tournament.StartDate > filter.ActiveOn {
+			continue
+		}
+		tournaments = append(tournaments, tournament)
+	}
+	return tournaments, nil
+}
+
+// PageTournaments needs a composite index on the filtered fields, StartDate
+// and the document ID.
+func (s *Firestore) PageTournaments(ctx context.Context, filter TournamentFilter, page TournamentPage) ([]*Tournament, error) {
+	if filter.ActiveOn != "" {
+		return nil, errors.New("page tournaments: ActiveOn is not supported")
+	}
+
+	query := s.tournamentQuery(filter).
+		OrderBy("StartDate", firestore.Asc).
+		OrderBy(firestore.DocumentID, firestore.Asc)
+	if page.AfterSlug != "" {
+		query = query.StartAfter(page.AfterStartDate, page.AfterSlug)
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	tournaments := make([]*Tournament, 0, len(docs))
+	for _, doc := range docs {
+		tournament, err := docTo[Tournament](doc)
+		if err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, tournament)
+	}
+	return tournaments, nil
+}
+
+func (s *Firestore) SumTournaments(ctx context.Context, filter TournamentFilter) (*TournamentTotals, error) {
+	if filter.ActiveOn != "" {
+		return nil, errors.New("sum tournaments: ActiveOn is not supported")
+	}
+
+	query := s.tournamentQuery(filter)
+	result, err := query.NewAggregationQuery().
+		WithCount("tournaments").
+		WithSum("NumberOfScoreboards", "scoreboards").
+		WithSum("NumberOfMatches", "matches").
+		Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var totals TournamentTotals
+	for alias, total := range map[string]*int{"tournaments": &totals.Tournaments, "scoreboards": &totals.NumberOfScoreboards, "matches": &totals.NumberOfMatches} {
+		value, ok := result[alias].(*firestorepb.Value)
+		if !ok {
+			return nil, fmt.Errorf("sum tournaments: unexpected aggregation result %T for %s", result[alias], alias)
+		}
+		// Sums that overflow an integer are returned as doubles.
+		if _, ok := value.GetValueType().(*firestorepb.Value_DoubleValue); ok {
+			*total = int(value.GetDoubleValue())
+		} else {
+			*total = int(value.GetIntegerValue())
+		}
+	}
+	return &totals, nil
+}
+
+// tournamentQuery applies the filter to the Tournaments collection. ActiveOn
+// only bounds EndDate; callers check StartDate to avoid a composite index.
+func (s *Firestore) tournamentQuery(filter TournamentFilter) firestore.Query {
+	query := s.tournaments().Query
+	if filter.EndsBefore != "" {
+		query = query.Where("EndDate", "<", filter.EndsBefore)
+	}
+	if filter.StatsWritten != nil {
+		query = query.Where("StatsWritten", "==", *filter.StatsWritten)
+	}
+	if filter.HasScoreboards {
+		query = query.Where("NumberOfScoreboards", ">", 0)
+	}
+	if filter.ActiveOn != "" {
+		// StartDate is checked below to avoid a composite index.
+		query = query.Where("EndDate", ">=", filter.ActiveOn)
+	}
+	if filter.Source != "" {
+		query = query.Where("Source", "==", filter.Source)
+	}
+	if filter.Type != "" {
+		query = query.Where("Type", "==", filter.Type)
+	}
+	if filter.Organisation != "" {
+		// Source IDs are "organisation/sport", and "0" follows "/".
+		query = query.Where("Source", ">=", filter.Organisation+"/").Where("Source", "<", filter.Organisation+"0")
+	}
+	if filter.StartsFrom != "" {
+		query = query.Where("StartDate", ">=", filter.StartsFrom)
+	}
+	if filter.StartsTo != "" {
+		query = query.Where("StartDate", "<=", filter.StartsTo)
+	}
+	return query
+}
+
+func (s *Firestore) PutTournament(ctx context.Context, tournament profixio.Tournament) error {
+	if tournament.Slug == nil {
+		return fmt.Errorf("put tournament: missing slug")
+	}
+	docRef := s.tournaments().Doc(*tournament.Slug)
+
+	doc, err := docRef.Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+
+	if doc.Exists() {
+		_, err = docRef.Update(ctx, createTournamentUpdates(&tournament))
+		return err
+	}
+	_, err = docRef.Set(ctx, tournament)
+	return err
+}
+
+func (s *Firestore) PutCustomTournament(ctx context.Context, tournament Tournament) error {
+	if tournament.Slug == "" {
+		return fmt.Errorf("put custom tournament: missing slug")
+	}
+	_, err := s.tournaments().Doc(tournament.Slug).Set(ctx, map[string]any{
+		"ID":              tournament.ID,
+		"Name":            tournament.Name,
+		"Slug":            tournament.Slug,
+		"Type":            tournament.Type,
+		"StartDate":       tournament.StartDate,
+		"EndDate":         tournament.EndDate,
+		"EventType":       tournament.EventType,
+		"Categories":      tournament.Categories,
+		"Scoring":         tournament.Scoring,
+		"CategoryScoring": tournament.CategoryScoring,
+	}, firestore.MergeAll)
+	return err
+}
+
+func (s *Firestore) DeleteTournament(ctx context.Context, slug string) error {
+	_, err := s.tournaments().Doc(slug).Delete(ctx)
+	return err
+}
+
+func (s *Firestore) SetLastSynced(ctx context.Context, slug string, lastSynced string) error {
+	return s.updateTournament(ctx, slug, firestore.Update{Path: "LastSynced", Value: lastSynced})
+}
+
+func (s *Firestore) SetLastRequest(ctx context.Context, slug string, lastRequest string) error {
+	return s.updateTournament(ctx, slug, firestore.Update{Path: "LastRequest", Value: lastRequest})
+}
+
+func (s *Firestore) SetNumberOfMatches(ctx context.Context, slug string, numberOfMatches int) error {
+	return s.updateTournament(ctx, slug, firestore.Update{Path: "NumberOfMatches", Value: numberOfMatches})
+}
+
+func (s *Firestore) SetSyncOutcome(ctx context.Context, slug string, outcome SyncOutcome) error {
+	return s.updateTournament(ctx, slug,
+		firestore.Update{Path: "LastSyncStatus", Value: outcome.Status},
+		firestore.Update{Path: "LastSyncError", Value: outcome.Error},
+		firestore.Update{Path: "LastSyncAt", Value: outcome.At},
+	)
+}
+
+func (s *Firestore) IncrementNumberOfMatches(ctx context.Context, slug string, delta int) error {
+	return s.updateTournament(ctx, slug, firestore.Update{Path: "NumberOfMatches", Value: firestore.Increment(delta)})
+}
+
+func (s *Firestore) WriteStats(ctx context.Context, slug string, numberOfScoreboards int, numberOfMatches int) error {
+	return s.updateTournament(ctx, slug,
+		firestore.Update{Path: "NumberOfScoreboards", Value: numberOfScoreboards},
+		firestore.Update{Path: "NumberOfMatches", Value: numberOfMatches},
+		firestore.Update{Path: "StatsWritten", Value: true},
+	)
+}
+
+func (s *Firestore) updateTournament(ctx context.Context, slug string, updates ...firestore.Update) error {
+	_, err := s.tournaments().Doc(slug).Update(ctx, updates)
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) GetMatch(ctx context.Context, slug string, number string) (*Match, error) {
+	doc, err := s.tournamentMatches(slug).Doc(number).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[Match](doc)
+}
+
+func (s *Firestore) ListMatches(ctx context.Context, slug string) ([]*Match, error) {
+	docs, err := s.tournamentMatches(slug).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*Match, 0, len(docs))
+	for _, doc := range docs {
+		match, err := docTo[Match](doc)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+func (s *Firestore) CountMatches(ctx context.Context, slug string) (int, error) {
+	result, err := s.tournamentMatches(slug).NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("count matches %s: unexpected aggregation result %T", slug, result["count"])
+	}
+	return int(count.GetIntegerValue()), nil
+}
+
+func (s *Firestore) PutMatches(ctx context.Context, slug string, matches []profixio.Match) ([]profixio.MatchDiff, error) {
+	var diffs []profixio.MatchDiff
+	var errs []error
+
+	for start := 0; start < len(matches); start += maxBatchSize {
+		chunk := matches[start:min(start+maxBatchSize, len(matches))]
+		chunkDiffs, err := s.putMatchChunk(ctx, slug, chunk)
+		diffs = append(diffs, chunkDiffs...)
+		errs = append(errs, err)
+	}
+	return diffs, errors.Join(errs...)
+}
+
+// putMatchChunk reads the stored matches with one GetAll and writes the
+// changed ones with one BulkWriter.
+func (s *Firestore) putMatchChunk(ctx context.Context, slug string, matches []profixio.Match) ([]profixio.MatchDiff, error) {
+	var diffs []profixio.MatchDiff
+	var errs []error
+
+	valid := make([]profixio.Match, 0, len(matches))
+	docRefs := make([]*firestore.DocumentRef, 0, len(matches))
+	for _, match := range matches {
+		if match.Number == nil {
+			errs = append(errs, fmt.Errorf("put match: missing number"))
+			continue
+		}
+		valid = append(valid, match)
+		docRefs = append(docRefs, s.tournamentMatches(slug).Doc(*match.Number))
+	}
+	if len(docRefs) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	docs, err := s.client.GetAll(ctx, docRefs)
+	if err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+
+	type write struct {
+		job  *firestore.BulkWriterJob
+		diff profixio.MatchDiff
+	}
+	writes := make([]write, 0, len(valid))
+
+	writer := s.client.BulkWriter(ctx)
+	for i, match := range valid {
+		var job *firestore.BulkWriterJob
+		if docs[i].Exists() {
+			var stored Match
+			if err := docs[i].DataTo(&stored); err != nil {
+				errs = append(errs, fmt.Errorf("put match %s: %w", *match.Number, err))
+				continue
+			}
+			diff := profixio.DiffMatch(stored.Match, match)
+			if diff.Unchanged() {
+				diffs = append(diffs, diff)
+				continue
+			}
+			job, err = writer.Update(docRefs[i], createMatchUpdates(&match))
+			writes = append(writes, write{job: job, diff: diff})
+		} else {
+			job, err = writer.Set(docRefs[i], match)
+			writes = append(writes, write{job: job, diff: profixio.MatchDiff{Number: *match.Number, Created: true}})
+		}
+		if err != nil {
+			writes = writes[:len(writes)-1]
+			errs = append(errs, fmt.Errorf("put match %s: %w", *match.Number, err))
+		}
+	}
+	writer.End()
+
+	for _, w := range writes {
+		if _, err := w.job.Results(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		diffs = append(diffs, w.diff)
+	}
+	return diffs, errors.Join(errs...)
+}
+
+func (s *Firestore) DeleteMatches(ctx context.Context, slug string, numbers []string) error {
+	if len(numbers) == 0 {
+		return nil
+	}
+
+	writer := s.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(numbers))
+	var errs []error
+	for _, number := range numbers {
+		job, err := writer.Delete(s.tournamentMatches(slug).Doc(number))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete match %s: %w", number, err))
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Firestore) UpdateMatch(ctx context.Context, slug string, number string, match profixio.Match) error {
+	_, err := s.tournamentMatches(slug).Doc(number).Update(ctx, createMatchUpdates(&match))
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) SetMatchResultValid(ctx context.Context, slug string, number string, valid bool) error {
+	_, err := s.tournamentMatches(slug).Doc(number).Update(ctx, []firestore.Update{
+		{Path: "MatchResultValid", Value: valid},
+	})
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) SetMatchFinalized(ctx context.Context, slug string, number string) error {
+	_, err := s.tournamentMatches(slug).Doc(number).Update(ctx, buildTournamentFinalizeUpdates())
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) GetScoreboard(ctx context.Context, id string) (*Scoreboard, error) {
+	doc, err := s.scoreboards().Doc(id).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[Scoreboard](doc)
+}
+
+func (s *Firestore) UpdateScoreboard(ctx context.Context, id string, update ScoreboardUpdate) error {
+	updates := createScoreboardUpdates(update)
+	if len(updates) == 0 {
+		return nil
+	}
+	_, err := s.scoreboards().Doc(id).Update(ctx, updates)
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) ListEvents(ctx context.Context, id string) ([]Event, error) {
+	iter := s.scoreboards().Doc(id).Collection(eventsCollection).Documents(ctx)
+	defer iter.Stop()
+
+	events := []Event{}
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var event Event
+		if err := doc.DataTo(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *Firestore) AppendEvent(ctx context.Context, id string, event Event) error {
+	_, err := s.scoreboards().Doc(id).Collection(eventsCollection).Doc(event.ID).Set(ctx, event)
+	return err
+}
+
+func (s *Firestore) GetTournamentSecrets(ctx context.Context, slug string) (*TournamentSecrets, error) {
+	doc, err := s.secrets().Doc(slug).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[TournamentSecrets](doc)
+}
+
+func (s *Firestore) ListTournamentSecrets(ctx context.Context) ([]*TournamentSecrets, error) {
+	docs, err := s.secrets().Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*TournamentSecrets, 0, len(docs))
+	for _, doc := range docs {
+		secret, err := docTo[TournamentSecrets](doc)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+func (s *Firestore) EnsureTournamentSecrets(ctx context.Context, secrets profixio.TournamentSecrets) error {
+	if secrets.Slug == nil {
+		return fmt.Errorf("ensure tournament secrets: missing slug")
+	}
+	docRef := s.secrets().Doc(*secrets.Slug)
+
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if !doc.Exists() {
+			return tx.Set(docRef, secrets)
+		}
+
+		if existing, ok := doc.Data()["Secret"].(string); ok && existing != "" {
+			secrets.Secret = &existing
+		}
+		return tx.Update(docRef, createTournamentSecretUpdates(&secrets))
+	})
+}
+
+func (s *Firestore) DeleteTournamentSecrets(ctx context.Context, slug string) error {
+	_, err := s.secrets().Doc(slug).Delete(ctx)
+	return err
+}
+
+func (s *Firestore) GrantAccess(ctx context.Context, slug string, userID string) error {
+	docRef := s.secrets().Doc(slug)
+
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return err
+		}
+
+		var allowedUsers []string
+		// Retrieve the allowedUsers field from the document, if it exists
+		if data, err := doc.DataAt("allowedUsers"); err == nil {
+			// Type assert the data to a slice of interface{}
+			if users, ok := data.([]interface{}); ok {
+				// Convert the slice of interface{} to a slice of strings
+				for _, user := range users {
+					if userStr, ok := user.(string); ok {
+						allowedUsers = append(allowedUsers, userStr)
+					}
+				}
+			}
+		}
+
+		// Check if the userID already exists in the allowedUsers list
+		for _, user := range allowedUsers {
+			if user == userID {
+				// User already has access, so return nil to indicate no update needed
+				return nil
+			}
+		}
+
+		updatedUsers := append(allowedUsers, userID)
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "allowedUsers", Value: updatedUsers},
+		})
+	})
+}
+
+func (s *Firestore) GetSyncJob(ctx context.Context, id string) (*SyncJob, error) {
+	doc, err := s.client.Collection(syncJobsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[SyncJob](doc)
+}
+
+func (s *Firestore) ListSyncJobs(ctx context.Context, slug string) ([]*SyncJob, error) {
+	// Sorted here rather than with OrderBy to avoid a composite index.
+	docs, err := s.client.Collection(syncJobsCollection).Where("Slug", "==", slug).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*SyncJob, 0, len(docs))
+	for _, doc := range docs {
+		job, err := docTo[SyncJob](doc)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sortSyncJobs(jobs)
+	return jobs, nil
+}
+
+func (s *Firestore) SaveSyncJob(ctx context.Context, job SyncJob) error {
+	_, err := s.client.Collection(syncJobsCollection).Doc(job.ID).Set(ctx, job)
+	return err
+}
+
+func (s *Firestore) RevokeAccessCodes(ctx context.Context, slug string, at time.Time) error {
+	_, err := s.secrets().Doc(slug).Update(ctx, []firestore.Update{{Path: "AccessCodesRevokedAt", Value: at}})
+	return wrapNotFound(err)
+}
+
+func (s *Firestore) SaveAccessCode(ctx context.Context, code AccessCode) error {
+	_, err := s.client.Collection(accessCodesCollection).Doc(code.Nonce).Create(ctx, code)
+	return err
+}
+
+func (s *Firestore) UseAccessCode(ctx context.Context, nonce string, userID string, at time.Time) (*AccessCode, error) {
+	docRef := s.client.Collection(accessCodesCollection).Doc(nonce)
+	var code *AccessCode
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return wrapNotFound(err)
+		}
+		if code, err = docTo[AccessCode](doc); err != nil {
+			return err
+		}
+		if code.UsedBy == userID {
+			return nil
+		}
+		if !code.UsedAt.IsZero() {
+			return ErrAccessCodeUsed
+		}
+		code.UsedAt = at
+		code.UsedBy = userID
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "UsedAt", Value: at},
+			{Path: "UsedBy", Value: userID},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func (s *Firestore) DeleteAccessCodes(ctx context.Context, slug string) error {
+	return s.deleteAll(ctx, s.client.Collection(accessCodesCollection).Where("Slug", "==", slug))
+}
+
+func (s *Firestore) GetMember(ctx context.Context, slug string, userID string) (*Member, error) {
+	doc, err := s.members(slug).Doc(userID).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[Member](doc)
+}
+
+func (s *Firestore) ListMembers(ctx context.Context, slug string) ([]*Member, error) {
+	docs, err := s.members(slug).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*Member, 0, len(docs))
+	for _, doc := range docs {
+		member, err := docTo[Member](doc)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *Firestore) PutMember(ctx context.Context, slug string, member Member) error {
+	_, err := s.members(slug).Doc(member.UserID).Set(ctx, member)
+	return err
+}
+
+func (s *Firestore) DeleteMember(ctx context.Context, slug string, userID string) error {
+	if _, err := s.members(slug).Doc(userID).Delete(ctx); err != nil {
+		return err
+	}
+	_, err := s.secrets().Doc(slug).Update(ctx, []firestore.Update{
+		{Path: "allowedUsers", Value: firestore.ArrayRemove(userID)},
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *Firestore) DeleteMembers(ctx context.Context, slug string) error {
+	return s.deleteAll(ctx, s.members(slug).Query)
+}
+
+// deleteAll deletes the documents matching query with one BulkWriter.
+func (s *Firestore) deleteAll(ctx context.Context, query firestore.Query) error {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	writer := s.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+	var errs []error
+	for _, doc := range docs {
+		job, err := writer.Delete(doc.Ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", doc.Ref.Path, err))
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Firestore) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	_, err := s.client.Collection(auditLogCollection).Doc(entry.ID).Create(ctx, entry)
+	return err
+}
+
+func (s *Firestore) GetTournamentStats(ctx context.Context, slug string) (*TournamentStats, error) {
+	doc, err := s.client.Collection(tournamentStatsCollection).Doc(slug).Get(ctx)
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return docTo[TournamentStats](doc)
+}
+
+func (s *Firestore) ListTournamentStats(ctx context.Context, slugs []string) ([]*TournamentStats, error) {
+	if len(slugs) == 0 {
+		return []*TournamentStats{}, nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(slugs))
+	for _, slug := range slugs {
+		refs = append(refs, s.client.Collection(tournamentStatsCollection).Doc(slug))
+	}
+	docs, err := s.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*TournamentStats, 0, len(docs))
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		tournamentStats, err := docTo[TournamentStats](doc)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, tournamentStats)
+	}
+	return stats, nil
+}
+
+func (s *Firestore) PutTournamentStats(ctx context.Context, stats TournamentStats) error {
+	_, err := s.client.Collection(tournamentStatsCollection).Doc(stats.Slug).Set(ctx, stats)
+	return err
+}
+
+func (s *Firestore) AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	docRef := s.client.Collection(leasesCollection).Doc(name)
+	acquired := false
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+		doc, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		var lease *Lease
+		if doc.Exists() {
+			if lease, err = docTo[Lease](doc); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if !lease.available(holder, now) {
+			return nil
+		}
+
+		acquired = true
+		return tx.Set(docRef, Lease{Holder: holder, ExpiresAt: now.Add(ttl)})
+	})
+	return acquired, err
+}
+
+// schedulerStateDoc is the ID of the only document in the SchedulerState
+// collection.
+const schedulerStateDoc = "scheduler"
+
+func (s *Firestore) GetSchedulerState(ctx context.Context) (*SchedulerState, error) {
+	doc, err := s.client.Collection(schedulerStateCollection).Doc(schedulerStateDoc).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return &SchedulerState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return docTo[SchedulerState](doc)
+}
+
+func (s *Firestore) SaveSchedulerState(ctx context.Context, state SchedulerState) error {
+	_, err := s.client.Collection(schedulerStateCollection).Doc(schedulerStateDoc).Set(ctx, state)
+	return err
+}
+
+func sortSyncJobs(jobs []*SyncJob) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+}
+
+func docTo[T any](doc *firestore.DocumentSnapshot) (*T, error) {
+	var value T
+	if err := doc.DataTo(&value); err != nil {
+		// If this fails, we have an inconsistency error as we control both the data written to
+		// Firestore and the shape of our structs.
+		return nil, fmt.Errorf(
+			"consistency error. Converting %s to %T failed: %w",
+			doc.Ref.Path,
+			value,
+			err,
+		)
+	}
+	return &value, nil
+}
+
+func wrapNotFound(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
+
+func buildTournamentFinalizeUpdates() []firestore.Update {
+	return []firestore.Update{{Path: "IsFinalized", Value: true}}
+}
+
+func createScoreboardUpdates(update ScoreboardUpdate) []firestore.Update {
+	var updates []firestore.Update
+
+	if update.AutoReport != nil {
+		updates = append(updates, firestore.Update{Path: "AutoReport", Value: *update.AutoReport})
+	}
+	if update.AuthorMissmatches != nil {
+		updates = append(updates, firestore.Update{Path: "AuthorMissmatches", Value: *update.AuthorMissmatches})
+	}
+	if update.Invalid != nil {
+		updates = append(updates, firestore.Update{Path: "Invalid", Value: *update.Invalid})
+	}
+	if update.ReportedAt != nil {
+		updates = append(updates, firestore.Update{Path: "ReportedAt", Value: *update.ReportedAt})
+	}
+
+	return updates
+}
+
+func createTournamentUpdates(tournament *profixio.Tournament) []firestore.Update {
+	var updates []firestore.Update
+
+	if tournament.ID != nil {
+		updates = append(updates, firestore.Update{Path: "ID", Value: *tournament.ID})
+	}
+	if tournament.Name != nil {
+		updates = append(updates, firestore.Update{Path: "Name", Value: *tournament.Name})
+	}
+	if tournament.Slug != nil {
+		updates = append(updates, firestore.Update{Path: "Slug", Value: *tournament.Slug})
+	}
+	if tournament.StartDate != nil {
+		updates = append(updates, firestore.Update{Path: "StartDate", Value: *tournament.StartDate})
+	}
+	if tournament.EndDate != nil {
+		updates = append(updates, firestore.Update{Path: "EndDate", Value: *tournament.EndDate})
+	}
+	if tournament.Type != nil {
+		updates = append(updates, firestore.Update{Path: "Type", Value: *tournament.Type})
+	}
+	if tournament.Source != nil {
+		updates = append(updates, firestore.Update{Path: "Source", Value: *tournament.Source})
+	}
+	if !tournament.StatsWritten {
+		updates = append(updates, firestore.Update{Path: "StatsWritten", Value: tournament.StatsWritten})
+	}
+
+	return updates
+}
+
+func createTournamentSecretUpdates(tournament *profixio.TournamentSecrets) []firestore.Update {
+	var updates []firestore.Update
+
+	if tournament.ID != nil {
+		updates = append(updates, firestore.Update{Path: "ID", Value: *tournament.ID})
+	}
+	if tournament.Slug != nil {
+		updates = append(updates, firestore.Update{Path: "Slug", Value: *tournament.Slug})
+	}
+	if tournament.Secret != nil {
+		updates = append(updates, firestore.Update{Path: "Secret", Value: *tournament.Secret})
+	}
+
+	return updates
+}
+
+func createMatchUpdates(match *profixio.Match) []firestore.Update {
+	var updates []firestore.Update
+
+	if match.ID != nil {
+		updates = append(updates, firestore.Update{Path: "ID", Value: *match.ID})
+	}
+	if match.Txid != nil {
+		updates = append(updates, firestore.Update{Path: "Txid", Value: *match.Txid})
+	}
+	if match.TournamentID != nil {
+		updates = append(updates, firestore.Update{Path: "TournamentId", Value: *match.TournamentID})
+	}
+	if match.GameRound != nil {
+		updates = append(updates, firestore.Update{Path: "GameRound", Value: *match.GameRound})
+	}
+	if match.PlayoffLevel != nil {
+		updates = append(updates, firestore.Update{Path: "PlayoffLevel", Value: *match.PlayoffLevel})
+	}
+	if match.Number != nil {
+		updates = append(updates, firestore.Update{Path: "Number", Value: *match.Number})
+	}
+	if match.Name != nil {
+		updates = append(updates, firestore.Update{Path: "Name", Value: *match.Name})
+	}
+	if match.Date != nil {
+		updates = append(updates, firestore.Update{Path: "Date", Value: *match.Date})
+	}
+	if match.Time != nil {
+		updates = append(updates, firestore.Update{Path: "Time", Value: *match.Time})
+	}
+	if match.WinnerTeam != nil {
+		updates = append(updates, firestore.Update{Path: "WinnerTeam", Value: *match.WinnerTeam})
+	}
+	if match.SettResultsFormatted != nil {
+		updates = append(updates, firestore.Update{Path: "SettResultsFormatted", Value: *match.SettResultsFormatted})
+	}
+	if match.MatchDataUpdated != nil {
+		updates = append(updates, firestore.Update{Path: "MatchDataUpdated", Value: *match.MatchDataUpdated})
+	}
+	if match.ResultsUpdated != nil {
+		updates = append(updates, firestore.Update{Path: "ResultsUpdated", Value: *match.ResultsUpdated})
+	}
+	if match.HasWinner != nil {
+		updates = append(updates, firestore.Update{Path: "HasWinner", Value: *match.HasWinner})
+	}
+	if match.IsHidden != nil {
+		updates = append(updates, firestore.Update{Path: "IsHidden", Value: *match.IsHidden})
+	}
+	if match.IsGroupPlay != nil {
+		updates = append(updates, firestore.Update{Path: "IsGroupPlay", Value: *match.IsGroupPlay})
+	}
+	if match.IsPlayoff != nil {
+		updates = append(updates, firestore.Update{Path: "IsPlayoff", Value: *match.IsPlayoff})
+	}
+	if match.IncludedInTableCalculation != nil {
+		updates = append(updates, firestore.Update{Path: "IncludedInTableCalculation", Value: *match.IncludedInTableCalculation})
+	}
+	if match.HomeTeam != nil {
+		updates = append(updates, firestore.Update{Path: "HomeTeam", Value: match.HomeTeam})
+	}
+	if match.AwayTeam != nil {
+		updates = append(updates, firestore.Update{Path: "AwayTeam", Value: match.AwayTeam})
+	}
+	if match.Field != nil {
+		updates = append(updates, firestore.Update{Path: "Field", Value: match.Field})
+	}
+	if match.MatchGroup != nil {
+		updates = append(updates, firestore.Update{Path: "MatchGroup", Value: match.MatchGroup})
+	}
+	if match.MatchCategory != nil {
+		updates = append(updates, firestore.Update{Path: "MatchCategory", Value: match.MatchCategory})
+	}
+	if match.Sets != nil {
+		updates = append(updates, firestore.Update{Path: "Sets", Value: match.Sets})
+	}
+	if match.RefereesTX != nil {
+		updates = append(updates, firestore.Update{Path: "RefereesTX", Value: match.RefereesTX})
+	}
+
+	return updates
+}
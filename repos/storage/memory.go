@@ -0,0 +1,793 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+)
+
+// Memory is an in-memory Store for tests. It mirrors the update semantics of
+// the Firestore implementation.
+type Memory struct {
+	mu          sync.Mutex
+	tournaments map[string]*Tournament
+	matches     map[string]map[string]*Match
+	scoreboards map[string]*Scoreboard
+	events      map[string][]Event
+	secrets     map[string]*TournamentSecrets
+	syncJobs    map[string]*SyncJob
+	leases      map[string]*Lease
+	scheduler   SchedulerState
+	stats       map[string]*TournamentStats
+	accessCodes map[string]*AccessCode
+	members     map[string]map[string]*Member
+	audit       []AuditEntry
+	// matchWrites counts the matches written by PutMatches.
+	matchWrites int
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		tournaments: map[string]*Tournament{},
+		matches:     map[string]map[string]*Match{},
+		scoreboards: map[string]*Scoreboard{},
+		events:      map[string][]Event{},
+		secrets:     map[string]*TournamentSecrets{},
+		syncJobs:    map[string]*SyncJob{},
+		leases:      map[string]*Lease{},
+		stats:       map[string]*TournamentStats{},
+		accessCodes: map[string]*AccessCode{},
+		members:     map[string]map[string]*Member{},
+	}
+}
+
+// SeedTournament stores a tournament document as is.
+func (s *Memory) SeedTournament(tournament Tournament) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tournaments[tournament.Slug] = &tournament
+}
+
+// SeedMatch stores a tournament match document as is.
+func (s *Memory) SeedMatch(slug string, match Match) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.matches[slug] == nil {
+		s.matches[slug] = map[string]*Match{}
+	}
+	s.matches[slug][deref(match.Number)] = &match
+}
+
+// SeedScoreboard stores a scoreboard document as is.
+func (s *Memory) SeedScoreboard(id string, scoreboard Scoreboard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scoreboards[id] = &scoreboard
+}
+
+// SeedTournamentSecrets stores a tournament secrets document as is.
+func (s *Memory) SeedTournamentSecrets(secrets TournamentSecrets) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[secrets.Slug] = &secrets
+}
+
+// MatchWrites returns how many matches PutMatches has written.
+func (s *Memory) MatchWrites() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.matchWrites
+}
+
+// AuditEntries returns the recorded audit entries in the order they were
+// recorded.
+func (s *Memory) AuditEntries() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditEntry(nil), s.audit...)
+}
+
+func (s *Memory) GetTournament(_ context.Context, slug string) (*Tournament, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tournament, ok := s.tournaments[slug]
+	if !ok {
+		return nil, notFound("tournament", slug)
+	}
+	copied := *tournament
+	return &copied, nil
+}
+
+func (s *Memory) GetTournamentID(ctx context.Context, slug string) (int, error) {
+	tournament, err := s.GetTournament(ctx, slug)
+	if err != nil {
+		return -1, err
+	}
+	return tournament.ID, nil
+}
+
+func (s *Memory) ListTournaments(_ context.Context, filter TournamentFilter) ([]*Tournament, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tournaments := []*Tournament{}
+	for _, tournament := range s.tournaments {
+		if !filter.matches(tournament) {
+			continue
+		}
+		copied := *tournament
+		tournaments = append(tournaments, &copied)
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		return tournaments[i].Slug < tournaments[j].Slug
+	})
+	return tournaments, nil
+}
+
+func (s *Memory) PageTournaments(ctx context.Context, filter TournamentFilter, page TournamentPage) ([]*Tournament, error) {
+	tournaments, err := s.ListTournaments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		return tournamentBefore(tournaments[i].StartDate, tournaments[i].Slug, tournaments[j].StartDate, tournaments[j].Slug)
+	})
+
+	if page.AfterSlug != "" {
+		tournaments = slices.DeleteFunc(tournaments, func(tournament *Tournament) bool {
+			return !tournamentBefore(page.AfterStartDate, page.AfterSlug, tournament.StartDate, tournament.Slug)
+		})
+	}
+	if page.Limit > 0 && len(tournaments) > page.Limit {
+		tournaments = tournaments[:page.Limit]
+	}
+	return tournaments, nil
+}
+
+func (s *Memory) SumTournaments(ctx context.Context, filter TournamentFilter) (*TournamentTotals, error) {
+	tournaments, err := s.ListTournaments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := &TournamentTotals{Tournaments: len(tournaments)}
+	for _, tournament := range tournaments {
+		totals.NumberOfScoreboards += tournament.NumberOfScoreboards
+		totals.NumberOfMatches += tournament.NumberOfMatches
+	}
+	return totals, nil
+}
+
+// tournamentBefore orders tournaments by StartDate and slug, like
+// PageTournaments.
+func tournamentBefore(startDate, slug, otherStartDate, otherSlug string) bool {
+	if startDate != otherStartDate {
+		return startDate < otherStartDate
+	}
+	return slug < otherSlug
+}
+
+// matches reports whether the tournament passes the filter.
+func (filter TournamentFilter) matches(tournament *Tournament) bool {
+	switch {
+	case filter.EndsBefore != "" && !(tournament.EndDate < filter.EndsBefore):
+		return false
+	case filter.StatsWritten != nil && tournament.StatsWritten != *filter.StatsWritten:
+		return false
+	case filter.HasScoreboards && tournament.NumberOfScoreboards <= 0:
+		return false
+	case filter.ActiveOn != "" && (tournament.StartDate > filter.ActiveOn || tournament.EndDate < filter.ActiveOn):
+		return false
+	case filter.Source != "" && tournament.Source != filter.Source:
+		return false
+	case filter.Type != "" && tournament.Type != filter.Type:
+		return false
+	case filter.Organisation != "" && !strings.HasPrefix(tournament.Source, filter.Organisation+"/"):
+		return false
+	case filter.StartsFrom != "" && tournament.StartDate < filter.StartsFrom:
+		return false
+	case filter.StartsTo != "" && tournament.StartDate > filter.StartsTo:
+		return false
+	}
+	return true
+}
+
+func (s *Memory) PutTournament(_ context.Context, tournament profixio.Tournament) error {
+	if tournament.Slug == nil {
+		return fmt.Errorf("put tournament: missing slug")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tournaments[*tournament.Slug]
+	if !ok {
+		stored = &Tournament{}
+		s.tournaments[*tournament.Slug] = stored
+	}
+	if tournament.ID != nil {
+		stored.ID = *tournament.ID
+	}
+	if tournament.Name != nil {
+		stored.Name = *tournament.Name
+	}
+	if tournament.Slug != nil {
+		stored.Slug = *tournament.Slug
+	}
+	if tournament.StartDate != nil {
+		stored.StartDate = *tournament.StartDate
+	}
+	if tournament.EndDate != nil {
+		stored.EndDate = *tournament.EndDate
+	}
+	if tournament.Type != nil {
+		stored.Type = *tournament.Type
+	}
+	if tournament.Source != nil {
+		stored.Source = *tournament.Source
+	}
+	if !tournament.StatsWritten {
+		stored.StatsWritten = false
+	}
+	return nil
+}
+
+func (s *Memory) PutCustomTournament(_ context.Context, tournament Tournament) error {
+	if tournament.Slug == "" {
+		return fmt.Errorf("put custom tournament: missing slug")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tournaments[tournament.Slug]
+	if !ok {
+		stored = &Tournament{Slug: tournament.Slug}
+		s.tournaments[tournament.Slug] = stored
+	}
+	stored.ID = tournament.ID
+	stored.Name = tournament.Name
+	stored.Type = tournament.Type
+	stored.StartDate = tournament.StartDate
+	stored.EndDate = tournament.EndDate
+	stored.EventType = tournament.EventType
+	stored.Categories = append([]string(nil), tournament.Categories...)
+	stored.Scoring = tournament.Scoring
+	stored.CategoryScoring = maps.Clone(tournament.CategoryScoring)
+	return nil
+}
+
+func (s *Memory) DeleteTournament(_ context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tournaments, slug)
+	return nil
+}
+
+func (s *Memory) SetLastSynced(_ context.Context, slug string, lastSynced string) error {
+	return s.updateTournament(slug, func(t *Tournament) { t.LastSynced = lastSynced })
+}
+
+func (s *Memory) SetLastRequest(_ context.Context, slug string, lastRequest string) error {
+	return s.updateTournament(slug, func(t *Tournament) { t.LastRequest = lastRequest })
+}
+
+func (s *Memory) SetNumberOfMatches(_ context.Context, slug string, numberOfMatches int) error {
+	return s.updateTournament(slug, func(t *Tournament) { t.NumberOfMatches = numberOfMatches })
+}
+
+func (s *Memory) SetSyncOutcome(_ context.Context, slug string, outcome SyncOutcome) error {
+	return s.updateTournament(slug, func(t *Tournament) {
+		t.LastSyncStatus = outcome.Status
+		t.LastSyncError = outcome.Error
+		t.LastSyncAt = outcome.At
+	})
+}
+
+func (s *Memory) IncrementNumberOfMatches(_ context.Context, slug string, delta int) error {
+	return s.updateTournament(slug, func(t *Tournament) { t.NumberOfMatches += delta })
+}
+
+func (s *Memory) WriteStats(_ context.Context, slug string, numberOfScoreboards int, numberOfMatches int) error {
+	return s.updateTournament(slug, func(t *Tournament) {
+		t.NumberOfScoreboards = numberOfScoreboards
+		t.NumberOfMatches = numberOfMatches
+		t.StatsWritten = true
+	})
+}
+
+func (s *Memory) updateTournament(slug string, update func(*Tournament)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tournament, ok := s.tournaments[slug]
+	if !ok {
+		return notFound("tournament", slug)
+	}
+	update(tournament)
+	return nil
+}
+
+func (s *Memory) GetMatch(_ context.Context, slug string, number string) (*Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match, ok := s.matches[slug][number]
+	if !ok {
+		return nil, notFound("match", slug+"/"+number)
+	}
+	copied := *match
+	return &copied, nil
+}
+
+func (s *Memory) ListMatches(_ context.Context, slug string) ([]*Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := make([]*Match, 0, len(s.matches[slug]))
+	for _, match := range s.matches[slug] {
+		copied := *match
+		matches = append(matches, &copied)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return deref(matches[i].Number) < deref(matches[j].Number)
+	})
+	return matches, nil
+}
+
+func (s *Memory) CountMatches(_ context.Context, slug string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.matches[slug]), nil
+}
+
+func (s *Memory) PutMatches(_ context.Context, slug string, matches []profixio.Match) ([]profixio.MatchDiff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var diffs []profixio.MatchDiff
+	var errs []error
+
+	if s.matches[slug] == nil {
+		s.matches[slug] = map[string]*Match{}
+	}
+	for _, match := range matches {
+		if match.Number == nil {
+			errs = append(errs, fmt.Errorf("put match: missing number"))
+			continue
+		}
+		stored, ok := s.matches[slug][*match.Number]
+		if ok {
+			diff := profixio.DiffMatch(stored.Match, match)
+			if !diff.Unchanged() {
+				mergeMatch(&stored.Match, match)
+				s.matchWrites++
+			}
+			diffs = append(diffs, diff)
+			continue
+		}
+		s.matches[slug][*match.Number] = &Match{Match: match}
+		s.matchWrites++
+		diffs = append(diffs, profixio.MatchDiff{Number: *match.Number, Created: true})
+	}
+	return diffs, errors.Join(errs...)
+}
+
+func (s *Memory) UpdateMatch(_ context.Context, slug string, number string, match profixio.Match) error {
+	return s.updateMatch(slug, number, func(m *Match) { mergeMatch(&m.Match, match) })
+}
+
+func (s *Memory) SetMatchResultValid(_ context.Context, slug string, number string, valid bool) error {
+	return s.updateMatch(slug, number, func(m *Match) { m.MatchResultValid = valid })
+}
+
+func (s *Memory) SetMatchFinalized(_ context.Context, slug string, number string) error {
+	return s.updateMatch(slug, number, func(m *Match) { m.IsFinalized = true })
+}
+
+func (s *Memory) DeleteMatches(_ context.Context, slug string, numbers []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, number := range numbers {
+		delete(s.matches[slug], number)
+	}
+	return nil
+}
+
+func (s *Memory) updateMatch(slug string, number string, update func(*Match)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	match, ok := s.matches[slug][number]
+	if !ok {
+		return notFound("match", slug+"/"+number)
+	}
+	update(match)
+	return nil
+}
+
+func (s *Memory) GetScoreboard(_ context.Context, id string) (*Scoreboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scoreboard, ok := s.scoreboards[id]
+	if !ok {
+		return nil, notFound("scoreboard", id)
+	}
+	copied := *scoreboard
+	return &copied, nil
+}
+
+func (s *Memory) UpdateScoreboard(_ context.Context, id string, update ScoreboardUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scoreboard, ok := s.scoreboards[id]
+	if !ok {
+		return notFound("scoreboard", id)
+	}
+	if update.AutoReport != nil {
+		scoreboard.AutoReport = *update.AutoReport
+	}
+	if update.AuthorMissmatches != nil {
+		scoreboard.AuthorMissmatches = *update.AuthorMissmatches
+	}
+	if update.Invalid != nil {
+		scoreboard.Invalid = *update.Invalid
+	}
+	if update.ReportedAt != nil {
+		scoreboard.ReportedAt = *update.ReportedAt
+	}
+	return nil
+}
+
+func (s *Memory) ListEvents(_ context.Context, id string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event{}, s.events[id]...), nil
+}
+
+func (s *Memory) AppendEvent(_ context.Context, id string, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.events[id] {
+		if existing.ID == event.ID {
+			s.events[id][i] = event
+			return nil
+		}
+	}
+	s.events[id] = append(s.events[id], event)
+	return nil
+}
+
+func (s *Memory) GetTournamentSecrets(_ context.Context, slug string) (*TournamentSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, ok := s.secrets[slug]
+	if !ok {
+		return nil, notFound("tournament secrets", slug)
+	}
+	copied := *secrets
+	copied.AllowedUsers = append([]string(nil), secrets.AllowedUsers...)
+	return &copied, nil
+}
+
+func (s *Memory) ListTournamentSecrets(_ context.Context) ([]*TournamentSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*TournamentSecrets, 0, len(s.secrets))
+	for _, secrets := range s.secrets {
+		copied := *secrets
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Slug < list[j].Slug
+	})
+	return list, nil
+}
+
+func (s *Memory) EnsureTournamentSecrets(_ context.Context, secrets profixio.TournamentSecrets) error {
+	if secrets.Slug == nil {
+		return fmt.Errorf("ensure tournament secrets: missing slug")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.secrets[*secrets.Slug]
+	if !ok {
+		stored = &TournamentSecrets{}
+		s.secrets[*secrets.Slug] = stored
+	}
+	if secrets.ID != nil {
+		stored.ID = *secrets.ID
+	}
+	stored.Slug = *secrets.Slug
+	if stored.Secret == "" && secrets.Secret != nil {
+		stored.Secret = *secrets.Secret
+	}
+	return nil
+}
+
+func (s *Memory) DeleteTournamentSecrets(_ context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, slug)
+	return nil
+}
+
+func (s *Memory) GrantAccess(_ context.Context, slug string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, ok := s.secrets[slug]
+	if !ok {
+		return notFound("tournament secrets", slug)
+	}
+	for _, user := range secrets.AllowedUsers {
+		if user == userID {
+			return nil
+		}
+	}
+	secrets.AllowedUsers = append(secrets.AllowedUsers, userID)
+	return nil
+}
+
+// mergeMatch copies every field that is set on src onto dst, like the
+// Firestore update built by createMatchUpdates.
+func (s *Memory) GetSyncJob(_ context.Context, id string) (*SyncJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.syncJobs[id]
+	if !ok {
+		return nil, notFound("sync job", id)
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *Memory) ListSyncJobs(_ context.Context, slug string) ([]*SyncJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []*SyncJob{}
+	for _, job := range s.syncJobs {
+		if job.Slug == slug {
+			copied := *job
+			list = append(list, &copied)
+		}
+	}
+	sortSyncJobs(list)
+	return list, nil
+}
+
+func (s *Memory) SaveSyncJob(_ context.Context, job SyncJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncJobs[job.ID] = &job
+	return nil
+}
+
+func (s *Memory) RevokeAccessCodes(_ context.Context, slug string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, ok := s.secrets[slug]
+	if !ok {
+		return notFound("tournament secrets", slug)
+	}
+	secrets.AccessCodesRevokedAt = at
+	return nil
+}
+
+func (s *Memory) SaveAccessCode(_ context.Context, code AccessCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accessCodes[code.Nonce]; ok {
+		return fmt.Errorf("access code %s already exists", code.Nonce)
+	}
+	s.accessCodes[code.Nonce] = &code
+	return nil
+}
+
+func (s *Memory) UseAccessCode(_ context.Context, nonce string, userID string, at time.Time) (*AccessCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.accessCodes[nonce]
+	if !ok {
+		return nil, notFound("access code", nonce)
+	}
+	if code.UsedBy != userID {
+		if !code.UsedAt.IsZero() {
+			return nil, ErrAccessCodeUsed
+		}
+		code.UsedAt = at
+		code.UsedBy = userID
+	}
+	copied := *code
+	return &copied, nil
+}
+
+func (s *Memory) DeleteAccessCodes(_ context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for nonce, code := range s.accessCodes {
+		if code.Slug == slug {
+			delete(s.accessCodes, nonce)
+		}
+	}
+	return nil
+}
+
+func (s *Memory) GetMember(_ context.Context, slug string, userID string) (*Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.members[slug][userID]
+	if !ok {
+		return nil, notFound("member", slug+"/"+userID)
+	}
+	copied := *member
+	copied.Fields = append([]string(nil), member.Fields...)
+	return &copied, nil
+}
+
+func (s *Memory) ListMembers(_ context.Context, slug string) ([]*Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Member, 0, len(s.members[slug]))
+	for _, member := range s.members[slug] {
+		copied := *member
+		copied.Fields = append([]string(nil), member.Fields...)
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UserID < list[j].UserID
+	})
+	return list, nil
+}
+
+func (s *Memory) PutMember(_ context.Context, slug string, member Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.members[slug] == nil {
+		s.members[slug] = map[string]*Member{}
+	}
+	member.Fields = append([]string(nil), member.Fields...)
+	s.members[slug][member.UserID] = &member
+	return nil
+}
+
+func (s *Memory) DeleteMember(_ context.Context, slug string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.members[slug], userID)
+	if secrets, ok := s.secrets[slug]; ok {
+		secrets.AllowedUsers = slices.DeleteFunc(secrets.AllowedUsers, func(user string) bool {
+			return user == userID
+		})
+	}
+	return nil
+}
+
+func (s *Memory) DeleteMembers(_ context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.members, slug)
+	return nil
+}
+
+func (s *Memory) RecordAudit(_ context.Context, entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func (s *Memory) GetTournamentStats(_ context.Context, slug string) (*TournamentStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[slug]
+	if !ok {
+		return nil, notFound("tournament stats", slug)
+	}
+	copied := *stats
+	return &copied, nil
+}
+
+func (s *Memory) ListTournamentStats(_ context.Context, slugs []string) ([]*TournamentStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]*TournamentStats, 0, len(slugs))
+	for _, slug := range slugs {
+		tournamentStats, ok := s.stats[slug]
+		if !ok {
+			continue
+		}
+		copied := *tournamentStats
+		stats = append(stats, &copied)
+	}
+	return stats, nil
+}
+
+func (s *Memory) PutTournamentStats(_ context.Context, stats TournamentStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats[stats.Slug] = &stats
+	return nil
+}
+
+func (s *Memory) AcquireLease(_ context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !s.leases[name].available(holder, now) {
+		return false, nil
+	}
+	s.leases[name] = &Lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *Memory) GetSchedulerState(_ context.Context) (*SchedulerState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.scheduler
+	return &state, nil
+}
+
+func (s *Memory) SaveSchedulerState(_ context.Context, state SchedulerState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scheduler = state
+	return nil
+}
+
+func mergeMatch(dst *profixio.Match, src profixio.Match) {
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src)
+	for i := 0; i < srcValue.NumField(); i++ {
+		if !srcValue.Field(i).IsNil() {
+			dstValue.Field(i).Set(srcValue.Field(i))
+		}
+	}
+}
+
+func notFound(kind string, key string) error {
+	return fmt.Errorf("%w: %s %s", ErrNotFound, kind, key)
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
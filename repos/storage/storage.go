@@ -0,0 +1,467 @@
+// Package storage is the persistence layer for tournaments, matches,
+// scoreboards and tournament secrets. Store has a Firestore implementation
+// for production and an in-memory implementation for tests.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+)
+
+// ErrNotFound is returned when a requested document does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAccessCodeUsed is returned when an access code has already been used.
+var ErrAccessCodeUsed = errors.New("access code already used")
+
+// Store is the full storage interface the services depend on.
+type Store interface {
+	TournamentStore
+	MatchStore
+	ScoreboardStore
+	SecretStore
+	SyncJobStore
+	LeaseStore
+	SchedulerStateStore
+	StatsStore
+	AccessCodeStore
+	MemberStore
+	AuditStore
+}
+
+// StatsStore persists documents in the TournamentStats collection.
+type StatsStore interface {
+	GetTournamentStats(ctx context.Context, slug string) (*TournamentStats, error)
+	// ListTournamentStats returns the stats of the tournaments with the
+	// slugs. Tournaments without stats are left out.
+	ListTournamentStats(ctx context.Context, slugs []string) ([]*TournamentStats, error)
+	// PutTournamentStats creates or replaces the stats of the tournament.
+	PutTournamentStats(ctx context.Context, stats TournamentStats) error
+}
+
+// TournamentStore persists documents in the Tournaments collection.
+type TournamentStore interface {
+	GetTournament(ctx context.Context, slug string) (*Tournament, error)
+	GetTournamentID(ctx context.Context, slug string) (int, error)
+	ListTournaments(ctx context.Context, filter TournamentFilter) ([]*Tournament, error)
+	// PageTournaments returns the tournaments matching the filter in
+	// StartDate and slug order, from after the page's position.
+	PageTournaments(ctx context.Context, filter TournamentFilter, page TournamentPage) ([]*Tournament, error)
+	// SumTournaments counts the tournaments matching the filter and sums
+	// their scoreboard counts.
+	SumTournaments(ctx context.Context, filter TournamentFilter) (*TournamentTotals, error)
+	// PutTournament creates the tournament, or updates the fields that are
+	// set on it if it already exists.
+	PutTournament(ctx context.Context, tournament profixio.Tournament) error
+	// PutCustomTournament creates the tournament or replaces its details: ID,
+	// name, type, dates, event type and categories. Counters and sync state
+	// are kept.
+	PutCustomTournament(ctx context.Context, tournament Tournament) error
+	DeleteTournament(ctx context.Context, slug string) error
+	SetLastSynced(ctx context.Context, slug string, lastSynced string) error
+	SetLastRequest(ctx context.Context, slug string, lastRequest string) error
+	SetNumberOfMatches(ctx context.Context, slug string, numberOfMatches int) error
+	// IncrementNumberOfMatches adds delta to NumberOfMatches without reading it.
+	IncrementNumberOfMatches(ctx context.Context, slug string, delta int) error
+	// SetSyncOutcome records how the last match sync of the tournament ended.
+	SetSyncOutcome(ctx context.Context, slug string, outcome SyncOutcome) error
+	// WriteStats stores the scoreboard counts and marks the stats as written.
+	WriteStats(ctx context.Context, slug string, numberOfScoreboards int, numberOfMatches int) error
+}
+
+// MatchStore persists the Matches subcollection of a tournament.
+type MatchStore interface {
+	GetMatch(ctx context.Context, slug string, number string) (*Match, error)
+	ListMatches(ctx context.Context, slug string) ([]*Match, error)
+	CountMatches(ctx context.Context, slug string) (int, error)
+	// PutMatches creates each match, or updates the fields that are set on it
+	// if it already exists, and returns what changed for every match stored.
+	// Matches that would not change are not written. Writes are batched, so
+	// one failing match does not stop the others; their errors are joined in
+	// the returned error.
+	PutMatches(ctx context.Context, slug string, matches []profixio.Match) ([]profixio.MatchDiff, error)
+	// UpdateMatch updates the fields that are set on an existing match.
+	UpdateMatch(ctx context.Context, slug string, number string, match profixio.Match) error
+	SetMatchResultValid(ctx context.Context, slug string, number string, valid bool) error
+	SetMatchFinalized(ctx context.Context, slug string, number string) error
+	// DeleteMatches deletes the tournament's matches with the given numbers.
+	// Numbers that do not exist are ignored.
+	DeleteMatches(ctx context.Context, slug string, numbers []string) error
+}
+
+// ScoreboardStore persists scoreboard documents in the Matches collection and
+// their event streams.
+type ScoreboardStore interface {
+	GetScoreboard(ctx context.Context, id string) (*Scoreboard, error)
+	UpdateScoreboard(ctx context.Context, id string, update ScoreboardUpdate) error
+	ListEvents(ctx context.Context, id string) ([]Event, error)
+	AppendEvent(ctx context.Context, id string, event Event) error
+}
+
+// SecretStore persists documents in the TournamentSecrets collection.
+type SecretStore interface {
+	GetTournamentSecrets(ctx context.Context, slug string) (*TournamentSecrets, error)
+	ListTournamentSecrets(ctx context.Context) ([]*TournamentSecrets, error)
+	// EnsureTournamentSecrets writes the ID and slug, and the secret only if
+	// the stored document does not already have one.
+	EnsureTournamentSecrets(ctx context.Context, secrets profixio.TournamentSecrets) error
+	DeleteTournamentSecrets(ctx context.Context, slug string) error
+	// GrantAccess adds the user to the tournament's allowed users.
+	GrantAccess(ctx context.Context, slug string, userID string) error
+	// RevokeAccessCodes invalidates the tournament's access codes issued
+	// before at.
+	RevokeAccessCodes(ctx context.Context, slug string, at time.Time) error
+}
+
+// AccessCodeStore persists documents in the AccessCodes collection.
+type AccessCodeStore interface {
+	SaveAccessCode(ctx context.Context, code AccessCode) error
+	// UseAccessCode marks the code as used by the user and returns it. It
+	// returns ErrAccessCodeUsed if another user has used the code.
+	UseAccessCode(ctx context.Context, nonce string, userID string, at time.Time) (*AccessCode, error)
+	// DeleteAccessCodes removes the records of the tournament's access codes,
+	// so codes issued before it was deleted cannot be used.
+	DeleteAccessCodes(ctx context.Context, slug string) error
+}
+
+// MemberStore persists the Members subcollection of tournament secrets.
+type MemberStore interface {
+	GetMember(ctx context.Context, slug string, userID string) (*Member, error)
+	// ListMembers returns the tournament's members ordered by user ID.
+	ListMembers(ctx context.Context, slug string) ([]*Member, error)
+	// PutMember creates or replaces the user's membership of the tournament.
+	PutMember(ctx context.Context, slug string, member Member) error
+	// DeleteMember removes the membership, and the user from the
+	// tournament's allowed users.
+	DeleteMember(ctx context.Context, slug string, userID string) error
+	// DeleteMembers removes every membership of the tournament. Deleting the
+	// tournament secrets does not delete the subcollection.
+	DeleteMembers(ctx context.Context, slug string) error
+}
+
+// AuditStore persists documents in the AuditLog collection.
+type AuditStore interface {
+	RecordAudit(ctx context.Context, entry AuditEntry) error
+}
+
+// SyncJobStore persists documents in the SyncJobs collection.
+type SyncJobStore interface {
+	GetSyncJob(ctx context.Context, id string) (*SyncJob, error)
+	// ListSyncJobs returns the tournament's jobs, most recently started first.
+	ListSyncJobs(ctx context.Context, slug string) ([]*SyncJob, error)
+	// SaveSyncJob creates or replaces the job.
+	SaveSyncJob(ctx context.Context, job SyncJob) error
+}
+
+// LeaseStore persists documents in the Leases collection. A lease lets one
+// of several instances act as leader for a named task.
+type LeaseStore interface {
+	// AcquireLease takes or renews the named lease for holder until now+ttl.
+	// It reports false if another holder has an unexpired lease.
+	AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+}
+
+// SchedulerStateStore persists the scheduler's document in the
+// SchedulerState collection.
+type SchedulerStateStore interface {
+	// GetSchedulerState returns the zero state if none has been saved.
+	GetSchedulerState(ctx context.Context) (*SchedulerState, error)
+	SaveSchedulerState(ctx context.Context, state SchedulerState) error
+}
+
+// Tournament is a document in the Tournaments collection.
+type Tournament struct {
+	ID                  int    `firestore:"ID"`
+	Name                string `firestore:"Name"`
+	Type                string `firestore:"Type"`
+	Slug                string `firestore:"Slug"`
+	StartDate           string `firestore:"StartDate"`
+	EndDate             string `firestore:"EndDate"`
+	StatsWritten        bool   `firestore:"StatsWritten"`
+	NumberOfMatches     int    `firestore:"NumberOfMatches"`
+	NumberOfScoreboards int    `firestore:"NumberOfScoreboards"`
+	LastSynced          string `firestore:"LastSynced"`
+	LastRequest         string `firestore:"LastRequest"`
+	LastSyncStatus      string `firestore:"LastSyncStatus"`
+	LastSyncError       string `firestore:"LastSyncError"`
+	LastSyncAt          string `firestore:"LastSyncAt"`
+	// Source is the ID of the Profixio source the tournament was synced
+	// from, such as "NVBF.NO.VB/SVB". It is empty for custom tournaments.
+	Source string `firestore:"Source"`
+	// EventType and Categories describe custom tournaments, for example
+	// "beach" and ["Men", "Women"].
+	EventType  string   `firestore:"EventType"`
+	Categories []string `firestore:"Categories"`
+	// Scoring are the rules results are validated against. CategoryScoring
+	// overrides them for matches in the named categories.
+	Scoring         *scoring.Rules           `firestore:"Scoring"`
+	CategoryScoring map[string]scoring.Rules `firestore:"CategoryScoring"`
+}
+
+// ScoringRules returns the rules for matches in the given category. Without
+// rules of its own a tournament is scored as beach volleyball.
+func (t Tournament) ScoringRules(category string) scoring.Rules {
+	if rules, ok := t.CategoryScoring[category]; ok && category != "" {
+		return rules
+	}
+	if t.Scoring != nil {
+		return *t.Scoring
+	}
+	return scoring.Beach()
+}
+
+// TournamentTypeCustom is the Type of tournaments that are not synced from
+// Profixio.
+const TournamentTypeCustom = "Custom"
+
+// Values of Tournament.LastSyncStatus.
+const (
+	SyncStatusSucceeded = "succeeded"
+	SyncStatusFailed    = "failed"
+)
+
+// SyncOutcome is the result of a match sync, stored on the tournament so
+// organisers can see when a sync failed.
+type SyncOutcome struct {
+	Status string
+	Error  string
+	At     string
+}
+
+// TournamentFilter narrows ListTournaments, PageTournaments and
+// SumTournaments. Zero values do not filter. ActiveOn is only supported by
+// ListTournaments.
+type TournamentFilter struct {
+	// EndsBefore keeps tournaments whose EndDate is before this YYYY-MM-DD date.
+	EndsBefore string
+	// StatsWritten keeps tournaments whose StatsWritten flag has this value.
+	StatsWritten *bool
+	// HasScoreboards keeps tournaments with at least one scoreboard.
+	HasScoreboards bool
+	// ActiveOn keeps tournaments whose StartDate..EndDate window contains this
+	// YYYY-MM-DD date.
+	ActiveOn string
+	// Source keeps tournaments synced from the source with this ID.
+	Source string
+	// Type keeps tournaments of this Type, such as TournamentTypeCustom.
+	Type string
+	// Organisation keeps tournaments synced from sources of the Profixio
+	// organisation, such as "NVBF.NO.VB".
+	Organisation string
+	// StartsFrom and StartsTo keep tournaments whose StartDate is within
+	// these YYYY-MM-DD dates, both included.
+	StartsFrom string
+	StartsTo   string
+}
+
+// TournamentPage is a page of PageTournaments.
+type TournamentPage struct {
+	// AfterStartDate and AfterSlug are the position of the last tournament
+	// of the previous page. An empty AfterSlug starts at the first
+	// tournament.
+	AfterStartDate string
+	AfterSlug      string
+	// Limit is the most tournaments returned. Zero returns all of them.
+	Limit int
+}
+
+// TournamentTotals is the result of SumTournaments.
+type TournamentTotals struct {
+	Tournaments         int
+	NumberOfScoreboards int
+	NumberOfMatches     int
+}
+
+// Match is a document in a tournament's Matches subcollection.
+type Match struct {
+	profixio.Match
+	ScoreboardId     string `firestore:"ScoreboardId"`
+	MatchResultValid bool   `firestore:"MatchResultValid"`
+	IsFinalized      bool   `firestore:"IsFinalized"`
+}
+
+// Scoreboard is a document in the Matches collection, written by the
+// scoreboard app for every match it is used on.
+type Scoreboard struct {
+	MatchNumber       string `firestore:"matchId"`
+	TournamentSlug    string `firestore:"tournamentId"`
+	AutoReport        bool   `firestore:"AutoReport"`
+	AuthorMissmatches int    `firestore:"AuthorMissmatches"`
+	Invalid           bool   `firestore:"Invalid"`
+	// ReportedAt is when the result was posted to Profixio.
+	ReportedAt time.Time `firestore:"ReportedAt"`
+}
+
+// ScoreboardUpdate holds the scoreboard fields to update. Nil fields are left
+// unchanged.
+type ScoreboardUpdate struct {
+	AutoReport        *bool
+	AuthorMissmatches *int
+	Invalid           *bool
+	ReportedAt        *time.Time
+}
+
+// Event is a document in a scoreboard's events subcollection.
+type Event struct {
+	Author    string `firestore:"author"`
+	EventType string `firestore:"eventType"`
+	ID        string `firestore:"id"`
+	PlayerID  int    `firestore:"playerId"`
+	Reference string `firestore:"reference"`
+	Team      string `firestore:"team"`
+	Timestamp int64  `firestore:"timestamp"`
+	Undone    string `firestore:"undone"`
+}
+
+// TournamentSecrets is a document in the TournamentSecrets collection.
+type TournamentSecrets struct {
+	ID           int      `firestore:"ID"`
+	Slug         string   `firestore:"Slug"`
+	Secret       string   `firestore:"Secret"`
+	AllowedUsers []string `firestore:"allowedUsers"`
+	// AccessCodesRevokedAt invalidates the access codes issued before it.
+	AccessCodesRevokedAt time.Time `firestore:"AccessCodesRevokedAt"`
+}
+
+// AccessCode is a document in the AccessCodes collection, keyed by the nonce
+// of the access code it records.
+type AccessCode struct {
+	Nonce     string    `firestore:"Nonce"`
+	Slug      string    `firestore:"Slug"`
+	Email     string    `firestore:"Email"`
+	IssuedAt  time.Time `firestore:"IssuedAt"`
+	ExpiresAt time.Time `firestore:"ExpiresAt"`
+	// UsedAt and UsedBy are set once the code has given a user access.
+	UsedAt time.Time `firestore:"UsedAt"`
+	UsedBy string    `firestore:"UsedBy"`
+}
+
+// Values of Member.Role.
+const (
+	// RoleDirector runs the tournament: members, access codes, syncs and
+	// results.
+	RoleDirector = "director"
+	// RoleReferee reports results of matches on the member's Fields.
+	RoleReferee = "referee"
+	// RoleScorekeeper reports results of every match and syncs matches.
+	RoleScorekeeper = "scorekeeper"
+	// RoleStatistician has read-only access.
+	RoleStatistician = "statistician"
+)
+
+// Member is a document in the Members subcollection of a tournament's
+// secrets, keyed by user ID. Users in the legacy AllowedUsers list without a
+// membership are directors.
+type Member struct {
+	UserID string `firestore:"UserID" json:"userId"`
+	Role   string `firestore:"Role" json:"role"`
+	// Fields limits a referee to matches on the courts with these names.
+	// Empty means every court.
+	Fields    []string  `firestore:"Fields" json:"fields,omitempty"`
+	GrantedBy string    `firestore:"GrantedBy" json:"grantedBy,omitempty"`
+	GrantedAt time.Time `firestore:"GrantedAt" json:"grantedAt"`
+}
+
+// AuditEntry is a document in the AuditLog collection, recording an attempt
+// to act on a tournament that the user's role did not allow.
+type AuditEntry struct {
+	ID     string    `firestore:"ID"`
+	At     time.Time `firestore:"At"`
+	UserID string    `firestore:"UserID"`
+	Slug   string    `firestore:"Slug"`
+	Field  string    `firestore:"Field"`
+	Action string    `firestore:"Action"`
+	// Resource is what the user acted on, such as the request path or
+	// "match/<scoreboard ID>".
+	Resource string `firestore:"Resource"`
+	Reason   string `firestore:"Reason"`
+}
+
+// Values of SyncJob.Kind.
+const (
+	SyncJobTournaments = "tournaments"
+	SyncJobMatches     = "matches"
+)
+
+// Values of SyncJob.Status.
+const (
+	SyncJobRunning   = "running"
+	SyncJobSucceeded = "succeeded"
+	SyncJobFailed    = "failed"
+)
+
+// SyncJob is a document in the SyncJobs collection, one per sync run.
+type SyncJob struct {
+	ID               string    `firestore:"ID" json:"id"`
+	Kind             string    `firestore:"Kind" json:"kind"`
+	Slug             string    `firestore:"Slug" json:"slug,omitempty"`
+	Sources          []string  `firestore:"Sources" json:"sources,omitempty"`
+	Status           string    `firestore:"Status" json:"status"`
+	StartedAt        time.Time `firestore:"StartedAt" json:"startedAt"`
+	FinishedAt       time.Time `firestore:"FinishedAt" json:"finishedAt"`
+	PagesFetched     int       `firestore:"PagesFetched" json:"pagesFetched"`
+	MatchesCreated   int       `firestore:"MatchesCreated" json:"matchesCreated"`
+	MatchesUpdated   int       `firestore:"MatchesUpdated" json:"matchesUpdated"`
+	MatchesUnchanged int       `firestore:"MatchesUnchanged" json:"matchesUnchanged"`
+	Error            string    `firestore:"Error" json:"error,omitempty"`
+}
+
+// TournamentStats is a document in the TournamentStats collection with the
+// metrics of a tournament's matches, keyed by slug.
+type TournamentStats struct {
+	Slug                string    `firestore:"Slug" json:"slug"`
+	UpdatedAt           time.Time `firestore:"UpdatedAt" json:"updatedAt"`
+	NumberOfMatches     int       `firestore:"NumberOfMatches" json:"numberOfMatches"`
+	NumberOfScoreboards int       `firestore:"NumberOfScoreboards" json:"numberOfScoreboards"`
+	// AutoReported counts results posted to Profixio from a scoreboard, and
+	// ManuallyReported results entered in Profixio directly.
+	AutoReported     int `firestore:"AutoReported" json:"autoReported"`
+	ManuallyReported int `firestore:"ManuallyReported" json:"manuallyReported"`
+	InvalidResults   int `firestore:"InvalidResults" json:"invalidResults"`
+	AuthorMismatches int `firestore:"AuthorMismatches" json:"authorMismatches"`
+	// AverageMatchDurationSeconds is measured from the first point to the
+	// end of the last set of matches with scoreboard events.
+	AverageMatchDurationSeconds int `firestore:"AverageMatchDurationSeconds" json:"averageMatchDurationSeconds"`
+	// DeuceSets counts the sets of SetsPlayed where both teams reached one
+	// point short of winning the set.
+	SetsPlayed         int     `firestore:"SetsPlayed" json:"setsPlayed"`
+	DeuceSets          int     `firestore:"DeuceSets" json:"deuceSets"`
+	DeuceSetPercentage float64 `firestore:"DeuceSetPercentage" json:"deuceSetPercentage"`
+	// AverageReportDelaySeconds is the average time from the end of a match
+	// to its result being posted to Profixio.
+	AverageReportDelaySeconds int          `firestore:"AverageReportDelaySeconds" json:"averageReportDelaySeconds"`
+	Courts                    []CourtStats `firestore:"Courts" json:"courts"`
+}
+
+// CourtStats is the scoreboard adoption on one court of a tournament.
+type CourtStats struct {
+	Court              string  `firestore:"Court" json:"court"`
+	Matches            int     `firestore:"Matches" json:"matches"`
+	Scoreboards        int     `firestore:"Scoreboards" json:"scoreboards"`
+	AdoptionPercentage float64 `firestore:"AdoptionPercentage" json:"adoptionPercentage"`
+}
+
+// Lease is a document in the Leases collection.
+type Lease struct {
+	Holder    string    `firestore:"Holder"`
+	ExpiresAt time.Time `firestore:"ExpiresAt"`
+}
+
+// SchedulerState is the document recording the scheduler's daily work, so it
+// survives restarts and leader changes.
+type SchedulerState struct {
+	// TournamentsSyncedOn is the local date, as YYYY-MM-DD, of the last
+	// successful tournament list sync.
+	TournamentsSyncedOn string `firestore:"TournamentsSyncedOn"`
+	// TournamentsAttemptedAt is when the tournament list sync last started.
+	TournamentsAttemptedAt time.Time `firestore:"TournamentsAttemptedAt"`
+}
+
+// available reports whether holder may take the lease at now.
+func (l *Lease) available(holder string, now time.Time) bool {
+	return l == nil || l.Holder == holder || !now.Before(l.ExpiresAt)
+}
@@ -6,3 +6,14 @@ type AccessRequest struct {
 	TournamentID string `json:"tournamentID"`
 	Email        string `json:"email"`
 }
+
+// MemberRequest grants or changes the role of a user in a tournament.
+type MemberRequest struct {
+	// UserID is the Firebase user ID. Email is used to look up the user when
+	// it is empty.
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// Fields limits a referee to matches on the courts with these names.
+	Fields []string `json:"fields"`
+}
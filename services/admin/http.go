@@ -1,18 +1,21 @@
 package admin
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	access "github.com/nvbf/tournament-sync/pkg/accessCode"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	resend "github.com/nvbf/tournament-sync/repos/resend"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 // Router is the interface for a router.
 type Router interface {
 	GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
 	POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
 	Use(middleware ...gin.HandlerFunc) gin.IRoutes
 	Group(relativePath string, handlers ...gin.HandlerFunc) *gin.RouterGroup
 }
@@ -20,7 +23,12 @@ type Router interface {
 // Greeter is the interface for a greeter service.
 type Admin interface {
 	ClaimAccess(c *gin.Context, request resend.AccessRequest) error
-	AddTournamentAccess(c *gin.Context, slug, uniruqID string) error
+	AddTournamentAccess(c *gin.Context, code string) (string, error)
+	RevokeAccessCodes(c *gin.Context, slug string) error
+	ListMembers(c *gin.Context, slug string) ([]*storage.Member, error)
+	GrantMember(c *gin.Context, slug string, request MemberRequest) (*storage.Member, error)
+	UpdateMember(c *gin.Context, slug string, userID string, request MemberRequest) (*storage.Member, error)
+	RevokeMember(c *gin.Context, slug string, userID string) error
 }
 
 // HTTPOptions contains all the options needed for the HTTP handler.
@@ -39,6 +47,11 @@ func NewHTTPHandler(opts HTTPOptions) {
 	h := &httpHandler{opts}
 	r.POST("/claim", h.claimHandler)
 	r.GET("/access/:access_code", h.accessHandler)
+	r.POST("/tournament/:slug_id/access-codes/revoke", h.revokeAccessCodesHandler)
+	r.GET("/tournament/:slug_id/members", h.listMembersHandler)
+	r.POST("/tournament/:slug_id/members", h.grantMemberHandler)
+	r.PUT("/tournament/:slug_id/members/:user_id", h.updateMemberHandler)
+	r.DELETE("/tournament/:slug_id/members/:user_id", h.revokeMemberHandler)
 }
 
 type httpHandler struct {
@@ -83,21 +96,142 @@ func (s *httpHandler) claimHandler(c *gin.Context) {
 func (s *httpHandler) accessHandler(c *gin.Context) {
 	accessCode := c.Param("access_code")
 	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath()}))
-	slug, uniqueID, err := access.Decode(accessCode)
-	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath(), "reason": "decode_access_code"}))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid access code"})
+
+	slug, err := s.Service.AddTournamentAccess(c, accessCode)
+	if errors.Is(err, ErrInvalidAccessCode) {
+		log.Warning("request forbidden", log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath(), "reason": err.Error()}))
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrInvalidAccessCode.Error()})
 		c.Abort()
 		return
 	}
-
-	err = s.Service.AddTournamentAccess(c, slug, uniqueID)
 	if err != nil {
-		log.Warning("request forbidden", log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath(), "slug": slug}))
-		c.JSON(http.StatusForbidden, gin.H{"error": "not valid access code"})
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath()}))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
 		c.Abort()
 		return
 	}
 	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "access", "path": c.FullPath(), "slug": slug}))
 	c.JSON(http.StatusOK, gin.H{"slug": slug})
 }
+
+func (s *httpHandler) revokeAccessCodesHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "revokeAccessCodes", "path": c.FullPath(), "slug": slug}))
+
+	err := s.Service.RevokeAccessCodes(c, slug)
+	switch {
+	case errors.Is(err, ErrForbidden):
+		log.Warning("request forbidden", log.WithRequest(c, log.Fields{"handler": "revokeAccessCodes", "path": c.FullPath(), "slug": slug}))
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "revokeAccessCodes", "path": c.FullPath(), "slug": slug}))
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	case err != nil:
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "revokeAccessCodes", "path": c.FullPath(), "slug": slug}))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		c.Abort()
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "revokeAccessCodes", "path": c.FullPath(), "slug": slug}))
+	c.JSON(http.StatusOK, gin.H{"slug": slug, "result": "Access codes revoked"})
+}
+
+func (s *httpHandler) listMembersHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "listMembers", "path": c.FullPath(), "slug": slug}))
+
+	members, err := s.Service.ListMembers(c, slug)
+	if err != nil {
+		writeMemberError(c, "listMembers", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "listMembers", "path": c.FullPath(), "slug": slug, "count": len(members)}))
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+func (s *httpHandler) grantMemberHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "grantMember", "path": c.FullPath(), "slug": slug}))
+
+	var request MemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "grantMember", "path": c.FullPath(), "slug": slug, "reason": "invalid_body"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	member, err := s.Service.GrantMember(c, slug, request)
+	if err != nil {
+		writeMemberError(c, "grantMember", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "grantMember", "path": c.FullPath(), "slug": slug, "userID": member.UserID, "role": member.Role}))
+	c.JSON(http.StatusCreated, gin.H{"member": member})
+}
+
+func (s *httpHandler) updateMemberHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	userID := c.Param("user_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "updateMember", "path": c.FullPath(), "slug": slug, "userID": userID}))
+
+	var request MemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "updateMember", "path": c.FullPath(), "slug": slug, "reason": "invalid_body"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	member, err := s.Service.UpdateMember(c, slug, userID, request)
+	if err != nil {
+		writeMemberError(c, "updateMember", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "updateMember", "path": c.FullPath(), "slug": slug, "userID": userID, "role": member.Role}))
+	c.JSON(http.StatusOK, gin.H{"member": member})
+}
+
+func (s *httpHandler) revokeMemberHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	userID := c.Param("user_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "revokeMember", "path": c.FullPath(), "slug": slug, "userID": userID}))
+
+	if err := s.Service.RevokeMember(c, slug, userID); err != nil {
+		writeMemberError(c, "revokeMember", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "revokeMember", "path": c.FullPath(), "slug": slug, "userID": userID}))
+	c.JSON(http.StatusOK, gin.H{"slug": slug, "userId": userID, "result": "Member revoked"})
+}
+
+// writeMemberError maps errors of the member endpoints onto HTTP responses.
+func writeMemberError(c *gin.Context, handler string, slug string, err error) {
+	fields := log.Fields{"handler": handler, "path": c.FullPath(), "slug": slug}
+
+	status := http.StatusInternalServerError
+	message := "something went wrong"
+	switch {
+	case errors.Is(err, ErrInvalidMember):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrForbidden):
+		status, message = http.StatusForbidden, ErrForbidden.Error()
+	case errors.Is(err, storage.ErrNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrMemberExists):
+		status, message = http.StatusConflict, err.Error()
+	}
+
+	if status == http.StatusInternalServerError {
+		log.Error("request failed", err, log.WithRequest(c, fields))
+	} else {
+		fields["reason"] = err.Error()
+		log.Warning("request failed", log.WithRequest(c, fields))
+	}
+	c.JSON(status, gin.H{"error": message})
+	c.Abort()
+}
@@ -2,104 +2,292 @@ package admin
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
-	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	auth "firebase.google.com/go/v4/auth"
 
 	"github.com/gin-gonic/gin"
 	access "github.com/nvbf/tournament-sync/pkg/accessCode"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	resend "github.com/nvbf/tournament-sync/repos/resend"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 var ErrInvalidTournementID = errors.New("tournamentID missmatch")
 
+var (
+	// ErrInvalidAccessCode is returned for access codes that are forged,
+	// expired, revoked, used by someone else or meant for another email.
+	ErrInvalidAccessCode = errors.New("not valid access code")
+	// ErrForbidden is returned when the user's role in the tournament does
+	// not allow the request.
+	ErrForbidden = authz.ErrForbidden
+	// ErrInvalidMember is returned for member requests with an unknown role
+	// or without a user.
+	ErrInvalidMember = errors.New("invalid member")
+	// ErrMemberExists is returned when granting a role to a user who is
+	// already a member of the tournament.
+	ErrMemberExists = errors.New("user is already a member of the tournament")
+)
+
 type AdminService struct {
-	firestoreClient *firestore.Client
-	firebaseApp     *firebase.App
-	resendService   *resend.Service
+	store         storage.Store
+	firebaseApp   *firebase.App
+	resendService *resend.Service
+	codes         *access.Signer
+	codeTTL       time.Duration
+	permissions   *authz.Permissions
 }
 
-func NewAdminService(firestoreClient *firestore.Client, firebaseApp *firebase.App, resendService *resend.Service) *AdminService {
+func NewAdminService(store storage.Store, permissions *authz.Permissions, firebaseApp *firebase.App, resendService *resend.Service, codes *access.Signer, codeTTL time.Duration) *AdminService {
 	return &AdminService{
-		firestoreClient: firestoreClient,
-		firebaseApp:     firebaseApp,
-		resendService:   resendService,
+		store:         store,
+		firebaseApp:   firebaseApp,
+		resendService: resendService,
+		codes:         codes,
+		codeTTL:       codeTTL,
+		permissions:   permissions,
 	}
 }
 
+// ClaimAccess mails an access code for the tournament to the email in the
+// request. Access is granted once the code is used.
 func (s *AdminService) ClaimAccess(c *gin.Context, request resend.AccessRequest) error {
-	token := c.MustGet("token").(*auth.Token)
-
-	doc, err := s.firestoreClient.Collection("TournamentSecrets").Doc(request.Slug).Get(c)
+	secrets, err := s.store.GetTournamentSecrets(c, request.Slug)
 	if err != nil {
 		log.Printf("Failed to get tournament to Firestore: %v\n", err)
 		return err
 	}
 
-	data := doc.Data()
-
-	fieldIDValue, ok := data["ID"]
-	if !ok {
-		log.Printf("Field ID does not exist in the document.")
-	}
-
-	if fieldIDValue != int64(request.TournamentID) {
-		log.Printf("tournament ID mismatch firestore=%v request=%d", fieldIDValue, request.TournamentID)
+	if secrets.ID != request.TournamentID {
+		log.Printf("tournament ID mismatch firestore=%v request=%d", secrets.ID, request.TournamentID)
 		return ErrInvalidTournementID
 	}
 
-	fieldValue, ok := data["Secret"]
-	if !ok {
-		log.Printf("Field does not exist in the document.")
+	accessCode, claims, err := s.codes.Issue(request.Slug, request.Email, s.codeTTL)
+	if err != nil {
+		return err
 	}
 
-	secretString, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("Failed to convert field value to string.")
+	err = s.store.SaveAccessCode(c, storage.AccessCode{
+		Nonce:     claims.Nonce,
+		Slug:      claims.Slug,
+		Email:     claims.Email,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		log.Printf("Failed to write access code to Firestore: %v\n", err)
+		return err
 	}
 
-	accessCode := access.GenerateCode(request.Slug, secretString)
-
 	err = s.resendService.SendMail(c, request, accessCode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send mail request"})
 		c.Abort()
 		return err
 	}
-
-	go s.resendService.GrantAccess(c, request.Slug, token.UID)
 	return nil
 }
 
-func (s *AdminService) AddTournamentAccess(c *gin.Context, slug, uniqueID string) error {
-	token := c.MustGet("token").(*auth.Token)
+// AddTournamentAccess verifies an access code and grants the user access to
+// its tournament. A code can only be used by one user, who must be signed in
+// with the email it was sent to. It returns the slug of the tournament.
+func (s *AdminService) AddTournamentAccess(c *gin.Context, code string) (string, error) {
+	principal := authz.MustPrincipal(c)
+
+	claims, err := s.codes.Verify(code)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAccessCode, err)
+	}
+
+	if !strings.EqualFold(principal.Email, claims.Email) {
+		return "", fmt.Errorf("%w: issued to another email", ErrInvalidAccessCode)
+	}
 
-	doc, err := s.firestoreClient.Collection("TournamentSecrets").Doc(slug).Get(c)
+	secrets, err := s.store.GetTournamentSecrets(c, claims.Slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", fmt.Errorf("%w: unknown tournament", ErrInvalidAccessCode)
+	}
 	if err != nil {
 		log.Printf("Failed to get tournament to Firestore: %v\n", err)
+		return "", err
+	}
+	if !secrets.AccessCodesRevokedAt.IsZero() && !claims.IssuedAt.After(secrets.AccessCodesRevokedAt) {
+		return "", fmt.Errorf("%w: revoked", ErrInvalidAccessCode)
+	}
+
+	_, err = s.store.UseAccessCode(c, claims.Nonce, principal.UserID, time.Now())
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrAccessCodeUsed) {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAccessCode, err)
+	}
+	if err != nil {
+		log.Printf("Failed to use access code in Firestore: %v\n", err)
+		return "", err
+	}
+
+	if err := s.store.GrantAccess(c, claims.Slug, principal.UserID); err != nil {
+		log.Printf("Failed to update document: %v", err)
+		return "", err
+	}
+	err = s.store.PutMember(c, claims.Slug, storage.Member{
+		UserID:    principal.UserID,
+		Role:      storage.RoleDirector,
+		GrantedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to write member to Firestore: %v", err)
+		return "", err
+	}
+	return claims.Slug, nil
+}
+
+// RevokeAccessCodes invalidates every access code issued for the tournament
+// so far. Users who already have access keep it.
+func (s *AdminService) RevokeAccessCodes(c *gin.Context, slug string) error {
+	if err := s.authorize(c, slug); err != nil {
 		return err
 	}
+	return s.store.RevokeAccessCodes(c, slug, time.Now())
+}
 
-	data := doc.Data()
-	fieldValue, ok := data["Secret"]
-	if !ok {
-		log.Printf("Field does not exist in the document.")
+// ListMembers returns the members of the tournament.
+func (s *AdminService) ListMembers(c *gin.Context, slug string) ([]*storage.Member, error) {
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
 	}
+	return s.store.ListMembers(c, slug)
+}
 
-	secretString, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("Failed to convert field value to string.")
+// GrantMember gives a user who is not yet a member a role in the tournament.
+// The user is looked up by email if the request has no user ID.
+func (s *AdminService) GrantMember(c *gin.Context, slug string, request MemberRequest) (*storage.Member, error) {
+	if err := validateMemberRequest(request); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
 	}
 
-	if uniqueID == secretString {
-		s.resendService.GrantAccess(c, slug, token.UID)
-	} else {
-		c.JSON(http.StatusForbidden, gin.H{"error": "not valid access code"})
-		c.Abort()
-		return errors.New("not valid access code")
+	userID, err := s.resolveUserID(c, request)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.store.GetMember(c, slug, userID)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMemberExists, userID)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	return s.putMember(c, slug, userID, request)
+}
+
+// UpdateMember changes the role and fields of a member of the tournament.
+func (s *AdminService) UpdateMember(c *gin.Context, slug string, userID string, request MemberRequest) (*storage.Member, error) {
+	request.UserID = userID
+	if err := validateMemberRequest(request); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.GetMember(c, slug, userID); err != nil {
+		return nil, err
+	}
+	return s.putMember(c, slug, userID, request)
+}
+
+// RevokeMember removes the user's role in the tournament, including access
+// from the legacy allowed users.
+func (s *AdminService) RevokeMember(c *gin.Context, slug string, userID string) error {
+	if err := s.authorize(c, slug); err != nil {
+		return err
+	}
+
+	if _, err := s.permissions.Member(c, slug, userID); err != nil {
+		if errors.Is(err, ErrForbidden) {
+			return fmt.Errorf("%w: member %s", storage.ErrNotFound, userID)
+		}
+		return err
+	}
+	if err := s.store.DeleteMember(c, slug, userID); err != nil {
+		return err
+	}
+	log.Info("member revoked", log.WithRequest(c, log.Fields{"operation": "revokeMember", "slug": slug, "userID": userID}))
+	return nil
+}
+
+func (s *AdminService) putMember(c *gin.Context, slug string, userID string, request MemberRequest) (*storage.Member, error) {
+	member := storage.Member{
+		UserID:    userID,
+		Role:      request.Role,
+		Fields:    request.Fields,
+		GrantedBy: authz.MustPrincipal(c).UserID,
+		GrantedAt: time.Now(),
+	}
+	if err := s.store.PutMember(c, slug, member); err != nil {
+		return nil, err
+	}
+	log.Info("member updated", log.WithRequest(c, log.Fields{"operation": "putMember", "slug": slug, "userID": userID, "role": member.Role}))
+	return &member, nil
+}
+
+// authorize checks that the tournament exists and the user may manage it.
+func (s *AdminService) authorize(c *gin.Context, slug string) error {
+	if _, err := s.store.GetTournamentSecrets(c, slug); err != nil {
+		return err
+	}
+	return s.permissions.Authorize(c, authz.MustPrincipal(c), authz.ActionManage, authz.Scope{Slug: slug})
+}
+
+func (s *AdminService) resolveUserID(c *gin.Context, request MemberRequest) (string, error) {
+	if request.UserID != "" {
+		return request.UserID, nil
+	}
+	if s.firebaseApp == nil {
+		return "", fmt.Errorf("%w: userId is required", ErrInvalidMember)
+	}
+
+	authClient, err := s.firebaseApp.Auth(c)
+	if err != nil {
+		return "", err
+	}
+	user, err := authClient.GetUserByEmail(c, request.Email)
+	if auth.IsUserNotFound(err) {
+		return "", fmt.Errorf("%w: no user with email %s", ErrInvalidMember, request.Email)
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.UID, nil
+}
+
+var memberRoles = []string{storage.RoleDirector, storage.RoleReferee, storage.RoleScorekeeper, storage.RoleStatistician}
+
+func validateMemberRequest(request MemberRequest) error {
+	var problems []string
+	if request.UserID == "" && request.Email == "" {
+		problems = append(problems, "userId or email is required")
+	}
+	if !authz.ValidRole(request.Role) {
+		problems = append(problems, fmt.Sprintf("role must be one of %s", strings.Join(memberRoles, ", ")))
+	}
+	if len(request.Fields) > 0 && request.Role != storage.RoleReferee {
+		problems = append(problems, "fields are only allowed for referees")
+	}
+	if slices.Contains(request.Fields, "") {
+		problems = append(problems, "fields must not be empty")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidMember, strings.Join(problems, "; "))
 	}
 	return nil
 }
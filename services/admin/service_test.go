@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+
+	access "github.com/nvbf/tournament-sync/pkg/accessCode"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func newTokenContext(uid, email string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/v1/access/code", nil)
+	authz.SetPrincipal(c, authz.NewPrincipal(&auth.Token{UID: uid, Claims: map[string]interface{}{"email": email}}))
+	return c
+}
+
+// issueCode issues and records a code for organiser@example.com, as
+// ClaimAccess does.
+func issueCode(t *testing.T, store *storage.Memory, signer *access.Signer, slug string, ttl time.Duration) string {
+	t.Helper()
+	code, claims, err := signer.Issue(slug, "organiser@example.com", ttl)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err = store.SaveAccessCode(context.Background(), storage.AccessCode{Nonce: claims.Nonce, Slug: slug, Email: claims.Email, IssuedAt: claims.IssuedAt, ExpiresAt: claims.ExpiresAt})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return code
+}
+
+func TestAddTournamentAccess(t *testing.T) {
+	signer := access.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "https://scoreboard.example")
+
+	cases := []struct {
+		name    string
+		code    func(t *testing.T, store *storage.Memory) string
+		uid     string
+		email   string
+		granted bool
+	}{
+		{
+			name:    "valid code",
+			code:    func(t *testing.T, store *storage.Memory) string { return issueCode(t, store, signer, "beach-cup", time.Hour) },
+			uid:     "organiser",
+			email:   "Organiser@example.com",
+			granted: true,
+		},
+		{
+			name: "used by another user",
+			code: func(t *testing.T, store *storage.Memory) string {
+				code := issueCode(t, store, signer, "beach-cup", time.Hour)
+				if _, err := NewAdminService(store, authz.NewPermissions(store), nil, nil, signer, time.Hour).AddTournamentAccess(newTokenContext("first", "organiser@example.com"), code); err != nil {
+					t.Fatalf("expected the first use to succeed, got %v", err)
+				}
+				return code
+			},
+			uid:   "organiser",
+			email: "organiser@example.com",
+		},
+		{
+			name:  "other email",
+			code:  func(t *testing.T, store *storage.Memory) string { return issueCode(t, store, signer, "beach-cup", time.Hour) },
+			uid:   "organiser",
+			email: "someone@example.com",
+		},
+		{
+			name:  "expired",
+			code:  func(t *testing.T, store *storage.Memory) string { return issueCode(t, store, signer, "beach-cup", -time.Minute) },
+			uid:   "organiser",
+			email: "organiser@example.com",
+		},
+		{
+			name: "revoked",
+			code: func(t *testing.T, store *storage.Memory) string {
+				code := issueCode(t, store, signer, "beach-cup", time.Hour)
+				_ = store.RevokeAccessCodes(context.Background(), "beach-cup", time.Now().Add(time.Second))
+				return code
+			},
+			uid:   "organiser",
+			email: "organiser@example.com",
+		},
+		{
+			name: "not recorded",
+			code: func(t *testing.T, store *storage.Memory) string {
+				code, _, _ := signer.Issue("beach-cup", "organiser@example.com", time.Hour)
+				return code
+			},
+			uid:   "organiser",
+			email: "organiser@example.com",
+		},
+		{
+			name:  "old secret code",
+			code:  func(t *testing.T, store *storage.Memory) string { return "YmVhY2gtY3VwfHNlY3JldA==" },
+			uid:   "organiser",
+			email: "organiser@example.com",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			store.SeedTournamentSecrets(storage.TournamentSecrets{ID: 2405, Slug: "beach-cup", Secret: "secret"})
+			service := NewAdminService(store, authz.NewPermissions(store), nil, nil, signer, time.Hour)
+			code := c.code(t, store)
+
+			slug, err := service.AddTournamentAccess(newTokenContext(c.uid, c.email), code)
+			if c.granted {
+				if err != nil || slug != "beach-cup" {
+					t.Fatalf("expected access to beach-cup, got %q %v", slug, err)
+				}
+				// Following the link again is fine for the same user.
+				if _, err := service.AddTournamentAccess(newTokenContext(c.uid, c.email), code); err != nil {
+					t.Fatalf("expected the same user to reuse the code, got %v", err)
+				}
+			} else if !errors.Is(err, ErrInvalidAccessCode) {
+				t.Fatalf("expected %v, got %v", ErrInvalidAccessCode, err)
+			}
+
+			secrets, _ := store.GetTournamentSecrets(context.Background(), "beach-cup")
+			if slices.Contains(secrets.AllowedUsers, c.uid) != c.granted {
+				t.Fatalf("expected granted=%v, got allowed users %v", c.granted, secrets.AllowedUsers)
+			}
+			member, err := store.GetMember(context.Background(), "beach-cup", c.uid)
+			if c.granted && (err != nil || member.Role != storage.RoleDirector) {
+				t.Fatalf("expected a director, got %+v %v", member, err)
+			}
+		})
+	}
+}
+
+func TestRevokeAccessCodes(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup", AllowedUsers: []string{"organiser"}})
+	service := NewAdminService(store, authz.NewPermissions(store), nil, nil, nil, time.Hour)
+
+	if err := service.RevokeAccessCodes(newTokenContext("stranger", ""), "beach-cup"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v, got %v", ErrForbidden, err)
+	}
+	if err := service.RevokeAccessCodes(newTokenContext("organiser", ""), "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+	}
+	if err := service.RevokeAccessCodes(newTokenContext("organiser", ""), "beach-cup"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	secrets, _ := store.GetTournamentSecrets(context.Background(), "beach-cup")
+	if secrets.AccessCodesRevokedAt.IsZero() || secrets.Secret != "" {
+		t.Fatalf("expected codes to be revoked without touching the secret, got %+v", secrets)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournamentSecrets(storage.TournamentSecrets{Slug: "beach-cup", AllowedUsers: []string{"organiser"}})
+	service := NewAdminService(store, authz.NewPermissions(store), nil, nil, nil, time.Hour)
+	director := func() *gin.Context { return newTokenContext("organiser", "") }
+
+	_, err := service.GrantMember(newTokenContext("stranger", ""), "beach-cup", MemberRequest{UserID: "referee", Role: storage.RoleReferee})
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v, got %v", ErrForbidden, err)
+	}
+	_, err = service.GrantMember(director(), "beach-cup", MemberRequest{UserID: "referee", Role: "coach"})
+	if !errors.Is(err, ErrInvalidMember) {
+		t.Fatalf("expected %v, got %v", ErrInvalidMember, err)
+	}
+	_, err = service.GrantMember(director(), "beach-cup", MemberRequest{UserID: "scorer", Role: storage.RoleScorekeeper, Fields: []string{"Bane 1"}})
+	if !errors.Is(err, ErrInvalidMember) {
+		t.Fatalf("expected %v for fields on a scorekeeper, got %v", ErrInvalidMember, err)
+	}
+
+	member, err := service.GrantMember(director(), "beach-cup", MemberRequest{UserID: "referee", Role: storage.RoleReferee, Fields: []string{"Bane 1"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if member.GrantedBy != "organiser" || !slices.Equal(member.Fields, []string{"Bane 1"}) {
+		t.Fatalf("unexpected member %+v", member)
+	}
+	_, err = service.GrantMember(director(), "beach-cup", MemberRequest{UserID: "referee", Role: storage.RoleStatistician})
+	if !errors.Is(err, ErrMemberExists) {
+		t.Fatalf("expected %v, got %v", ErrMemberExists, err)
+	}
+
+	// Only directors manage members.
+	if _, err := service.ListMembers(newTokenContext("referee", ""), "beach-cup"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v, got %v", ErrForbidden, err)
+	}
+
+	member, err = service.UpdateMember(director(), "beach-cup", "referee", MemberRequest{Role: storage.RoleStatistician})
+	if err != nil || member.Role != storage.RoleStatistician || len(member.Fields) != 0 {
+		t.Fatalf("expected a statistician, got %+v %v", member, err)
+	}
+	if _, err := service.UpdateMember(director(), "beach-cup", "nobody", MemberRequest{Role: storage.RoleStatistician}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+	}
+
+	members, err := service.ListMembers(director(), "beach-cup")
+	if err != nil || len(members) != 1 || members[0].UserID != "referee" {
+		t.Fatalf("expected the referee, got %v %v", members, err)
+	}
+
+	if err := service.RevokeMember(director(), "beach-cup", "referee"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := service.RevokeMember(director(), "beach-cup", "referee"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+	}
+
+	// Revoking a user from the legacy allowed users list removes their access.
+	if err := service.RevokeMember(director(), "beach-cup", "organiser"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := service.ListMembers(director(), "beach-cup"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected %v after revoking, got %v", ErrForbidden, err)
+	}
+}
@@ -0,0 +1,68 @@
+package custom
+
+import (
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// TournamentRequest is the body used to create or update a custom tournament.
+// The slug is taken from the path on update.
+type TournamentRequest struct {
+	ID         int      `json:"id"`
+	Slug       string   `json:"slug"`
+	Name       string   `json:"name"`
+	StartDate  string   `json:"startDate"`
+	EndDate    string   `json:"endDate"`
+	Type       string   `json:"type"`
+	Categories []string `json:"categories"`
+	// Scoring are the rules of the tournament, either a preset like
+	// {"name": "indoor"} or custom values. CategoryScoring overrides them per
+	// category.
+	Scoring         *scoring.Rules           `json:"scoring"`
+	CategoryScoring map[string]scoring.Rules `json:"categoryScoring"`
+}
+
+// Tournament is a custom tournament as returned by the API.
+type Tournament struct {
+	ID              int                      `json:"id"`
+	Slug            string                   `json:"slug"`
+	Name            string                   `json:"name"`
+	StartDate       string                   `json:"startDate"`
+	EndDate         string                   `json:"endDate"`
+	Type            string                   `json:"type"`
+	Categories      []string                 `json:"categories"`
+	NumberOfMatches int                      `json:"numberOfMatches"`
+	Scoring         scoring.Rules            `json:"scoring"`
+	CategoryScoring map[string]scoring.Rules `json:"categoryScoring"`
+}
+
+// UploadResult counts what a match upload changed.
+type UploadResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+}
+
+func toTournament(tournament *storage.Tournament) Tournament {
+	categories := tournament.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	categoryScoring := tournament.CategoryScoring
+	if categoryScoring == nil {
+		categoryScoring = map[string]scoring.Rules{}
+	}
+	return Tournament{
+		ID:              tournament.ID,
+		Slug:            tournament.Slug,
+		Name:            tournament.Name,
+		StartDate:       tournament.StartDate,
+		EndDate:         tournament.EndDate,
+		Type:            tournament.EventType,
+		Categories:      categories,
+		NumberOfMatches: tournament.NumberOfMatches,
+		Scoring:         tournament.ScoringRules(""),
+		CategoryScoring: categoryScoring,
+	}
+}
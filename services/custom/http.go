@@ -0,0 +1,275 @@
+package custom
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// Router is the interface for a router.
+type Router interface {
+	GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+	Use(middleware ...gin.HandlerFunc) gin.IRoutes
+	Group(relativePath string, handlers ...gin.HandlerFunc) *gin.RouterGroup
+}
+
+// Custom is the interface for the custom tournament service.
+type Custom interface {
+	ListTournaments(c *gin.Context) ([]Tournament, error)
+	GetTournament(c *gin.Context, slug string) (*Tournament, error)
+	CreateTournament(c *gin.Context, request TournamentRequest) (*Tournament, error)
+	UpdateTournament(c *gin.Context, slug string, request TournamentRequest) (*Tournament, error)
+	DeleteTournament(c *gin.Context, slug string) error
+	UploadMatches(c *gin.Context, slug string, tournament profixio.CustomTournament, prune bool) (*UploadResult, error)
+	ImportMatches(c *gin.Context, slug string, format string, data []byte, prune bool) (*UploadResult, error)
+}
+
+// maxScheduleSize limits the size of an imported schedule file.
+const maxScheduleSize = 5 << 20
+
+// HTTPOptions contains all the options needed for the HTTP handler.
+type HTTPOptions struct {
+
+	// The service we provides the HTTP transport for.
+	Service Custom
+
+	// The router instance to configure the HTTP routes.
+	Router Router
+}
+
+// NewHTTPHandler creates a new HTTP handler.
+func NewHTTPHandler(opts HTTPOptions) {
+	r := opts.Router
+	h := &httpHandler{opts}
+	r.GET("/tournaments", h.listTournamentsHandler)
+	r.POST("/tournament", h.createTournamentHandler)
+	r.GET("/tournament/:slug_id", h.getTournamentHandler)
+	r.PUT("/tournament/:slug_id", h.updateTournamentHandler)
+	r.DELETE("/tournament/:slug_id", h.deleteTournamentHandler)
+	r.POST("/tournament/:slug_id/matches", h.uploadMatchesHandler)
+	r.POST("/tournament/:slug_id/import", h.importMatchesHandler)
+}
+
+type httpHandler struct {
+	HTTPOptions
+}
+
+func (s *httpHandler) listTournamentsHandler(c *gin.Context) {
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "listCustomTournaments", "path": c.FullPath()}))
+
+	tournaments, err := s.Service.ListTournaments(c)
+	if err != nil {
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "listCustomTournaments", "path": c.FullPath()}))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		c.Abort()
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "listCustomTournaments", "path": c.FullPath(), "count": len(tournaments)}))
+	c.JSON(http.StatusOK, gin.H{"tournaments": tournaments})
+}
+
+func (s *httpHandler) getTournamentHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "getCustomTournament", "path": c.FullPath(), "slug": slug}))
+
+	tournament, err := s.Service.GetTournament(c, slug)
+	if err != nil {
+		s.abort(c, "getCustomTournament", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "getCustomTournament", "path": c.FullPath(), "slug": slug}))
+	c.JSON(http.StatusOK, gin.H{"tournament": tournament})
+}
+
+func (s *httpHandler) createTournamentHandler(c *gin.Context) {
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "createCustomTournament", "path": c.FullPath()}))
+
+	var request TournamentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "createCustomTournament", "path": c.FullPath(), "reason": "invalid_body"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	tournament, err := s.Service.CreateTournament(c, request)
+	if err != nil {
+		s.abort(c, "createCustomTournament", request.Slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "createCustomTournament", "path": c.FullPath(), "slug": tournament.Slug}))
+	c.JSON(http.StatusCreated, gin.H{"tournament": tournament})
+}
+
+func (s *httpHandler) updateTournamentHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "slug": slug}))
+
+	var request TournamentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "slug": slug, "reason": "invalid_body"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	tournament, err := s.Service.UpdateTournament(c, slug, request)
+	if err != nil {
+		s.abort(c, "updateCustomTournament", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "slug": slug}))
+	c.JSON(http.StatusOK, gin.H{"tournament": tournament})
+}
+
+func (s *httpHandler) deleteTournamentHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "deleteCustomTournament", "path": c.FullPath(), "slug": slug}))
+
+	if err := s.Service.DeleteTournament(c, slug); err != nil {
+		s.abort(c, "deleteCustomTournament", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "deleteCustomTournament", "path": c.FullPath(), "slug": slug}))
+	c.JSON(http.StatusOK, gin.H{"slug": slug})
+}
+
+func (s *httpHandler) uploadMatchesHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+
+	prune, ok := pruneQuery(c, "uploadCustomMatches", slug)
+	if !ok {
+		return
+	}
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "uploadCustomMatches", "path": c.FullPath(), "slug": slug, "prune": prune}))
+
+	var request profixio.CustomTournament
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "uploadCustomMatches", "path": c.FullPath(), "slug": slug, "reason": "invalid_body"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	result, err := s.Service.UploadMatches(c, slug, request, prune)
+	var validationErr *profixio.ValidationError
+	if errors.As(err, &validationErr) {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "uploadCustomMatches", "path": c.FullPath(), "slug": slug, "reason": "invalid_matches", "count": len(validationErr.Errors)}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": profixio.ErrInvalidCustomTournament.Error(), "matchErrors": validationErr.Errors})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		s.abort(c, "uploadCustomMatches", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "uploadCustomMatches", "path": c.FullPath(), "slug": slug, "created": result.Created, "updated": result.Updated, "removed": result.Removed}))
+	c.JSON(http.StatusOK, gin.H{"slug": slug, "result": result})
+}
+
+func (s *httpHandler) importMatchesHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+
+	prune, ok := pruneQuery(c, "importCustomMatches", slug)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "importCustomMatches", "path": c.FullPath(), "slug": slug, "reason": "missing_file"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		c.Abort()
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", strings.TrimPrefix(filepath.Ext(header.Filename), ".")))
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "importCustomMatches", "path": c.FullPath(), "slug": slug, "prune": prune, "format": format, "size": header.Size}))
+
+	if header.Size > maxScheduleSize {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "importCustomMatches", "path": c.FullPath(), "slug": slug, "reason": "file_too_large"}))
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		c.Abort()
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		s.abort(c, "importCustomMatches", slug, err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		s.abort(c, "importCustomMatches", slug, err)
+		return
+	}
+
+	result, err := s.Service.ImportMatches(c, slug, format, data, prune)
+	var scheduleErr *ScheduleError
+	if errors.As(err, &scheduleErr) {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": "importCustomMatches", "path": c.FullPath(), "slug": slug, "reason": "invalid_schedule", "count": len(scheduleErr.Errors)}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidSchedule.Error(), "rowErrors": scheduleErr.Errors})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		s.abort(c, "importCustomMatches", slug, err)
+		return
+	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "importCustomMatches", "path": c.FullPath(), "slug": slug, "created": result.Created, "updated": result.Updated, "removed": result.Removed}))
+	c.JSON(http.StatusOK, gin.H{"slug": slug, "result": result})
+}
+
+// pruneQuery reads the optional prune query parameter. It responds with 400
+// and returns false when the value is not a boolean.
+func pruneQuery(c *gin.Context, handler string, slug string) (bool, bool) {
+	pruneParam := c.Query("prune")
+	if pruneParam == "" {
+		return false, true
+	}
+	prune, err := strconv.ParseBool(pruneParam)
+	if err != nil {
+		log.Warning("request invalid", log.WithRequest(c, log.Fields{"handler": handler, "path": c.FullPath(), "slug": slug, "prune": pruneParam, "reason": "invalid_prune"}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prune must be true or false"})
+		c.Abort()
+		return false, false
+	}
+	return prune, true
+}
+
+// abort maps service errors onto HTTP responses.
+func (s *httpHandler) abort(c *gin.Context, handler string, slug string, err error) {
+	fields := log.Fields{"handler": handler, "path": c.FullPath(), "slug": slug}
+
+	status := http.StatusInternalServerError
+	message := "something went wrong"
+	switch {
+	case errors.Is(err, ErrInvalidTournament):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, ErrNotCustom):
+		status, message = http.StatusNotFound, "custom tournament not found"
+	case errors.Is(err, ErrAlreadyExists):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, ErrForbidden):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	if status == http.StatusInternalServerError {
+		log.Error("request failed", err, log.WithRequest(c, fields))
+	} else {
+		fields["reason"] = err.Error()
+		log.Warning("request failed", log.WithRequest(c, fields))
+	}
+	c.JSON(status, gin.H{"error": message})
+	c.Abort()
+}
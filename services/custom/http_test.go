@@ -0,0 +1,317 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xorcare/pointer"
+
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// testUserHeader carries the UID the test middleware puts in the token.
+const testUserHeader = "X-Test-User"
+
+func setupCustomRouter(store storage.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		authz.SetPrincipal(c, &authz.Principal{UserID: c.GetHeader(testUserHeader)})
+	})
+	NewHTTPHandler(HTTPOptions{Service: NewCustomService(store, authz.NewPermissions(store), profixio.NewService(store, "")), Router: r})
+	return r
+}
+
+func performRequest(r *gin.Engine, method, path, user string, body any) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(testUserHeader, user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func nevza() TournamentRequest {
+	return TournamentRequest{
+		ID:         2405,
+		Slug:       "nevza_oddanesand_24",
+		Name:       "Nevza Oddanesand 2024",
+		StartDate:  "2024-06-18",
+		EndDate:    "2024-06-20",
+		Type:       "beach",
+		Categories: []string{"U18 Boys", "U18 Girls"},
+	}
+}
+
+func TestCustomTournamentLifecycle(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "beach-cup", Type: "Profixio"})
+	r := setupCustomRouter(store)
+
+	w := performRequest(r, http.MethodPost, "/tournament", "organiser", nevza())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	secrets, err := store.GetTournamentSecrets(context.Background(), "nevza_oddanesand_24")
+	if err != nil || secrets.Secret == "" || len(secrets.AllowedUsers) != 1 || secrets.AllowedUsers[0] != "organiser" {
+		t.Fatalf("expected a secret and access for the organiser, got %+v %v", secrets, err)
+	}
+
+	update := nevza()
+	update.Name = "Nevza Oddanesand"
+	update.Categories = []string{"U18 Boys"}
+	update.Scoring = &scoring.Rules{Name: scoring.PresetYouth}
+	update.CategoryScoring = map[string]scoring.Rules{"U18 Boys": {BestOf: 1, SetPoints: 21, MinLead: 2}}
+
+	invalidScoring := nevza()
+	invalidScoring.Scoring = &scoring.Rules{Name: "snow"}
+	invalidScoring.CategoryScoring = map[string]scoring.Rules{"U12": {Name: scoring.PresetBeach}}
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		user       string
+		body       any
+		statusCode int
+	}{
+		{name: "duplicate", method: http.MethodPost, path: "/tournament", user: "organiser", body: nevza(), statusCode: http.StatusConflict},
+		{name: "invalid", method: http.MethodPost, path: "/tournament", user: "organiser", body: TournamentRequest{Slug: "Bad Slug", StartDate: "18.06.2024"}, statusCode: http.StatusBadRequest},
+		{name: "invalid scoring", method: http.MethodPut, path: "/tournament/nevza_oddanesand_24", user: "organiser", body: invalidScoring, statusCode: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, path: "/tournament/nevza_oddanesand_24", user: "anyone", statusCode: http.StatusOK},
+		{name: "get profixio tournament", method: http.MethodGet, path: "/tournament/beach-cup", user: "anyone", statusCode: http.StatusNotFound},
+		{name: "update without access", method: http.MethodPut, path: "/tournament/nevza_oddanesand_24", user: "someone", body: update, statusCode: http.StatusForbidden},
+		{name: "update", method: http.MethodPut, path: "/tournament/nevza_oddanesand_24", user: "organiser", body: update, statusCode: http.StatusOK},
+		{name: "upload without access", method: http.MethodPost, path: "/tournament/nevza_oddanesand_24/matches", user: "someone", body: profixio.CustomTournament{}, statusCode: http.StatusForbidden},
+		{name: "delete profixio tournament", method: http.MethodDelete, path: "/tournament/beach-cup", user: "organiser", statusCode: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := performRequest(r, c.method, c.path, c.user, c.body)
+			if w.Code != c.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", c.statusCode, w.Code, w.Body)
+			}
+		})
+	}
+
+	w = performRequest(r, http.MethodGet, "/tournaments", "anyone", nil)
+	var body struct {
+		Tournaments []Tournament `json:"tournaments"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(body.Tournaments) != 1 || body.Tournaments[0].Name != "Nevza Oddanesand" || len(body.Tournaments[0].Categories) != 1 || body.Tournaments[0].Type != "beach" {
+		t.Fatalf("expected the updated custom tournament only, got %+v", body.Tournaments)
+	}
+	youth, _ := scoring.Preset(scoring.PresetYouth)
+	if body.Tournaments[0].Scoring != youth || body.Tournaments[0].CategoryScoring["U18 Boys"].SetPoints != 21 {
+		t.Fatalf("expected youth rules with single set U18 Boys, got %+v %+v", body.Tournaments[0].Scoring, body.Tournaments[0].CategoryScoring)
+	}
+
+	store.SeedMatch("nevza_oddanesand_24", storage.Match{Match: profixio.Match{Number: pointer.String("1")}})
+	w = performRequest(r, http.MethodDelete, "/tournament/nevza_oddanesand_24", "organiser", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if _, err := store.GetTournament(context.Background(), "nevza_oddanesand_24"); err == nil {
+		t.Fatal("expected the tournament to be deleted")
+	}
+	if matches, _ := store.ListMatches(context.Background(), "nevza_oddanesand_24"); len(matches) != 0 {
+		t.Fatalf("expected the matches to be deleted, got %d", len(matches))
+	}
+}
+
+func TestDeleteTournamentRevokesMembers(t *testing.T) {
+	store := storage.NewMemory()
+	r := setupCustomRouter(store)
+	ctx := context.Background()
+
+	if w := performRequest(r, http.MethodPost, "/tournament", "organiser", nevza()); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	_ = store.PutMember(ctx, "nevza_oddanesand_24", storage.Member{UserID: "co-director", Role: storage.RoleDirector})
+	_ = store.SaveAccessCode(ctx, storage.AccessCode{Nonce: "nonce-1", Slug: "nevza_oddanesand_24"})
+
+	if w := performRequest(r, http.MethodDelete, "/tournament/nevza_oddanesand_24", "organiser", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	if _, err := store.UseAccessCode(ctx, "nonce-1", "someone", time.Now()); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the access code to be deleted, got %v", err)
+	}
+
+	if w := performRequest(r, http.MethodPost, "/tournament", "new-organiser", nevza()); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	for _, user := range []string{"organiser", "co-director"} {
+		if w := performRequest(r, http.MethodPut, "/tournament/nevza_oddanesand_24", user, nevza()); w.Code != http.StatusForbidden {
+			t.Fatalf("expected status %d for %s, got %d: %s", http.StatusForbidden, user, w.Code, w.Body)
+		}
+	}
+	if w := performRequest(r, http.MethodPut, "/tournament/nevza_oddanesand_24", "new-organiser", nevza()); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+}
+
+func TestUploadMatches(t *testing.T) {
+	store := storage.NewMemory()
+	r := setupCustomRouter(store)
+	if w := performRequest(r, http.MethodPost, "/tournament", "organiser", nevza()); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	match := func(number string, time string) profixio.Match {
+		return profixio.Match{
+			Number:   pointer.String(number),
+			Date:     pointer.String("2024-06-18"),
+			Time:     pointer.String(time),
+			HomeTeam: &profixio.Team{Name: "Norway"},
+			AwayTeam: &profixio.Team{Name: "Sweden"},
+		}
+	}
+	upload := func(matches ...profixio.Match) profixio.CustomTournament {
+		return profixio.CustomTournament{Matches: &matches}
+	}
+
+	cases := []struct {
+		name        string
+		path        string
+		body        profixio.CustomTournament
+		statusCode  int
+		result      UploadResult
+		matchErrors int
+		stored      int
+	}{
+		{name: "create", body: upload(match("1", "09:00"), match("2", "10:00"), match("3", "11:00")), statusCode: http.StatusOK, result: UploadResult{Created: 3}, stored: 3},
+		{name: "same upload again", body: upload(match("1", "09:00"), match("2", "10:00"), match("3", "11:00")), statusCode: http.StatusOK, result: UploadResult{Unchanged: 3}, stored: 3},
+		{name: "invalid", body: upload(match("1", "9"), match("1", "10:00")), statusCode: http.StatusBadRequest, matchErrors: 2, stored: 3},
+		{name: "invalid prune", path: "?prune=maybe", body: upload(match("1", "09:00")), statusCode: http.StatusBadRequest, stored: 3},
+		{name: "update without prune", body: upload(match("1", "09:30")), statusCode: http.StatusOK, result: UploadResult{Updated: 1}, stored: 3},
+		{name: "prune", path: "?prune=true", body: upload(match("1", "09:30"), match("2", "10:00")), statusCode: http.StatusOK, result: UploadResult{Unchanged: 2, Removed: 1}, stored: 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := performRequest(r, http.MethodPost, "/tournament/nevza_oddanesand_24/matches"+c.path, "organiser", c.body)
+			if w.Code != c.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", c.statusCode, w.Code, w.Body)
+			}
+
+			var body struct {
+				Result      UploadResult          `json:"result"`
+				MatchErrors []profixio.MatchError `json:"matchErrors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response body: %v", err)
+			}
+			if body.Result != c.result {
+				t.Fatalf("expected result %+v, got %+v", c.result, body.Result)
+			}
+			if len(body.MatchErrors) != c.matchErrors {
+				t.Fatalf("expected %d match errors, got %+v", c.matchErrors, body.MatchErrors)
+			}
+
+			tournament, err := store.GetTournament(context.Background(), "nevza_oddanesand_24")
+			if err != nil {
+				t.Fatalf("failed to get tournament: %v", err)
+			}
+			if tournament.NumberOfMatches != c.stored {
+				t.Fatalf("expected %d matches, got %d", c.stored, tournament.NumberOfMatches)
+			}
+		})
+	}
+}
+
+func TestImportMatches(t *testing.T) {
+	store := storage.NewMemory()
+	r := setupCustomRouter(store)
+	if w := performRequest(r, http.MethodPost, "/tournament", "organiser", nevza()); w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	importFile := func(path, user, filename, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", filename)
+		part.Write([]byte(content))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/tournament/nevza_oddanesand_24/import"+path, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set(testUserHeader, user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	const schedule = "Number;Date;Time;Court;Home team;Away team;Group;Category\n" +
+		"1;18.06.2024;09:00;Bane 1;Norway;Sweden;A;U18 Boys\n" +
+		"2;18.06.2024;10:00;Bane 2;Denmark;Finland;A;U18 Boys\n"
+
+	cases := []struct {
+		name       string
+		path       string
+		user       string
+		filename   string
+		content    string
+		statusCode int
+		result     UploadResult
+		rowErrors  int
+	}{
+		{name: "without access", user: "someone", filename: "schedule.csv", content: schedule, statusCode: http.StatusForbidden},
+		{name: "import", user: "organiser", filename: "schedule.csv", content: schedule, statusCode: http.StatusOK, result: UploadResult{Created: 2}},
+		{name: "import again", user: "organiser", filename: "schedule.txt", path: "?format=csv", content: schedule, statusCode: http.StatusOK, result: UploadResult{Unchanged: 2}},
+		{name: "row errors", user: "organiser", filename: "schedule.csv", content: "Number,Home team,Away team\n3,Norway,\n3,,Sweden\n", statusCode: http.StatusBadRequest, rowErrors: 3},
+		{name: "unsupported format", user: "organiser", filename: "schedule.pdf", content: schedule, statusCode: http.StatusBadRequest, rowErrors: 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := importFile(c.path, c.user, c.filename, c.content)
+			if w.Code != c.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", c.statusCode, w.Code, w.Body)
+			}
+
+			var body struct {
+				Result    UploadResult `json:"result"`
+				RowErrors []RowError   `json:"rowErrors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response body: %v", err)
+			}
+			if body.Result != c.result {
+				t.Fatalf("expected result %+v, got %+v", c.result, body.Result)
+			}
+			if len(body.RowErrors) != c.rowErrors {
+				t.Fatalf("expected %d row errors, got %+v", c.rowErrors, body.RowErrors)
+			}
+		})
+	}
+
+	matches, err := store.ListMatches(context.Background(), "nevza_oddanesand_24")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d %v", len(matches), err)
+	}
+	for _, match := range matches {
+		if match.Field == nil || match.MatchGroup == nil || match.MatchCategory == nil || *match.Date != "2024-06-18" {
+			t.Fatalf("expected court, group, category and date to be imported, got %+v", match.Match)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package custom
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/xorcare/pointer"
+)
+
+// ErrInvalidSchedule is returned when an imported schedule cannot be turned
+// into matches. The error is a *ScheduleError listing the problems.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule file formats accepted by ImportMatches.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Schedule columns. The header row names them, in any order and case.
+const (
+	columnNumber   = "number"
+	columnDate     = "date"
+	columnTime     = "time"
+	columnCourt    = "court"
+	columnHomeTeam = "home team"
+	columnAwayTeam = "away team"
+	columnGroup    = "group"
+	columnCategory = "category"
+)
+
+var columnAliases = map[string]string{
+	"no":       columnNumber,
+	"match":    columnNumber,
+	"field":    columnCourt,
+	"home":     columnHomeTeam,
+	"hometeam": columnHomeTeam,
+	"away":     columnAwayTeam,
+	"awayteam": columnAwayTeam,
+	"pool":     columnGroup,
+	"class":    columnCategory,
+}
+
+var requiredColumns = []string{columnNumber, columnHomeTeam, columnAwayTeam}
+
+// matchFieldColumns maps the fields reported by custom tournament validation
+// onto schedule columns.
+var matchFieldColumns = map[string]string{
+	"number":   columnNumber,
+	"date":     columnDate,
+	"time":     columnTime,
+	"homeTeam": columnHomeTeam,
+	"awayTeam": columnAwayTeam,
+}
+
+// RowError is a problem with one row of an imported schedule. Row is the
+// 1-based row in the file, or 0 for problems with the file itself.
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ScheduleError lists every problem found in an imported schedule.
+type ScheduleError struct {
+	Errors []RowError
+}
+
+func (e *ScheduleError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, rowError := range e.Errors {
+		if rowError.Column == "" {
+			messages = append(messages, fmt.Sprintf("row %d: %s", rowError.Row, rowError.Message))
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("row %d: %s: %s", rowError.Row, rowError.Column, rowError.Message))
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidSchedule, strings.Join(messages, "; "))
+}
+
+func (e *ScheduleError) Unwrap() error {
+	return ErrInvalidSchedule
+}
+
+func fileError(format string, args ...any) error {
+	return &ScheduleError{Errors: []RowError{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+// record is one non-empty row of a schedule file.
+type record struct {
+	row    int
+	values []string
+}
+
+// schedule is a parsed schedule with the file row of every match.
+type schedule struct {
+	matches []profixio.Match
+	rows    []int
+}
+
+// rowErrors translates custom tournament validation errors onto file rows.
+func (s schedule) rowErrors(validationErr *profixio.ValidationError) *ScheduleError {
+	errs := make([]RowError, 0, len(validationErr.Errors))
+	for _, matchError := range validationErr.Errors {
+		rowError := RowError{Column: matchError.Field, Message: matchError.Message}
+		if column, ok := matchFieldColumns[matchError.Field]; ok {
+			rowError.Column = column
+		}
+		if matchError.Index >= 0 && matchError.Index < len(s.rows) {
+			rowError.Row = s.rows[matchError.Index]
+		}
+		if strings.HasPrefix(matchError.Message, "duplicates match ") {
+			first, err := strconv.Atoi(strings.TrimPrefix(matchError.Message, "duplicates match "))
+			if err == nil && first >= 0 && first < len(s.rows) {
+				rowError.Message = fmt.Sprintf("duplicates row %d", s.rows[first])
+			}
+		}
+		errs = append(errs, rowError)
+	}
+	return &ScheduleError{Errors: errs}
+}
+
+// parseSchedule reads a CSV or XLSX schedule.
+func parseSchedule(format string, data []byte) (schedule, error) {
+	var (
+		records []record
+		err     error
+	)
+	switch format {
+	case FormatCSV:
+		records, err = readCSV(data)
+	case FormatXLSX:
+		records, err = readXLSX(data)
+	default:
+		return schedule{}, fileError("unsupported format %q", format)
+	}
+	if err != nil {
+		return schedule{}, err
+	}
+	return parseRecords(records)
+}
+
+// parseRecords maps the header row onto columns and every following row onto
+// a match.
+func parseRecords(records []record) (schedule, error) {
+	if len(records) == 0 {
+		return schedule{}, fileError("schedule is empty")
+	}
+
+	header := records[0]
+	columns := map[string]int{}
+	var errs []RowError
+	for i, name := range header.values {
+		column := strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if alias, ok := columnAliases[column]; ok {
+			column = alias
+		}
+		if column == "" {
+			continue
+		}
+		if _, duplicate := columns[column]; duplicate {
+			errs = append(errs, RowError{Row: header.row, Column: column, Message: "column appears more than once"})
+			continue
+		}
+		columns[column] = i
+	}
+	for _, column := range requiredColumns {
+		if _, ok := columns[column]; !ok {
+			errs = append(errs, RowError{Row: header.row, Column: column, Message: "column is missing"})
+		}
+	}
+	if len(errs) > 0 {
+		return schedule{}, &ScheduleError{Errors: errs}
+	}
+	if len(records) == 1 {
+		return schedule{}, fileError("schedule has no matches")
+	}
+
+	parsed := schedule{
+		matches: make([]profixio.Match, 0, len(records)-1),
+		rows:    make([]int, 0, len(records)-1),
+	}
+	for _, rec := range records[1:] {
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(rec.values) {
+				return ""
+			}
+			return strings.TrimSpace(rec.values[i])
+		}
+
+		match := profixio.Match{
+			Number:   pointer.String(value(columnNumber)),
+			HomeTeam: &profixio.Team{Name: value(columnHomeTeam)},
+			AwayTeam: &profixio.Team{Name: value(columnAwayTeam)},
+		}
+		if date := value(columnDate); date != "" {
+			normalized, err := normalizeDate(date)
+			if err != nil {
+				errs = append(errs, RowError{Row: rec.row, Column: columnDate, Message: err.Error()})
+			} else {
+				match.Date = pointer.String(normalized)
+			}
+		}
+		if clock := value(columnTime); clock != "" {
+			normalized, err := normalizeTime(clock)
+			if err != nil {
+				errs = append(errs, RowError{Row: rec.row, Column: columnTime, Message: err.Error()})
+			} else {
+				match.Time = pointer.String(normalized)
+			}
+		}
+		if court := value(columnCourt); court != "" {
+			match.Field = &profixio.Field{Name: pointer.String(court)}
+		}
+		if group := value(columnGroup); group != "" {
+			match.MatchGroup = &profixio.Group{Name: pointer.String(group), DisplayName: pointer.String(group)}
+		}
+		if category := value(columnCategory); category != "" {
+			match.MatchCategory = &profixio.Category{Name: pointer.String(category)}
+		}
+
+		parsed.matches = append(parsed.matches, match)
+		parsed.rows = append(parsed.rows, rec.row)
+	}
+
+	var validationErr *profixio.ValidationError
+	if errors.As(profixio.ValidateCustomTournament("", profixio.CustomTournament{Matches: &parsed.matches}), &validationErr) {
+		errs = append(errs, parsed.rowErrors(validationErr).Errors...)
+	}
+	if len(errs) > 0 {
+		slices.SortStableFunc(errs, func(a, b RowError) int { return cmp.Compare(a.Row, b.Row) })
+		return schedule{}, &ScheduleError{Errors: errs}
+	}
+	return parsed, nil
+}
+
+// normalizeDate accepts YYYY-MM-DD, DD.MM.YYYY and spreadsheet serial dates.
+func normalizeDate(value string) (string, error) {
+	for _, layout := range []string{"2006-01-02", "02.01.2006", "2.1.2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format("2006-01-02"), nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 {
+		return spreadsheetEpoch.AddDate(0, 0, int(serial)).Format("2006-01-02"), nil
+	}
+	return "", fmt.Errorf("%q is not YYYY-MM-DD or DD.MM.YYYY", value)
+}
+
+// normalizeTime accepts HH:MM, HH:MM:SS, HH.MM and spreadsheet day fractions.
+func normalizeTime(value string) (string, error) {
+	for _, layout := range []string{"15:04", "15:04:05", "15.04"} {
+		if clock, err := time.Parse(layout, value); err == nil {
+			return clock.Format("15:04"), nil
+		}
+	}
+	if fraction, err := strconv.ParseFloat(value, 64); err == nil && fraction >= 0 && fraction < 1 {
+		minutes := int(math.Round(fraction * 24 * 60))
+		return fmt.Sprintf("%02d:%02d", minutes/60%24, minutes%60), nil
+	}
+	return "", fmt.Errorf("%q is not HH:MM", value)
+}
+
+// spreadsheetEpoch is day zero of spreadsheet serial dates.
+var spreadsheetEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// readCSV reads comma or semicolon separated rows. Spreadsheets in Norwegian
+// locales export with semicolons.
+func readCSV(data []byte) ([]record, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	firstLine, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+
+	var records []record
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &ScheduleError{Errors: []RowError{{Row: parseErr.StartLine, Message: parseErr.Err.Error()}}}
+			}
+			return nil, fileError("%v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if !emptyRow(values) {
+			records = append(records, record{row: line, values: values})
+		}
+	}
+	return records, nil
+}
+
+func emptyRow(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var text strings.Builder
+	for _, run := range t.Runs {
+		text.WriteString(run.Text)
+	}
+	return text.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Limits of xlsx files. Spreadsheets have at most 16384 columns, A to XFD.
+// Parts of the workbook are read up to maxXLSXPartSize uncompressed, so a
+// small upload cannot expand without bound.
+const (
+	maxXLSXColumns  = 16384
+	maxXLSXPartSize = 20 << 20
+)
+
+// readXLSX reads the first worksheet of a workbook. Only cell values are
+// read; formulas are taken from their cached results.
+func readXLSX(data []byte) ([]record, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fileError("not an xlsx file: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXML(file, &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	file, ok := files[sheetPath]
+	if !ok {
+		return nil, fileError("xlsx file has no worksheet %s", sheetPath)
+	}
+	var sheet xlsxSheet
+	if err := decodeXML(file, &sheet); err != nil {
+		return nil, err
+	}
+
+	var records []record
+	for i, row := range sheet.Rows {
+		number := row.Number
+		if number == 0 {
+			number = i + 1
+		}
+		var values []string
+		for j, cell := range row.Cells {
+			column := j
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+			if column < 0 || column >= maxXLSXColumns {
+				return nil, &ScheduleError{Errors: []RowError{{Row: number, Message: fmt.Sprintf("cell %q has an invalid reference", cell.Ref)}}}
+			}
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, &ScheduleError{Errors: []RowError{{Row: number, Message: fmt.Sprintf("cell %s refers to a missing shared string", cell.Ref)}}}
+				}
+				value = sharedStrings.Items[index].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			}
+			for len(values) <= column {
+				values = append(values, "")
+			}
+			values[column] = value
+		}
+		if !emptyRow(values) {
+			records = append(records, record{row: number, values: values})
+		}
+	}
+	return records, nil
+}
+
+// firstSheetPath resolves the first sheet of the workbook to its file.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	workbookFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fileError("xlsx file has no workbook")
+	}
+	var workbook xlsxWorkbook
+	if err := decodeXML(workbookFile, &workbook); err != nil {
+		return "", err
+	}
+	relsFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if len(workbook.Sheets) == 0 || !ok {
+		return fallback, nil
+	}
+	var rels xlsxRelationships
+	if err := decodeXML(relsFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+func decodeXML(file *zip.File, v any) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fileError("failed to open %s: %v", file.Name, err)
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxXLSXPartSize + 1}
+	err = xml.NewDecoder(limited).Decode(v)
+	if limited.N <= 0 {
+		return fileError("%s is larger than %d MB uncompressed", file.Name, maxXLSXPartSize>>20)
+	}
+	if err != nil {
+		return fileError("failed to read %s: %v", file.Name, err)
+	}
+	return nil
+}
+
+// columnIndex turns the letters of a cell reference like "C7" into a 0-based
+// column. It returns -1 if the reference has no column letters or names a
+// column past maxXLSXColumns.
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A') + 1
+		if index > maxXLSXColumns {
+			return -1
+		}
+	}
+	return index - 1
+}
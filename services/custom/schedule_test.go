@@ -0,0 +1,177 @@
+package custom
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/xorcare/pointer"
+)
+
+// xlsxFile builds a minimal workbook whose first sheet holds the given XML
+// rows, with cells referring to the shared strings.
+func xlsxFile(t *testing.T, sharedStrings []string, rows string) []byte {
+	t.Helper()
+
+	var items bytes.Buffer
+	for _, s := range sharedStrings {
+		items.WriteString("<si><t>" + s + "</t></si>")
+	}
+	files := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Schedule" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Target="worksheets/schedule.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + items.String() + `</sst>`,
+		"xl/worksheets/schedule.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`,
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close xlsx: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func scheduleMatch(number, date, clock, court, home, away, group, category string) profixio.Match {
+	return profixio.Match{
+		Number:        pointer.String(number),
+		Date:          pointer.String(date),
+		Time:          pointer.String(clock),
+		HomeTeam:      &profixio.Team{Name: home},
+		AwayTeam:      &profixio.Team{Name: away},
+		Field:         &profixio.Field{Name: pointer.String(court)},
+		MatchGroup:    &profixio.Group{Name: pointer.String(group), DisplayName: pointer.String(group)},
+		MatchCategory: &profixio.Category{Name: pointer.String(category)},
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	expected := []profixio.Match{
+		scheduleMatch("1", "2024-06-18", "09:00", "Bane 1", "Norway", "Sweden", "A", "U18 Boys"),
+		scheduleMatch("2", "2024-06-18", "10:30", "Bane 2", "Denmark", "Finland", "B", "U18 Girls"),
+	}
+
+	cases := []struct {
+		name     string
+		format   string
+		data     []byte
+		expected []profixio.Match
+		rows     []int
+		errors   []RowError
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			data: []byte("Number,Date,Time,Court,Home team,Away team,Group,Category\n" +
+				"1,2024-06-18,09:00,Bane 1,Norway,Sweden,A,U18 Boys\n" +
+				"\n" +
+				"2,2024-06-18,10:30,Bane 2,Denmark,Finland,B,U18 Girls\n"),
+			expected: expected,
+			rows:     []int{2, 4},
+		},
+		{
+			name:   "semicolon csv with local dates and aliases",
+			format: FormatCSV,
+			data: []byte("\ufeffMatch;Home;Away;Date;Time;Field;Pool;Class\n" +
+				"1;Norway;Sweden;18.06.2024;09.00;Bane 1;A;U18 Boys\n" +
+				"2;Denmark;Finland;18.06.2024;10:30;Bane 2;B;U18 Girls\n"),
+			expected: expected,
+			rows:     []int{2, 3},
+		},
+		{
+			name:   "xlsx with serial dates",
+			format: FormatXLSX,
+			data: xlsxFile(t, []string{"Number", "Date", "Time", "Court", "Home team", "Away team", "Group", "Category", "Bane 1", "Norway", "Sweden", "A", "U18 Boys"}, ``+
+				`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c><c r="E1" t="s"><v>4</v></c><c r="F1" t="s"><v>5</v></c><c r="G1" t="s"><v>6</v></c><c r="H1" t="s"><v>7</v></c></row>`+
+				`<row r="3"><c r="A3"><v>1</v></c><c r="B3"><v>45461</v></c><c r="C3"><v>0.375</v></c><c r="D3" t="s"><v>8</v></c><c r="E3" t="s"><v>9</v></c><c r="F3" t="s"><v>10</v></c><c r="G3" t="s"><v>11</v></c><c r="H3" t="s"><v>12</v></c></row>`+
+				`<row r="4"><c r="A4" t="inlineStr"><is><t>2</t></is></c><c r="B4" t="str"><v>2024-06-18</v></c><c r="C4" t="str"><v>10:30</v></c><c r="D4" t="inlineStr"><is><t>Bane 2</t></is></c><c r="E4" t="inlineStr"><is><t>Denmark</t></is></c><c r="F4" t="inlineStr"><is><t>Finland</t></is></c><c r="G4" t="inlineStr"><is><r><t>B</t></r></is></c><c r="H4" t="inlineStr"><is><r><t>U18 </t></r><r><t>Girls</t></r></is></c></row>`),
+			expected: expected,
+			rows:     []int{3, 4},
+		},
+		{
+			name:   "missing columns",
+			format: FormatCSV,
+			data:   []byte("Number,Date,Home team\n1,2024-06-18,Norway\n"),
+			errors: []RowError{{Row: 1, Column: columnAwayTeam, Message: "column is missing"}},
+		},
+		{
+			name:   "row errors",
+			format: FormatCSV,
+			data: []byte("Number,Date,Time,Home team,Away team\n" +
+				"1,18/06/2024,09:00,Norway,Sweden\n" +
+				"2,2024-06-18,late,Denmark,\n" +
+				"1,2024-06-18,10:00,Norway,Finland\n"),
+			errors: []RowError{
+				{Row: 2, Column: columnDate, Message: `"18/06/2024" is not YYYY-MM-DD or DD.MM.YYYY`},
+				{Row: 3, Column: columnTime, Message: `"late" is not HH:MM`},
+				{Row: 3, Column: columnAwayTeam, Message: "is required"},
+				{Row: 4, Column: columnNumber, Message: "duplicates row 2"},
+			},
+		},
+		{
+			name:   "not a workbook",
+			format: FormatXLSX,
+			data:   []byte("Number,Home team,Away team\n"),
+			errors: []RowError{{Message: "not an xlsx file: zip: not a valid zip file"}},
+		},
+		{
+			name:   "xlsx cell without column",
+			format: FormatXLSX,
+			data:   xlsxFile(t, nil, `<row r="1"><c r="1"><v>x</v></c></row>`),
+			errors: []RowError{{Row: 1, Message: `cell "1" has an invalid reference`}},
+		},
+		{
+			name:   "xlsx cell past the last column",
+			format: FormatXLSX,
+			data:   xlsxFile(t, nil, `<row r="1"><c r="XFDZZZZ1"><v>x</v></c></row>`),
+			errors: []RowError{{Row: 1, Message: `cell "XFDZZZZ1" has an invalid reference`}},
+		},
+		{
+			name:   "xlsx part too large",
+			format: FormatXLSX,
+			data:   xlsxFile(t, nil, strings.Repeat(" ", maxXLSXPartSize)),
+			errors: []RowError{{Message: "xl/worksheets/schedule.xml is larger than 20 MB uncompressed"}},
+		},
+		{
+			name:   "unsupported format",
+			format: "pdf",
+			errors: []RowError{{Message: `unsupported format "pdf"`}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			parsed, err := parseSchedule(c.format, c.data)
+			if c.errors != nil {
+				var scheduleErr *ScheduleError
+				if !errors.As(err, &scheduleErr) || !errors.Is(err, ErrInvalidSchedule) {
+					t.Fatalf("expected a schedule error, got %v", err)
+				}
+				if !reflect.DeepEqual(scheduleErr.Errors, c.errors) {
+					t.Fatalf("expected %+v, got %+v", c.errors, scheduleErr.Errors)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(parsed.matches, c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, parsed.matches)
+			}
+			if !reflect.DeepEqual(parsed.rows, c.rows) {
+				t.Fatalf("expected rows %v, got %v", c.rows, parsed.rows)
+			}
+		})
+	}
+}
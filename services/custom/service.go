@@ -0,0 +1,349 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/samborkent/uuidv7"
+)
+
+var (
+	// ErrInvalidTournament is returned when a tournament request fails
+	// validation.
+	ErrInvalidTournament = errors.New("invalid tournament")
+	// ErrAlreadyExists is returned when creating a tournament whose slug is
+	// taken.
+	ErrAlreadyExists = errors.New("tournament already exists")
+	// ErrNotCustom is returned when the tournament is synced from Profixio.
+	ErrNotCustom = errors.New("not a custom tournament")
+	// ErrForbidden is returned when the user may not manage the tournament.
+	ErrForbidden = authz.ErrForbidden
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Profixio is the part of the Profixio integration the custom service relies on.
+type Profixio interface {
+	ProcessCustomTournament(ctx context.Context, slug string, customTournament profixio.CustomTournament) (profixio.SyncStats, error)
+}
+
+type CustomService struct {
+	store           storage.Store
+	profixioService Profixio
+	permissions     *authz.Permissions
+}
+
+func NewCustomService(store storage.Store, permissions *authz.Permissions, profixioService Profixio) *CustomService {
+	return &CustomService{
+		store:           store,
+		profixioService: profixioService,
+		permissions:     permissions,
+	}
+}
+
+func (s *CustomService) ListTournaments(c *gin.Context) ([]Tournament, error) {
+	docs, err := s.store.ListTournaments(c, storage.TournamentFilter{Type: storage.TournamentTypeCustom})
+	if err != nil {
+		return nil, err
+	}
+
+	tournaments := make([]Tournament, 0, len(docs))
+	for _, doc := range docs {
+		tournaments = append(tournaments, toTournament(doc))
+	}
+	return tournaments, nil
+}
+
+func (s *CustomService) GetTournament(c *gin.Context, slug string) (*Tournament, error) {
+	stored, err := s.getCustomTournament(c, slug)
+	if err != nil {
+		return nil, err
+	}
+	tournament := toTournament(stored)
+	return &tournament, nil
+}
+
+// CreateTournament stores a new custom tournament with a fresh access secret
+// and makes the creator its director.
+func (s *CustomService) CreateTournament(c *gin.Context, request TournamentRequest) (*Tournament, error) {
+	if err := validateTournament(request); err != nil {
+		return nil, err
+	}
+
+	_, err := s.store.GetTournament(c, request.Slug)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyExists, request.Slug)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	if err := s.store.PutCustomTournament(c, toStorageTournament(request)); err != nil {
+		return nil, err
+	}
+
+	secret := uuidv7.New().String()
+	if err := s.store.EnsureTournamentSecrets(c, profixio.TournamentSecrets{
+		ID:     &request.ID,
+		Slug:   &request.Slug,
+		Secret: &secret,
+	}); err != nil {
+		return nil, err
+	}
+
+	userID := authz.MustPrincipal(c).UserID
+	if err := s.store.GrantAccess(c, request.Slug, userID); err != nil {
+		return nil, err
+	}
+	if err := s.store.PutMember(c, request.Slug, storage.Member{UserID: userID, Role: storage.RoleDirector, GrantedAt: time.Now()}); err != nil {
+		return nil, err
+	}
+	log.Info("custom tournament created", log.Fields{"operation": "createCustomTournament", "slug": request.Slug, "userID": userID})
+
+	return s.GetTournament(c, request.Slug)
+}
+
+// UpdateTournament replaces the details of a custom tournament.
+func (s *CustomService) UpdateTournament(c *gin.Context, slug string, request TournamentRequest) (*Tournament, error) {
+	request.Slug = slug
+	if err := validateTournament(request); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.PutCustomTournament(c, toStorageTournament(request)); err != nil {
+		return nil, err
+	}
+	log.Info("custom tournament updated", log.Fields{"operation": "updateCustomTournament", "slug": slug})
+
+	return s.GetTournament(c, slug)
+}
+
+// DeleteTournament deletes a custom tournament with its matches, secrets,
+// members and access codes.
+func (s *CustomService) DeleteTournament(c *gin.Context, slug string) error {
+	if err := s.authorize(c, slug); err != nil {
+		return err
+	}
+
+	matches, err := s.store.ListMatches(c, slug)
+	if err != nil {
+		return err
+	}
+	numbers := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if match.Number != nil {
+			numbers = append(numbers, *match.Number)
+		}
+	}
+	if err := s.store.DeleteMatches(c, slug, numbers); err != nil {
+		return err
+	}
+	if err := s.store.DeleteMembers(c, slug); err != nil {
+		return err
+	}
+	if err := s.store.DeleteAccessCodes(c, slug); err != nil {
+		return err
+	}
+	if err := s.store.DeleteTournamentSecrets(c, slug); err != nil {
+		return err
+	}
+	if err := s.store.DeleteTournament(c, slug); err != nil {
+		return err
+	}
+	log.Info("custom tournament deleted", log.Fields{"operation": "deleteCustomTournament", "slug": slug, "matches": len(numbers)})
+	return nil
+}
+
+// UploadMatches validates and stores the matches of a custom tournament. With
+// prune, stored matches missing from the upload are deleted.
+func (s *CustomService) UploadMatches(c *gin.Context, slug string, tournament profixio.CustomTournament, prune bool) (*UploadResult, error) {
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
+	}
+	return s.uploadMatches(c, slug, tournament, prune)
+}
+
+// ImportMatches reads a CSV or XLSX schedule and uploads its matches like
+// UploadMatches. Problems are reported per row in a *ScheduleError.
+func (s *CustomService) ImportMatches(c *gin.Context, slug string, format string, data []byte, prune bool) (*UploadResult, error) {
+	if err := s.authorize(c, slug); err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseSchedule(format, data)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("custom schedule parsed", log.Fields{"operation": "importCustomMatches", "slug": slug, "format": format, "matches": len(parsed.matches)})
+
+	result, err := s.uploadMatches(c, slug, profixio.CustomTournament{Matches: &parsed.matches}, prune)
+	var validationErr *profixio.ValidationError
+	if errors.As(err, &validationErr) {
+		return nil, parsed.rowErrors(validationErr)
+	}
+	return result, err
+}
+
+func (s *CustomService) uploadMatches(c *gin.Context, slug string, tournament profixio.CustomTournament, prune bool) (*UploadResult, error) {
+	stats, err := s.profixioService.ProcessCustomTournament(c, slug, tournament)
+	if err != nil {
+		return nil, err
+	}
+	result := &UploadResult{
+		Created:   stats.MatchesCreated,
+		Updated:   stats.MatchesUpdated,
+		Unchanged: stats.MatchesUnchanged,
+	}
+
+	if prune {
+		removed, err := s.pruneMatches(c, slug, *tournament.Matches)
+		if err != nil {
+			return nil, err
+		}
+		result.Removed = removed
+	}
+
+	numberOfMatches, err := s.store.CountMatches(c, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetNumberOfMatches(c, slug, numberOfMatches); err != nil {
+		return nil, err
+	}
+	log.Info("custom matches uploaded", log.Fields{"operation": "uploadCustomMatches", "slug": slug, "created": result.Created, "updated": result.Updated, "unchanged": result.Unchanged, "removed": result.Removed})
+	return result, nil
+}
+
+// pruneMatches deletes the stored matches whose numbers are not uploaded.
+func (s *CustomService) pruneMatches(ctx context.Context, slug string, uploaded []profixio.Match) (int, error) {
+	keep := map[string]bool{}
+	for _, match := range uploaded {
+		keep[strings.TrimSpace(*match.Number)] = true
+	}
+
+	stored, err := s.store.ListMatches(ctx, slug)
+	if err != nil {
+		return 0, err
+	}
+	var removed []string
+	for _, match := range stored {
+		if match.Number != nil && !keep[*match.Number] {
+			removed = append(removed, *match.Number)
+		}
+	}
+	if err := s.store.DeleteMatches(ctx, slug, removed); err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+func (s *CustomService) getCustomTournament(ctx context.Context, slug string) (*storage.Tournament, error) {
+	tournament, err := s.store.GetTournament(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if tournament.Type != storage.TournamentTypeCustom {
+		return nil, fmt.Errorf("%w: %s", ErrNotCustom, slug)
+	}
+	return tournament, nil
+}
+
+// authorize checks that the slug is a custom tournament the user may
+// manage.
+func (s *CustomService) authorize(c *gin.Context, slug string) error {
+	if _, err := s.getCustomTournament(c, slug); err != nil {
+		return err
+	}
+	return s.permissions.Authorize(c, authz.MustPrincipal(c), authz.ActionManage, authz.Scope{Slug: slug})
+}
+
+func validateTournament(request TournamentRequest) error {
+	var problems []string
+	if !slugPattern.MatchString(request.Slug) {
+		problems = append(problems, "slug must be lowercase letters, digits, - or _")
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+
+	startDate, startErr := time.Parse("2006-01-02", request.StartDate)
+	if startErr != nil {
+		problems = append(problems, "startDate must be YYYY-MM-DD")
+	}
+	endDate, endErr := time.Parse("2006-01-02", request.EndDate)
+	if endErr != nil {
+		problems = append(problems, "endDate must be YYYY-MM-DD")
+	}
+	if startErr == nil && endErr == nil && endDate.Before(startDate) {
+		problems = append(problems, "endDate must not be before startDate")
+	}
+
+	for _, category := range request.Categories {
+		if strings.TrimSpace(category) == "" {
+			problems = append(problems, "categories must not be empty")
+			break
+		}
+	}
+
+	if request.Scoring != nil {
+		if err := request.Scoring.WithDefaults().Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("scoring: %v", err))
+		}
+	}
+	for category, rules := range request.CategoryScoring {
+		if !slices.Contains(request.Categories, category) {
+			problems = append(problems, fmt.Sprintf("categoryScoring: %q is not a category", category))
+			continue
+		}
+		if err := rules.WithDefaults().Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("categoryScoring %q: %v", category, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidTournament, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func toStorageTournament(request TournamentRequest) storage.Tournament {
+	var tournamentScoring *scoring.Rules
+	if request.Scoring != nil {
+		rules := request.Scoring.WithDefaults()
+		tournamentScoring = &rules
+	}
+	var categoryScoring map[string]scoring.Rules
+	for category, rules := range request.CategoryScoring {
+		if categoryScoring == nil {
+			categoryScoring = map[string]scoring.Rules{}
+		}
+		categoryScoring[category] = rules.WithDefaults()
+	}
+
+	return storage.Tournament{
+		ID:              request.ID,
+		Slug:            request.Slug,
+		Name:            strings.TrimSpace(request.Name),
+		Type:            storage.TournamentTypeCustom,
+		StartDate:       request.StartDate,
+		EndDate:         request.EndDate,
+		EventType:       strings.TrimSpace(request.Type),
+		Categories:      request.Categories,
+		Scoring:         tournamentScoring,
+		CategoryScoring: categoryScoring,
+	}
+}
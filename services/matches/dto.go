@@ -1,12 +1,6 @@
 package matches
 
-type Event struct {
-	Author    string `firestore:"author"`
-	EventType string `firestore:"eventType"`
-	ID        string `firestore:"id"`
-	PlayerID  int    `firestore:"playerId"`
-	Reference string `firestore:"reference"`
-	Team      string `firestore:"team"`
-	Timestamp int64  `firestore:"timestamp"`
-	Undone    string `firestore:"undone"`
-}
+import "github.com/nvbf/tournament-sync/repos/storage"
+
+// Event is a scoreboard event as stored in the match's events collection.
+type Event = storage.Event
@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 // Router is the interface for a router.
@@ -25,6 +27,7 @@ type Router interface {
 type Results interface {
 	ReportResult(c *gin.Context, matchID string) error
 	FinalizeResult(c *gin.Context, matchID string) error
+	Timeline(c *gin.Context, matchID string) (*Timeline, error)
 }
 
 // HTTPOptions contains all the options needed for the HTTP handler.
@@ -35,20 +38,32 @@ type HTTPOptions struct {
 
 	// The router instance to configure the HTTP routes.
 	Router Router
+
+	// Guard checks the caller's role in the tournament of the match. Nil
+	// lets every authenticated user through.
+	Guard authz.Guard
 }
 
 // NewHTTPHandler creates a new HTTP handler.
 func NewHTTPHandler(opts HTTPOptions) {
 	r := opts.Router
 	h := &httpHandler{opts}
-	r.GET("/result/:match_id", h.resultHandler)
-	r.PUT("/result/finalize/:match_id", h.finalizeResultHandler)
+	r.GET("/result/:match_id", h.guard(authz.ActionReport), h.resultHandler)
+	r.PUT("/result/finalize/:match_id", h.guard(authz.ActionReport), h.finalizeResultHandler)
+	r.GET("/:match_id/timeline", h.guard(authz.ActionView), h.timelineHandler)
 }
 
 type httpHandler struct {
 	HTTPOptions
 }
 
+func (h *httpHandler) guard(action authz.Action) gin.HandlerFunc {
+	if h.Guard == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return h.Guard(action)
+}
+
 func (h *httpHandler) resultHandler(c *gin.Context) {
 	matchID := c.Param("match_id")
 	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "result", "path": c.FullPath(), "matchID": matchID}))
@@ -124,6 +139,32 @@ func (h *httpHandler) finalizeResultHandler(c *gin.Context) {
 	})
 }
 
+func (h *httpHandler) timelineHandler(c *gin.Context) {
+	matchID := c.Param("match_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "timeline", "path": c.FullPath(), "matchID": matchID}))
+
+	timeline, err := h.Service.Timeline(c, matchID)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Warning("request failed", log.WithRequest(c, log.Fields{"handler": "timeline", "path": c.FullPath(), "matchID": matchID, "reason": "not_found"}))
+		c.JSON(http.StatusNotFound, gin.H{"error": "match not found"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "timeline", "path": c.FullPath(), "matchID": matchID}))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		c.Abort()
+		return
+	}
+
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "timeline", "path": c.FullPath(), "matchID": matchID, "entries": len(timeline.Entries)}))
+	if c.Query("format") == "text" || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.String(http.StatusOK, timeline.Text())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"timeline": timeline})
+}
+
 func setFinalizeRetryHeaders(c *gin.Context, err error) {
 	tooSoonErr := &FinalizeTooSoonError{}
 	if !errors.As(err, &tooSoonErr) {
@@ -6,16 +6,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 type testResultsService struct {
 	reportErr           error
 	finalizeErr         error
+	timelineErr         error
 	reportCalled        int
 	finalizeCalled      int
 	lastReportMatchID   string
@@ -34,6 +38,13 @@ func (s *testResultsService) FinalizeResult(_ *gin.Context, matchID string) erro
 	return s.finalizeErr
 }
 
+func (s *testResultsService) Timeline(_ *gin.Context, matchID string) (*Timeline, error) {
+	if s.timelineErr != nil {
+		return nil, s.timelineErr
+	}
+	return buildTimeline(matchID, buildValidTwoSetMatchEvents(1_700_000_000_000), scoring.Beach()), nil
+}
+
 func setupMatchesRouter(service Results) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -254,3 +265,59 @@ func TestFinalizeResultHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTimelineHandler(t *testing.T) {
+	cases := []struct {
+		name           string
+		timelineErr    error
+		path           string
+		accept         string
+		expectedStatus int
+		expectedType   string
+	}{
+		{name: "json", path: "/match-42/timeline", expectedStatus: http.StatusOK, expectedType: gin.MIMEJSON},
+		{name: "text format", path: "/match-42/timeline?format=text", expectedStatus: http.StatusOK, expectedType: gin.MIMEPlain},
+		{name: "text accept header", path: "/match-42/timeline", accept: "text/plain", expectedStatus: http.StatusOK, expectedType: gin.MIMEPlain},
+		{name: "not found", timelineErr: storage.ErrNotFound, path: "/match-42/timeline", expectedStatus: http.StatusNotFound, expectedType: gin.MIMEJSON},
+		{name: "internal error", timelineErr: errors.New("boom"), path: "/match-42/timeline", expectedStatus: http.StatusInternalServerError, expectedType: gin.MIMEJSON},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := setupMatchesRouter(&testResultsService{timelineErr: c.timelineErr})
+
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body)
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, c.expectedType) {
+				t.Fatalf("expected content type %s, got %s", c.expectedType, contentType)
+			}
+			if c.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if c.expectedType == gin.MIMEPlain {
+				if !strings.HasPrefix(w.Body.String(), "Match match-42: 2-0 (21-0, 21-0)\n") {
+					t.Fatalf("expected a text timeline, got %q", w.Body)
+				}
+				return
+			}
+			var body struct {
+				Timeline Timeline `json:"timeline"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to parse response body: %v", err)
+			}
+			if body.Timeline.MatchID != "match-42" || len(body.Timeline.Entries) != 44 || len(body.Timeline.Sets) != 2 {
+				t.Fatalf("expected 44 entries over 2 sets, got %d entries and %d sets", len(body.Timeline.Entries), len(body.Timeline.Sets))
+			}
+		})
+	}
+}
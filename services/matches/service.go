@@ -6,16 +6,18 @@ import (
 	"sort"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
-	auth "firebase.google.com/go/v4/auth"
-	"google.golang.org/api/iterator"
+	"github.com/xorcare/pointer"
 
 	"github.com/gin-gonic/gin"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/nvbf/tournament-sync/pkg/replay"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
 	"github.com/samborkent/uuidv7"
 
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 var (
@@ -38,148 +40,106 @@ func (e *FinalizeTooSoonError) Unwrap() error {
 }
 
 type MatchesService struct {
-	firestoreClient *firestore.Client
+	store           storage.Store
 	firebaseApp     *firebase.App
-	profixioService *profixio.Service
+	profixioService profixio.ResultSink
 }
 
-func NewMatchesService(firestoreClient *firestore.Client, firebaseApp *firebase.App, profixioService *profixio.Service) *MatchesService {
+func NewMatchesService(store storage.Store, firebaseApp *firebase.App, profixioService profixio.ResultSink) *MatchesService {
 	return &MatchesService{
-		firestoreClient: firestoreClient,
+		store:           store,
 		firebaseApp:     firebaseApp,
 		profixioService: profixioService,
 	}
 }
 
+// ReportResult posts the result of the match to Profixio. The route's Guard
+// checks that the user may report results of the match's court.
 func (s *MatchesService) ReportResult(c *gin.Context, matchID string) error {
-	token := c.MustGet("token").(*auth.Token)
+	principal := authz.MustPrincipal(c)
 
-	_, err := s.firestoreClient.Collection("Matches").Doc(matchID).Update(c,
-		[]firestore.Update{
-			{Path: "AutoReport", Value: false},
-		},
-	)
+	err := s.store.UpdateScoreboard(c, matchID, storage.ScoreboardUpdate{AutoReport: pointer.Bool(false)})
 	if err != nil {
 		log.Printf("Failed to update match in Firestore: %v\n", err)
 		return err
 	}
 
-	iter := s.firestoreClient.Collection("Matches").Doc(matchID).Collection("events").Documents(c)
-	defer iter.Stop()
+	events, err := s.getMatchEvents(c, matchID)
+	if err != nil {
+		return nil
+	}
 
 	authorMissmatches := 0
-
-	var events []Event
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			if err == iterator.Done {
-				break
-			}
-			log.Printf("Failed to get document: %v\n", err)
-			return nil
-		}
-
-		var event Event
-		if err := doc.DataTo(&event); err != nil {
-			log.Printf("Failed to decode document: %v\n", err)
-			return nil
-		}
-		if event.Author != token.UID {
-			log.Printf("For event: %s - %s: Not the same author: %s vs. %s", event.EventType, event.ID, token.UID, event.Author)
+	for _, event := range events {
+		if event.Author != principal.UserID {
+			log.Printf("For event: %s - %s: Not the same author: %s vs. %s", event.EventType, event.ID, principal.UserID, event.Author)
 			authorMissmatches++
 		}
-		events = append(events, event)
 	}
 
 	sort.Slice(events, func(i, j int) bool {
 		return events[i].Timestamp < events[j].Timestamp
 	})
 
-	matchResult := processEvents(events)
-	doc, err := s.firestoreClient.Collection("Matches").Doc(matchID).Get(c)
+	scoreboard, err := s.store.GetScoreboard(c, matchID)
 	if err != nil {
 		log.Printf("Failed to get tournament from Firestore: %v\n", err)
 		return err
 	}
 
-	data := doc.Data()
-	fieldValue, ok := data["matchId"]
-	if !ok {
+	matchNumber := scoreboard.MatchNumber
+	if matchNumber == "" {
 		log.Printf("Field 'matchId' does not exist in the document.")
-	}
-
-	matchNumber, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("Failed to convert field value 'matchId' to string.")
 		return nil
 	}
-	fieldValue, ok = data["tournamentId"]
-	if !ok {
-		log.Printf("Field 'tournamentId' does not exist in the document.")
-	}
 
-	slug, ok := fieldValue.(string)
-	if !ok {
-		log.Printf("Failed to convert field value 'tournamentId' to string.")
+	slug := scoreboard.TournamentSlug
+	if slug == "" {
+		log.Printf("Field 'tournamentId' does not exist in the document.")
 		return nil
 	}
 
-	doc, err = s.firestoreClient.Collection("TournamentSecrets").Doc(slug).Get(c)
+	secrets, err := s.store.GetTournamentSecrets(c, slug)
 	if err != nil {
 		log.Printf("Failed to get tournament to Firestore: %v\n", err)
 		return err
 	}
 
-	data = doc.Data()
-	fieldValue, ok = data["ID"]
-	if !ok {
-		log.Printf("Field 'ID' does not exist in the document. %v", fieldValue)
-	}
-
-	tournamentSecretID, ok := fieldValue.(int64)
-	if !ok {
-		log.Printf("Failed to convert field value 'ID' to int from slug %s.  %v", fieldValue, slug)
+	if secrets.ID == 0 {
+		log.Printf("Field 'ID' does not exist in the tournament secrets for slug %s.", slug)
 		return nil
 	}
 
-	doc, err = s.firestoreClient.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(matchNumber).Get(c)
+	match, err := s.store.GetMatch(c, slug, matchNumber)
 	if err != nil {
 		log.Printf("Failed to get tournament match from Firestore: %v\n", err)
 		return err
 	}
 
-	data = doc.Data()
-	fieldValue, ok = data["ID"]
-	if !ok {
-		log.Printf("Field 'ID' does not exist in the document. %v", fieldValue)
+	if match.ID == nil {
+		log.Printf("Field 'ID' does not exist in match %s for slug %s.", matchNumber, slug)
+		return nil
 	}
+	tournamentSecretIDString := fmt.Sprint(secrets.ID)
+	matchSecretIDString := fmt.Sprint(*match.ID)
 
-	matchSecretID, ok := fieldValue.(int64)
-	if !ok {
-		log.Printf("Failed to convert field value 'ID' to int from slug %s.  %v", fieldValue, slug)
-		return nil
+	rules, err := s.scoringRules(c, slug, match.Match)
+	if err != nil {
+		return err
 	}
-	tournamentSecretIDString := fmt.Sprint(tournamentSecretID)
-	matchSecretIDString := fmt.Sprint(matchSecretID)
 
-	if !validateMatchResult(matchResult) {
-		_, err = s.firestoreClient.Collection("Matches").Doc(matchID).Update(c,
-			[]firestore.Update{
-				{Path: "AuthorMissmatches", Value: authorMissmatches},
-				{Path: "Invalid", Value: true},
-			},
-		)
+	matchResult, expiredSets := processEvents(events, rules)
+	if !validateMatchResult(matchResult, expiredSets, rules) {
+		err = s.store.UpdateScoreboard(c, matchID, storage.ScoreboardUpdate{
+			AuthorMissmatches: pointer.Int(authorMissmatches),
+			Invalid:           pointer.Bool(true),
+		})
 		if err != nil {
 			log.Printf("Failed to update match in Firestore: %v\n", err)
 			return err
 		}
 
-		_, err = s.firestoreClient.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(matchNumber).Update(c,
-			[]firestore.Update{
-				{Path: "MatchResultValid", Value: false},
-			},
-		)
+		err = s.store.SetMatchResultValid(c, slug, matchNumber, false)
 		if err != nil {
 			log.Printf("Failed to update match in Firestore: %v\n", err)
 			return err
@@ -193,21 +153,16 @@ func (s *MatchesService) ReportResult(c *gin.Context, matchID string) error {
 		return err
 	}
 
-	_, err = s.firestoreClient.Collection("Matches").Doc(matchID).Update(c,
-		[]firestore.Update{
-			{Path: "AutoReport", Value: true},
-			{Path: "AuthorMissmatches", Value: authorMissmatches},
-		},
-	)
+	err = s.store.UpdateScoreboard(c, matchID, storage.ScoreboardUpdate{
+		AutoReport:        pointer.Bool(true),
+		AuthorMissmatches: pointer.Int(authorMissmatches),
+		ReportedAt:        pointer.Time(time.Now()),
+	})
 	if err != nil {
 		log.Printf("Failed to update match in Firestore: %v\n", err)
 		return err
 	}
-	_, err = s.firestoreClient.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(matchNumber).Update(c,
-		[]firestore.Update{
-			{Path: "MatchResultValid", Value: true},
-		},
-	)
+	err = s.store.SetMatchResultValid(c, slug, matchNumber, true)
 	if err != nil {
 		log.Printf("Failed to update match in Firestore: %v\n", err)
 		return err
@@ -215,39 +170,49 @@ func (s *MatchesService) ReportResult(c *gin.Context, matchID string) error {
 	return nil
 }
 
+// FinalizeResult marks the match as finalized. The route's Guard checks that
+// the user may report results of the match's court.
 func (s *MatchesService) FinalizeResult(c *gin.Context, matchID string) error {
-	token := c.MustGet("token").(*auth.Token)
+	principal := authz.MustPrincipal(c)
 
 	events, err := s.getMatchEvents(c, matchID)
 	if err != nil {
 		return err
 	}
 
-	if err := validateFinalizeCandidate(events, time.Now()); err != nil {
+	matchNumber, tournamentSlug, err := s.getMatchNumberAndTournamentSlug(c, matchID)
+	if err != nil {
 		return err
 	}
 
-	matchNumber, tournamentSlug, err := s.getMatchNumberAndTournamentSlug(c, matchID)
+	match, err := s.store.GetMatch(c, tournamentSlug, matchNumber)
+	if err != nil {
+		log.Printf("Failed to get tournament match from Firestore: %v\n", err)
+		return err
+	}
+	rules, err := s.scoringRules(c, tournamentSlug, match.Match)
 	if err != nil {
 		return err
 	}
 
+	if err := validateFinalizeCandidate(events, rules, time.Now()); err != nil {
+		return err
+	}
+
 	finalizeEvent := Event{
-		Author:    token.UID,
+		Author:    principal.UserID,
 		EventType: "MATCH_FINALIZED",
 		ID:        uuidv7.New().String(),
 		Timestamp: time.Now().UnixMilli(),
 	}
 
-	_, err = s.firestoreClient.Collection("Matches").Doc(matchID).Collection("events").Doc(finalizeEvent.ID).Set(c, finalizeEvent)
+	err = s.store.AppendEvent(c, matchID, finalizeEvent)
 	if err != nil {
 		log.Printf("Failed to write finalize event in Firestore: %v\n", err)
 		return err
 	}
 
-	_, err = s.firestoreClient.Collection("Tournaments").Doc(tournamentSlug).Collection("Matches").Doc(matchNumber).Update(c,
-		buildTournamentFinalizeUpdates(),
-	)
+	err = s.store.SetMatchFinalized(c, tournamentSlug, matchNumber)
 	if err != nil {
 		log.Printf("Failed to update tournament match finalized state in Firestore: %v\n", err)
 		return err
@@ -256,75 +221,105 @@ func (s *MatchesService) FinalizeResult(c *gin.Context, matchID string) error {
 	return nil
 }
 
-func (s *MatchesService) getMatchNumberAndTournamentSlug(c *gin.Context, matchID string) (string, string, error) {
-	doc, err := s.firestoreClient.Collection("Matches").Doc(matchID).Get(c)
+// Timeline rebuilds the point-by-point history of a match from its events.
+func (s *MatchesService) Timeline(c *gin.Context, matchID string) (*Timeline, error) {
+	matchNumber, tournamentSlug, err := s.getMatchNumberAndTournamentSlug(c, matchID)
 	if err != nil {
-		log.Printf("Failed to get match from Firestore: %v\n", err)
-		return "", "", err
+		return nil, err
 	}
 
-	data := doc.Data()
+	match, err := s.store.GetMatch(c, tournamentSlug, matchNumber)
+	if err != nil {
+		log.Printf("Failed to get tournament match from Firestore: %v\n", err)
+		return nil, err
+	}
+	rules, err := s.scoringRules(c, tournamentSlug, match.Match)
+	if err != nil {
+		return nil, err
+	}
 
-	matchIDField, ok := data["matchId"]
-	if !ok {
-		return "", "", errors.New("field 'matchId' does not exist in match document")
+	events, err := s.getMatchEvents(c, matchID)
+	if err != nil {
+		return nil, err
+	}
+	return buildTimeline(matchID, events, rules), nil
+}
+
+// Scope resolves the tournament and court of the match in the match_id
+// route parameter, for checking the caller's role.
+func (s *MatchesService) Scope(c *gin.Context) (authz.Scope, error) {
+	matchNumber, slug, err := s.getMatchNumberAndTournamentSlug(c, c.Param("match_id"))
+	if err != nil {
+		return authz.Scope{}, err
 	}
 
-	matchNumber, ok := matchIDField.(string)
-	if !ok {
-		return "", "", errors.New("field 'matchId' is not a string")
+	scope := authz.Scope{Slug: slug}
+	match, err := s.store.GetMatch(c, slug, matchNumber)
+	if errors.Is(err, storage.ErrNotFound) {
+		return scope, nil
+	}
+	if err != nil {
+		return authz.Scope{}, err
+	}
+	if match.Field != nil && match.Field.Name != nil {
+		scope.Field = *match.Field.Name
 	}
+	return scope, nil
+}
 
-	tournamentIDField, ok := data["tournamentId"]
-	if !ok {
-		return "", "", errors.New("field 'tournamentId' does not exist in match document")
+func (s *MatchesService) getMatchNumberAndTournamentSlug(c *gin.Context, matchID string) (string, string, error) {
+	scoreboard, err := s.store.GetScoreboard(c, matchID)
+	if err != nil {
+		log.Printf("Failed to get match from Firestore: %v\n", err)
+		return "", "", err
 	}
 
-	tournamentSlug, ok := tournamentIDField.(string)
-	if !ok {
-		return "", "", errors.New("field 'tournamentId' is not a string")
+	if scoreboard.MatchNumber == "" {
+		return "", "", errors.New("field 'matchId' does not exist in match document")
 	}
 
-	return matchNumber, tournamentSlug, nil
-}
+	if scoreboard.TournamentSlug == "" {
+		return "", "", errors.New("field 'tournamentId' does not exist in match document")
+	}
 
-func buildTournamentFinalizeUpdates() []firestore.Update {
-	return []firestore.Update{{Path: "IsFinalized", Value: true}}
+	return scoreboard.MatchNumber, scoreboard.TournamentSlug, nil
 }
 
-func (s *MatchesService) getMatchEvents(c *gin.Context, matchID string) ([]Event, error) {
-	iter := s.firestoreClient.Collection("Matches").Doc(matchID).Collection("events").Documents(c)
-	defer iter.Stop()
-
-	events := []Event{}
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			if err == iterator.Done {
-				break
-			}
-			log.Printf("Failed to get document: %v\n", err)
-			return nil, err
-		}
+// scoringRules returns the rules of the match category, falling back to the
+// rules of the tournament.
+func (s *MatchesService) scoringRules(c *gin.Context, slug string, match profixio.Match) (scoring.Rules, error) {
+	tournament, err := s.store.GetTournament(c, slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		return scoring.Beach(), nil
+	}
+	if err != nil {
+		log.Printf("Failed to get tournament from Firestore: %v\n", err)
+		return scoring.Rules{}, err
+	}
 
-		var event Event
-		if err := doc.DataTo(&event); err != nil {
-			log.Printf("Failed to decode document: %v\n", err)
-			return nil, err
-		}
+	category := ""
+	if match.MatchCategory != nil && match.MatchCategory.Name != nil {
+		category = *match.MatchCategory.Name
+	}
+	return tournament.ScoringRules(category), nil
+}
 
-		events = append(events, event)
+func (s *MatchesService) getMatchEvents(c *gin.Context, matchID string) ([]Event, error) {
+	events, err := s.store.ListEvents(c, matchID)
+	if err != nil {
+		log.Printf("Failed to get document: %v\n", err)
+		return nil, err
 	}
 
 	return events, nil
 }
 
-func validateFinalizeCandidate(events []Event, now time.Time) error {
+func validateFinalizeCandidate(events []Event, rules scoring.Rules, now time.Time) error {
 	if len(events) == 0 {
 		return ErrNoEventsToFinalize
 	}
 
-	activeEvents := activeEvents(events)
+	activeEvents := replay.ActiveEvents(events)
 	if hasActiveMatchFinalizedEvent(activeEvents) {
 		return ErrMatchAlreadyFinalized
 	}
@@ -335,23 +330,14 @@ func validateFinalizeCandidate(events []Event, now time.Time) error {
 		return &FinalizeTooSoonError{RetryAt: retryAt}
 	}
 
-	matchResult := processEvents(activeEvents)
-	if !validateMatchResult(matchResult) {
+	matchResult, expiredSets := processEvents(activeEvents, rules)
+	if !validateMatchResult(matchResult, expiredSets, rules) {
 		return ErrInvalidMatchResult
 	}
 
 	return nil
 }
 
-func sortedEvents(events []Event) []Event {
-	copyEvents := make([]Event, len(events))
-	copy(copyEvents, events)
-	sort.Slice(copyEvents, func(i, j int) bool {
-		return copyEvents[i].Timestamp < copyEvents[j].Timestamp
-	})
-	return copyEvents
-}
-
 func latestEventTime(events []Event) time.Time {
 	latest := events[0].Timestamp
 	for _, event := range events[1:] {
@@ -360,11 +346,7 @@ func latestEventTime(events []Event) time.Time {
 		}
 	}
 
-	if latest > 1_000_000_000_000 {
-		return time.UnixMilli(latest)
-	}
-
-	return time.Unix(latest, 0)
+	return replay.EventTime(latest)
 }
 
 func hasActiveMatchFinalizedEvent(events []Event) bool {
@@ -377,64 +359,29 @@ func hasActiveMatchFinalizedEvent(events []Event) bool {
 	return false
 }
 
-func activeEvents(events []Event) []Event {
-	sorted := sortedEvents(events)
-	eventsByID := make(map[string]Event, len(sorted))
-	undoesByReference := make(map[string][]string)
-	activeByID := make(map[string]bool, len(sorted))
-	visiting := make(map[string]bool, len(sorted))
-
-	for _, event := range sorted {
-		eventsByID[event.ID] = event
-		if event.EventType == "UNDO" && event.Reference != "" {
-			undoesByReference[event.Reference] = append(undoesByReference[event.Reference], event.ID)
-		}
-	}
+// expiredSets holds the 0-based indexes of sets that ended because their time
+// ran out rather than on points.
+type expiredSets map[int]bool
 
-	var isActive func(eventID string) bool
-	isActive = func(eventID string) bool {
-		if active, ok := activeByID[eventID]; ok {
-			return active
-		}
-		if visiting[eventID] {
-			return true
-		}
-
-		visiting[eventID] = true
-		active := true
-		for _, undoID := range undoesByReference[eventID] {
-			if _, ok := eventsByID[undoID]; !ok {
-				continue
-			}
-			if isActive(undoID) {
-				active = false
-				break
-			}
-		}
-		delete(visiting, eventID)
-		activeByID[eventID] = active
-		return active
-	}
-
-	active := make([]Event, 0, len(sorted))
-	for _, event := range sorted {
-		if isActive(event.ID) {
-			active = append(active, event)
-		}
-	}
-
-	return active
-}
-
-func processEvents(events []Event) profixio.MatchResult {
+// processEvents builds the match result from the active events. A FORFEIT,
+// NO_SHOW or RETIREMENT event by a team ends the match and awards it to the
+// opponent according to the rules.
+func processEvents(events []Event, rules scoring.Rules) (profixio.MatchResult, expiredSets) {
 	var sets []profixio.Result
+	expired := expiredSets{}
 	currentSet := profixio.Result{}
 	homeSetsWon := 0
 	awaySetsWon := 0
 
-	for _, event := range activeEvents(events) {
+	for _, event := range replay.ActiveEvents(events) {
 
 		switch event.EventType {
+		case "FORFEIT", "NO_SHOW", "RETIREMENT":
+			if event.Team != "HOME" && event.Team != "AWAY" {
+				continue
+			}
+			return awardedResult(sets, currentSet, event, rules), expired
+
 		case "SCORE":
 			if event.Team == "HOME" {
 				currentSet.Home++
@@ -442,11 +389,15 @@ func processEvents(events []Event) profixio.MatchResult {
 				currentSet.Away++
 			}
 
-		case "SET_FINALIZED", "MATCH_FINALIZED":
+		case "SET_FINALIZED", "SET_TIME_EXPIRED", "MATCH_FINALIZED":
 			if currentSet.Home == 0 && currentSet.Away == 0 {
 				continue
 			}
 
+			if event.EventType == "SET_TIME_EXPIRED" {
+				expired[len(sets)] = true
+			}
+
 			if currentSet.Home > currentSet.Away {
 				homeSetsWon++
 			} else {
@@ -473,42 +424,107 @@ func processEvents(events []Event) profixio.MatchResult {
 			Home: homeSetsWon,
 			Away: awaySetsWon,
 		},
+	}, expired
+}
+
+// awardedResult completes a match given up by event.Team. The opponent is
+// awarded every set it needs at the full target score. After a forfeit or
+// no-show the points played are dropped and the result is flagged as a
+// walkover. After a retirement the sets played are kept and the current set
+// is completed for the opponent first.
+func awardedResult(sets []profixio.Result, currentSet profixio.Result, event Event, rules scoring.Rules) profixio.MatchResult {
+	walkover := event.EventType != "RETIREMENT"
+	if walkover {
+		sets = nil
+	} else {
+		sets = append([]profixio.Result(nil), sets...)
+		if currentSet.Home > 0 || currentSet.Away > 0 {
+			sets = append(sets, completeSet(currentSet, len(sets), event.Team, rules))
+		}
 	}
+
+	for {
+		homeSetsWon, awaySetsWon := setsWon(sets)
+		if homeSetsWon >= rules.SetsToWin() || awaySetsWon >= rules.SetsToWin() || len(sets) >= rules.BestOf {
+			break
+		}
+		sets = append(sets, completeSet(profixio.Result{}, len(sets), event.Team, rules))
+	}
+
+	homeSetsWon, awaySetsWon := setsWon(sets)
+	return profixio.MatchResult{
+		Sets: sets,
+		Result: profixio.Result{
+			Home: homeSetsWon,
+			Away: awaySetsWon,
+		},
+		Walkover: walkover,
+	}
+}
+
+// completeSet gives the opponent of the team giving up the points it needs to
+// win the set with the given 0-based index.
+func completeSet(set profixio.Result, setIndex int, givenUpBy string, rules scoring.Rules) profixio.Result {
+	points := func(winner, loser int) int {
+		needed := max(winner, rules.PointsToWin(setIndex), loser+rules.MinLead)
+		if rules.PointCap > 0 {
+			needed = min(needed, rules.PointCap)
+		}
+		return needed
+	}
+	if givenUpBy == "HOME" {
+		set.Away = points(set.Away, set.Home)
+	} else {
+		set.Home = points(set.Home, set.Away)
+	}
+	return set
+}
+
+func setsWon(sets []profixio.Result) (int, int) {
+	home, away := 0, 0
+	for _, set := range sets {
+		if set.Home > set.Away {
+			home++
+		} else {
+			away++
+		}
+	}
+	return home, away
 }
 
-// Validates the match results according to beach volleyball rules.
-func validateMatchResult(match profixio.MatchResult) bool {
-	if len(match.Sets) > 3 || len(match.Sets) < 2 {
+// Validates the match results according to the scoring rules. Sets that
+// ended on time only need a leader, if the rules allow time limits.
+func validateMatchResult(match profixio.MatchResult, expired expiredSets, rules scoring.Rules) bool {
+	if len(match.Sets) > rules.BestOf || len(match.Sets) < rules.SetsToWin() {
 		return false // Invalid number of sets
 	}
 
 	for i, set := range match.Sets {
-		if !isValidSetScore(set, i == 2) {
+		if !isValidSetScore(set, i, expired[i], rules) {
 			return false // Invalid score in one of the sets
 		}
 	}
 
 	// Check the overall match result consistency
-	return isValidMatchResult(match)
+	return isValidMatchResult(match, rules)
 }
 
-func isValidSetScore(set profixio.Result, isDecidingSet bool) bool {
-	homeAdv := set.Home >= set.Away+2
-	awayAdv := set.Away >= set.Home+2
-	if isDecidingSet {
-		// Deciding set must end at 15 or more, with a 2-point lead
-		return (set.Home >= 15 && homeAdv) || (set.Away >= 15 && awayAdv)
+func isValidSetScore(set profixio.Result, setIndex int, timeExpired bool, rules scoring.Rules) bool {
+	if timeExpired {
+		return rules.IsSetWonOnTime(set.Home, set.Away)
 	}
-	// Regular sets must end at 21 or more, with a 2-point lead
-	return (set.Home >= 21 && homeAdv) || (set.Away >= 21 && awayAdv)
+	// Sets must reach the points to win with the minimum lead, or the point
+	// cap. The deciding set may be played to fewer points.
+	return rules.IsSetWon(setIndex, set.Home, set.Away)
 }
 
-func isValidMatchResult(match profixio.MatchResult) bool {
+func isValidMatchResult(match profixio.MatchResult, rules scoring.Rules) bool {
+	setsToWin := rules.SetsToWin()
 	homeWins := 0
 	awayWins := 0
-	for i, set := range match.Sets {
-		if i == 2 && (homeWins == 2 || awayWins == 2) {
-			return false // Should not be a third set here. Match is done.
+	for _, set := range match.Sets {
+		if homeWins == setsToWin || awayWins == setsToWin {
+			return false // Should not be another set here. Match is done.
 		}
 		if set.Home > set.Away {
 			homeWins++
@@ -517,7 +533,7 @@ func isValidMatchResult(match profixio.MatchResult) bool {
 		}
 	}
 
-	if homeWins != 2 && awayWins != 2 {
+	if homeWins != setsToWin && awayWins != setsToWin {
 		return false // Match is not done
 	}
 
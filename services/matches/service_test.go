@@ -1,12 +1,22 @@
 package matches
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/xorcare/pointer"
+
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 func buildScoreEvents(startTS int64, count int, team string, prefix string) []Event {
@@ -71,7 +81,7 @@ func TestValidateMatchResultCorrect(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		if !validateMatchResult(c) {
+		if !validateMatchResult(c, nil, scoring.Beach()) {
 			t.Errorf("Expected match result to be valid, got invalid for %+v", c)
 		}
 	}
@@ -123,12 +133,237 @@ func TestValidateMatchResultIncorrect(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		if validateMatchResult(c) {
+		if validateMatchResult(c, nil, scoring.Beach()) {
 			t.Errorf("Expected match result to be invalid, got valid for %+v", c)
 		}
 	}
 }
 
+func TestValidateMatchResultRules(t *testing.T) {
+	indoor, _ := scoring.Preset(scoring.PresetIndoor)
+	youth, _ := scoring.Preset(scoring.PresetYouth)
+	singleSet, _ := scoring.Preset(scoring.PresetSingleSet)
+
+	cases := []struct {
+		name     string
+		rules    scoring.Rules
+		match    profixio.MatchResult
+		expired  expiredSets
+		expected bool
+	}{
+		{
+			name:  "indoor five sets",
+			rules: indoor,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 25, Away: 20}, {Home: 23, Away: 25}, {Home: 25, Away: 18}, {Home: 26, Away: 28}, {Home: 15, Away: 12}},
+				Result: profixio.Result{Home: 3, Away: 2},
+			},
+			expected: true,
+		},
+		{
+			name:  "indoor set to 21",
+			rules: indoor,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 21, Away: 19}, {Home: 25, Away: 20}, {Home: 25, Away: 18}},
+				Result: profixio.Result{Home: 3, Away: 0},
+			},
+		},
+		{
+			name:  "indoor match not done after two sets",
+			rules: indoor,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 25, Away: 20}, {Home: 25, Away: 18}},
+				Result: profixio.Result{Home: 2, Away: 0},
+			},
+		},
+		{
+			name:  "youth sets to 15",
+			rules: youth,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 15, Away: 13}, {Home: 11, Away: 15}, {Home: 17, Away: 15}},
+				Result: profixio.Result{Home: 2, Away: 1},
+			},
+			expected: true,
+		},
+		{
+			name:  "single set",
+			rules: singleSet,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 19, Away: 21}},
+				Result: profixio.Result{Home: 0, Away: 1},
+			},
+			expected: true,
+		},
+		{
+			name:  "single set played to 15",
+			rules: singleSet,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 15, Away: 13}},
+				Result: profixio.Result{Home: 1, Away: 0},
+			},
+		},
+		{
+			name:  "capped set",
+			rules: scoring.Rules{Name: scoring.PresetYouth, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, PointCap: 25},
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 25, Away: 24}, {Home: 21, Away: 15}},
+				Result: profixio.Result{Home: 2, Away: 0},
+			},
+			expected: true,
+		},
+		{
+			name:  "capped set played beyond the cap",
+			rules: scoring.Rules{Name: scoring.PresetYouth, BestOf: 3, SetPoints: 21, DecidingSetPoints: 15, MinLead: 2, PointCap: 25},
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 27, Away: 25}, {Home: 21, Away: 15}},
+				Result: profixio.Result{Home: 2, Away: 0},
+			},
+		},
+		{
+			name:  "uncapped set at 25-24",
+			rules: scoring.Beach(),
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 25, Away: 24}, {Home: 21, Away: 15}},
+				Result: profixio.Result{Home: 2, Away: 0},
+			},
+		},
+		{
+			name:  "set ended on time",
+			rules: scoring.Rules{Name: scoring.PresetSingleSet, BestOf: 1, SetPoints: 21, DecidingSetPoints: 21, MinLead: 2, SetTimeLimitMinutes: 20},
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 17, Away: 16}},
+				Result: profixio.Result{Home: 1, Away: 0},
+			},
+			expired:  expiredSets{0: true},
+			expected: true,
+		},
+		{
+			name:  "set ended on time without a time limit",
+			rules: singleSet,
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 17, Away: 16}},
+				Result: profixio.Result{Home: 1, Away: 0},
+			},
+			expired: expiredSets{0: true},
+		},
+		{
+			name:  "set ended on time while tied",
+			rules: scoring.Rules{Name: scoring.PresetSingleSet, BestOf: 1, SetPoints: 21, DecidingSetPoints: 21, MinLead: 2, SetTimeLimitMinutes: 20},
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 17, Away: 17}},
+				Result: profixio.Result{Home: 0, Away: 1},
+			},
+			expired: expiredSets{0: true},
+		},
+		{
+			name:  "custom best of three to 11",
+			rules: scoring.Rules{Name: scoring.PresetCustom, BestOf: 3, SetPoints: 11, DecidingSetPoints: 7, MinLead: 1},
+			match: profixio.MatchResult{
+				Sets:   []profixio.Result{{Home: 11, Away: 10}, {Home: 5, Away: 11}, {Home: 7, Away: 6}},
+				Result: profixio.Result{Home: 2, Away: 1},
+			},
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := validateMatchResult(c.match, c.expired, c.rules); got != c.expected {
+				t.Fatalf("expected valid=%v, got %v for %+v", c.expected, got, c.match)
+			}
+		})
+	}
+}
+
+func TestProcessEventsSetTimeExpired(t *testing.T) {
+	events := buildScoreEvents(1_700_000_000_000, 21, "HOME", "set1")
+	events = append(events, Event{ID: "set1-final", EventType: "SET_FINALIZED", Timestamp: 1_700_000_000_100})
+	events = append(events, buildScoreEvents(1_700_000_000_200, 12, "AWAY", "set2")...)
+	events = append(events, buildScoreEvents(1_700_000_000_300, 9, "HOME", "set2-home")...)
+	events = append(events, Event{ID: "set2-time", EventType: "SET_TIME_EXPIRED", Timestamp: 1_700_000_000_400})
+	events = append(events, buildScoreEvents(1_700_000_000_500, 15, "HOME", "set3")...)
+	events = append(events, Event{ID: "set3-final", EventType: "SET_FINALIZED", Timestamp: 1_700_000_000_600})
+
+	result, expired := processEvents(events, scoring.Beach())
+	expected := []profixio.Result{{Home: 21, Away: 0}, {Home: 9, Away: 12}, {Home: 15, Away: 0}}
+	if fmt.Sprint(result.Sets) != fmt.Sprint(expected) || result.Result != (profixio.Result{Home: 2, Away: 1}) {
+		t.Fatalf("expected sets %v with 2-1, got %+v", expected, result)
+	}
+	if len(expired) != 1 || !expired[1] {
+		t.Fatalf("expected the second set to have ended on time, got %v", expired)
+	}
+
+	timed := scoring.Beach()
+	timed.SetTimeLimitMinutes = 15
+	if !validateMatchResult(result, expired, timed) {
+		t.Fatalf("expected the result to be valid with a time limit")
+	}
+	if validateMatchResult(result, expired, scoring.Beach()) {
+		t.Fatalf("expected the result to be invalid without a time limit")
+	}
+}
+
+func TestProcessEventsAwardedResults(t *testing.T) {
+	startTS := int64(1_700_000_000_000)
+	firstSetPlayed := append(buildScoreEvents(startTS, 21, "HOME", "set1"),
+		Event{ID: "set1-final", EventType: "SET_FINALIZED", Timestamp: startTS + 100},
+	)
+	firstSetPlayed = append(firstSetPlayed, buildScoreEvents(startTS+200, 8, "AWAY", "set2")...)
+	firstSetPlayed = append(firstSetPlayed, buildScoreEvents(startTS+300, 20, "HOME", "set2-home")...)
+	indoor, _ := scoring.Preset(scoring.PresetIndoor)
+
+	cases := []struct {
+		name     string
+		events   []Event
+		rules    scoring.Rules
+		expected profixio.MatchResult
+		valid    bool
+	}{
+		{
+			name:     "no show",
+			events:   []Event{{ID: "no-show", EventType: "NO_SHOW", Team: "AWAY", Timestamp: startTS}},
+			rules:    scoring.Beach(),
+			expected: profixio.MatchResult{Sets: []profixio.Result{{Home: 21, Away: 0}, {Home: 21, Away: 0}}, Result: profixio.Result{Home: 2, Away: 0}, Walkover: true},
+			valid:    true,
+		},
+		{
+			name:     "indoor forfeit discards played points",
+			events:   append(append([]Event{}, firstSetPlayed...), Event{ID: "forfeit", EventType: "FORFEIT", Team: "HOME", Timestamp: startTS + 400}),
+			rules:    indoor,
+			expected: profixio.MatchResult{Sets: []profixio.Result{{Home: 0, Away: 25}, {Home: 0, Away: 25}, {Home: 0, Away: 25}}, Result: profixio.Result{Home: 0, Away: 3}, Walkover: true},
+			valid:    true,
+		},
+		{
+			name:     "retirement keeps played points",
+			events:   append(append([]Event{}, firstSetPlayed...), Event{ID: "retire", EventType: "RETIREMENT", Team: "HOME", Timestamp: startTS + 400}),
+			rules:    scoring.Beach(),
+			expected: profixio.MatchResult{Sets: []profixio.Result{{Home: 21, Away: 0}, {Home: 20, Away: 22}, {Home: 0, Away: 15}}, Result: profixio.Result{Home: 1, Away: 2}},
+			valid:    true,
+		},
+		{
+			name: "undone retirement",
+			events: append(append([]Event{}, firstSetPlayed...),
+				Event{ID: "retire", EventType: "RETIREMENT", Team: "HOME", Timestamp: startTS + 400},
+				Event{ID: "undo-retire", EventType: "UNDO", Reference: "retire", Timestamp: startTS + 401},
+			),
+			rules:    scoring.Beach(),
+			expected: profixio.MatchResult{Sets: []profixio.Result{{Home: 21, Away: 0}, {Home: 20, Away: 8}}, Result: profixio.Result{Home: 2, Away: 0}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, expired := processEvents(c.events, c.rules)
+			if fmt.Sprintf("%+v", result) != fmt.Sprintf("%+v", c.expected) {
+				t.Fatalf("expected %+v, got %+v", c.expected, result)
+			}
+			if valid := validateMatchResult(result, expired, c.rules); valid != c.valid {
+				t.Fatalf("expected valid=%v, got %v for %+v", c.valid, valid, result)
+			}
+		})
+	}
+}
+
 func TestValidateFinalizeCandidate(t *testing.T) {
 	startTS := int64(1_700_000_000_000)
 	validEvents := buildValidTwoSetMatchEvents(startTS)
@@ -312,7 +547,7 @@ func TestValidateFinalizeCandidate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		err := validateFinalizeCandidate(c.events, c.now)
+		err := validateFinalizeCandidate(c.events, scoring.Beach(), c.now)
 		if !errors.Is(err, c.expected) {
 			t.Errorf("%s: expected error %v, got %v", c.name, c.expected, err)
 		}
@@ -357,22 +592,217 @@ func TestLatestEventTimeSupportsSecondsAndMilliseconds(t *testing.T) {
 	}
 }
 
-func TestBuildTournamentFinalizeUpdates(t *testing.T) {
-	updates := buildTournamentFinalizeUpdates()
+func newReportContext(uid string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/match/scoreboard-1/report", nil)
+	authz.SetPrincipal(c, &authz.Principal{UserID: uid})
+	return c
+}
 
-	if len(updates) != 1 {
-		t.Fatalf("expected 1 update, got %d", len(updates))
+func seedReportMatch(store *storage.Memory, events []Event) {
+	store.SeedScoreboard("scoreboard-1", storage.Scoreboard{MatchNumber: "12", TournamentSlug: "beach-cup"})
+	store.SeedTournamentSecrets(storage.TournamentSecrets{ID: 2405, Slug: "beach-cup"})
+	_ = store.PutMember(context.Background(), "beach-cup", storage.Member{UserID: "user-1", Role: storage.RoleScorekeeper})
+	store.SeedMatch("beach-cup", storage.Match{
+		Match:        profixio.Match{ID: pointer.Int64(77), Number: pointer.String("12"), Field: &profixio.Field{Name: pointer.String("Bane 1")}},
+		ScoreboardId: "scoreboard-1",
+	})
+	for _, event := range events {
+		store.AppendEvent(context.Background(), "scoreboard-1", storage.Event(event))
 	}
+}
 
-	if updates[0].Path != "IsFinalized" {
-		t.Fatalf("expected update path IsFinalized, got %s", updates[0].Path)
+func TestReportResult(t *testing.T) {
+	cases := []struct {
+		name          string
+		events        []Event
+		expectPosted  bool
+		expectValid   bool
+		expectInvalid bool
+	}{
+		{
+			name:         "valid result is posted",
+			events:       buildValidTwoSetMatchEvents(1_700_000_000_000),
+			expectPosted: true,
+			expectValid:  true,
+		},
+		{
+			name:          "invalid result is flagged",
+			events:        buildScoreEvents(1_700_000_000_000, 10, "HOME", "set1"),
+			expectInvalid: true,
+		},
 	}
 
-	value, ok := updates[0].Value.(bool)
-	if !ok {
-		t.Fatalf("expected bool value, got %T", updates[0].Value)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			seedReportMatch(store, c.events)
+			server := profixiotest.NewServer(t)
+			service := NewMatchesService(store, nil, profixio.NewService(nil, "", server.Options()...))
+
+			if err := service.ReportResult(newReportContext("user-1"), "scoreboard-1"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			results := server.Results()
+			if c.expectPosted != (len(results) == 1) {
+				t.Fatalf("expected posted=%v, got %d results", c.expectPosted, len(results))
+			}
+			if c.expectPosted && (results[0].TournamentID != "2405" || results[0].MatchID != "77") {
+				t.Fatalf("expected result for tournament 2405 match 77, got %+v", results[0])
+			}
+
+			match, err := store.GetMatch(context.Background(), "beach-cup", "12")
+			if err != nil {
+				t.Fatalf("expected match, got %v", err)
+			}
+			if match.MatchResultValid != c.expectValid {
+				t.Fatalf("expected MatchResultValid=%v, got %v", c.expectValid, match.MatchResultValid)
+			}
+
+			scoreboard, err := store.GetScoreboard(context.Background(), "scoreboard-1")
+			if err != nil {
+				t.Fatalf("expected scoreboard, got %v", err)
+			}
+			if scoreboard.Invalid != c.expectInvalid {
+				t.Fatalf("expected Invalid=%v, got %v", c.expectInvalid, scoreboard.Invalid)
+			}
+			if scoreboard.AutoReport != c.expectPosted {
+				t.Fatalf("expected AutoReport=%v, got %v", c.expectPosted, scoreboard.AutoReport)
+			}
+			if scoreboard.ReportedAt.IsZero() == c.expectPosted {
+				t.Fatalf("expected ReportedAt to be set=%v, got %v", c.expectPosted, scoreboard.ReportedAt)
+			}
+			if scoreboard.AuthorMissmatches != len(c.events) {
+				t.Fatalf("expected %d author missmatches, got %d", len(c.events), scoreboard.AuthorMissmatches)
+			}
+		})
 	}
-	if !value {
-		t.Fatalf("expected IsFinalized value to be true")
+}
+
+func TestReportResultUsesCategoryRules(t *testing.T) {
+	singleSet, _ := scoring.Preset(scoring.PresetSingleSet)
+	events := append(buildScoreEvents(1_700_000_000_000, 21, "HOME", "set1"),
+		Event{ID: "set1-final", EventType: "SET_FINALIZED", Timestamp: 1_700_000_000_100},
+	)
+
+	store := storage.NewMemory()
+	seedReportMatch(store, events)
+	store.SeedMatch("beach-cup", storage.Match{
+		Match:        profixio.Match{ID: pointer.Int64(77), Number: pointer.String("12"), MatchCategory: &profixio.Category{Name: pointer.String("Pool play")}},
+		ScoreboardId: "scoreboard-1",
+	})
+	store.SeedTournament(storage.Tournament{Slug: "beach-cup", CategoryScoring: map[string]scoring.Rules{"Pool play": singleSet}})
+	server := profixiotest.NewServer(t)
+	service := NewMatchesService(store, nil, profixio.NewService(nil, "", server.Options()...))
+
+	if err := service.ReportResult(newReportContext("user-1"), "scoreboard-1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if results := server.Results(); len(results) != 1 {
+		t.Fatalf("expected the single set result to be posted, got %d results", len(results))
+	}
+}
+
+func TestReportResultPostsWalkover(t *testing.T) {
+	store := storage.NewMemory()
+	seedReportMatch(store, []Event{{ID: "no-show", EventType: "NO_SHOW", Team: "HOME", Timestamp: 1_700_000_000_000}})
+	server := profixiotest.NewServer(t)
+	service := NewMatchesService(store, nil, profixio.NewService(nil, "", server.Options()...))
+
+	if err := service.ReportResult(newReportContext("user-1"), "scoreboard-1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	results := server.Results()
+	if len(results) != 1 || !results[0].Result.Walkover || results[0].Result.Result != (profixio.Result{Home: 0, Away: 2}) {
+		t.Fatalf("expected a 0-2 walkover to be posted, got %+v", results)
+	}
+}
+
+func TestReportResultProfixioRejects(t *testing.T) {
+	store := storage.NewMemory()
+	seedReportMatch(store, buildValidTwoSetMatchEvents(1_700_000_000_000))
+	server := profixiotest.NewServer(t)
+	server.SetResultStatus(http.StatusConflict)
+	service := NewMatchesService(store, nil, profixio.NewService(nil, "", server.Options()...))
+
+	err := service.ReportResult(newReportContext("user-1"), "scoreboard-1")
+	if !errors.Is(err, profixio.ErrAlreadyRegistered) {
+		t.Fatalf("expected %v, got %v", profixio.ErrAlreadyRegistered, err)
+	}
+
+	match, err := store.GetMatch(context.Background(), "beach-cup", "12")
+	if err != nil {
+		t.Fatalf("expected match, got %v", err)
+	}
+	if match.MatchResultValid {
+		t.Fatalf("expected MatchResultValid to stay false")
+	}
+}
+
+func TestReportResultAuthorization(t *testing.T) {
+	cases := []struct {
+		name         string
+		member       *storage.Member
+		expectPosted bool
+	}{
+		{name: "not a member"},
+		{name: "statistician", member: &storage.Member{UserID: "user-2", Role: storage.RoleStatistician}},
+		{name: "referee on another court", member: &storage.Member{UserID: "user-2", Role: storage.RoleReferee, Fields: []string{"Bane 2"}}},
+		{name: "referee on the court", member: &storage.Member{UserID: "user-2", Role: storage.RoleReferee, Fields: []string{"Bane 1"}}, expectPosted: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			seedReportMatch(store, buildValidTwoSetMatchEvents(1_700_000_000_000))
+			if c.member != nil {
+				_ = store.PutMember(context.Background(), "beach-cup", *c.member)
+			}
+			server := profixiotest.NewServer(t)
+			service := NewMatchesService(store, nil, profixio.NewService(nil, "", server.Options()...))
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(func(ctx *gin.Context) {
+				authz.SetPrincipal(ctx, &authz.Principal{UserID: "user-2"})
+			})
+			NewHTTPHandler(HTTPOptions{Service: service, Router: r, Guard: authz.NewPermissions(store).Guard(service.Scope)})
+
+			report := performRequest(r, http.MethodGet, "/result/scoreboard-1")
+			if c.expectPosted {
+				if report.Code != http.StatusAccepted {
+					t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, report.Code, report.Body)
+				}
+				if len(server.Results()) != 1 || len(store.AuditEntries()) != 0 {
+					t.Fatalf("expected the result to be posted without audit entries, got %d results and %v", len(server.Results()), store.AuditEntries())
+				}
+				return
+			}
+
+			finalize := performRequest(r, http.MethodPut, "/result/finalize/scoreboard-1")
+			for _, w := range []*httptest.ResponseRecorder{report, finalize} {
+				if w.Code != http.StatusForbidden {
+					t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body)
+				}
+			}
+			if len(server.Results()) != 0 {
+				t.Fatalf("expected nothing to be posted, got %d results", len(server.Results()))
+			}
+			scoreboard, _ := store.GetScoreboard(context.Background(), "scoreboard-1")
+			match, _ := store.GetMatch(context.Background(), "beach-cup", "12")
+			if scoreboard.AuthorMissmatches != 0 || match.IsFinalized {
+				t.Fatalf("expected the match to be untouched, got %+v %+v", scoreboard, match)
+			}
+
+			entries := store.AuditEntries()
+			if len(entries) != 2 {
+				t.Fatalf("expected one audit entry per request, got %v", entries)
+			}
+			if entries[0].UserID != "user-2" || entries[0].Slug != "beach-cup" || entries[0].Field != "Bane 1" || entries[0].Resource != "/result/scoreboard-1" || entries[0].Action != "report" {
+				t.Fatalf("unexpected audit entry %+v", entries[0])
+			}
+		})
 	}
 }
@@ -0,0 +1,214 @@
+package matches
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nvbf/tournament-sync/pkg/replay"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+)
+
+// Timeline is the point-by-point history of a match with the derived state
+// after every active event.
+type Timeline struct {
+	MatchID string               `json:"matchId"`
+	Rules   scoring.Rules        `json:"rules"`
+	Result  profixio.MatchResult `json:"result"`
+	Sets    []TimelineSet        `json:"sets"`
+	Entries []TimelineEntry      `json:"entries"`
+}
+
+// TimelineSet summarises one set of the timeline. Sets still in play have no
+// winner or end.
+type TimelineSet struct {
+	Number          int             `json:"number"`
+	Score           profixio.Result `json:"score"`
+	Winner          string          `json:"winner,omitempty"`
+	StartedAt       *time.Time      `json:"startedAt,omitempty"`
+	EndedAt         *time.Time      `json:"endedAt,omitempty"`
+	DurationSeconds int             `json:"durationSeconds,omitempty"`
+	TimeExpired     bool            `json:"timeExpired,omitempty"`
+}
+
+// TimelineEntry is one event with the match state after it.
+type TimelineEntry struct {
+	EventID   string    `json:"eventId"`
+	EventType string    `json:"eventType"`
+	Team      string    `json:"team,omitempty"`
+	PlayerID  int       `json:"playerId,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	At        time.Time `json:"at"`
+	// Set is the 1-based set the event belongs to.
+	Set     int             `json:"set"`
+	Score   profixio.Result `json:"score"`
+	SetsWon profixio.Result `json:"setsWon"`
+	// ServedBy is the team that served the rally of a SCORE event, and
+	// Serving the team serving next. Both are unknown at the start of a set.
+	ServedBy         string `json:"servedBy,omitempty"`
+	Serving          string `json:"serving,omitempty"`
+	SideSwitch       bool   `json:"sideSwitch,omitempty"`
+	TechnicalTimeout bool   `json:"technicalTimeout,omitempty"`
+	SetEnded         bool   `json:"setEnded,omitempty"`
+	MatchEnded       bool   `json:"matchEnded,omitempty"`
+}
+
+// buildTimeline replays the active events of a match against the rules.
+func buildTimeline(matchID string, events []Event, rules scoring.Rules) *Timeline {
+	result, _ := processEvents(events, rules)
+	timeline := &Timeline{
+		MatchID: matchID,
+		Rules:   rules,
+		Result:  result,
+		Sets:    []TimelineSet{},
+		Entries: []TimelineEntry{},
+	}
+
+	current := TimelineSet{Number: 1}
+	setsWon := profixio.Result{}
+	serving := ""
+	endSet := func(at time.Time, timeExpired bool) {
+		current.EndedAt = &at
+		current.TimeExpired = timeExpired
+		if current.StartedAt != nil {
+			current.DurationSeconds = int(at.Sub(*current.StartedAt).Seconds())
+		}
+		if current.Score.Home > current.Score.Away {
+			current.Winner = "HOME"
+			setsWon.Home++
+		} else {
+			current.Winner = "AWAY"
+			setsWon.Away++
+		}
+		timeline.Sets = append(timeline.Sets, current)
+		current = TimelineSet{Number: current.Number + 1}
+		serving = ""
+	}
+
+	for _, event := range replay.ActiveEvents(events) {
+		if event.EventType == "UNDO" {
+			continue
+		}
+
+		at := replay.EventTime(event.Timestamp)
+		entry := TimelineEntry{
+			EventID:   event.ID,
+			EventType: event.EventType,
+			Team:      event.Team,
+			PlayerID:  event.PlayerID,
+			Author:    event.Author,
+			At:        at,
+			Set:       current.Number,
+		}
+		if entry.Team == "NONE" {
+			entry.Team = ""
+		}
+
+		matchEnded := false
+		switch event.EventType {
+		case "SCORE":
+			if event.Team != "HOME" && event.Team != "AWAY" {
+				break
+			}
+			if current.StartedAt == nil {
+				current.StartedAt = &at
+			}
+			entry.ServedBy = serving
+			if event.Team == "HOME" {
+				current.Score.Home++
+			} else {
+				current.Score.Away++
+			}
+			serving = event.Team
+
+			pointsPlayed := current.Score.Home + current.Score.Away
+			entry.SideSwitch = rules.IsSideSwitch(current.Number-1, pointsPlayed)
+			entry.TechnicalTimeout = rules.IsTechnicalTimeout(current.Number-1, pointsPlayed)
+
+		case "SET_FINALIZED", "SET_TIME_EXPIRED", "MATCH_FINALIZED":
+			entry.MatchEnded = event.EventType == "MATCH_FINALIZED"
+			if current.Score.Home == 0 && current.Score.Away == 0 {
+				break
+			}
+			entry.Score = current.Score
+			entry.SetEnded = true
+			endSet(at, event.EventType == "SET_TIME_EXPIRED")
+
+		case "FORFEIT", "NO_SHOW", "RETIREMENT":
+			if event.Team != "HOME" && event.Team != "AWAY" {
+				break
+			}
+			matchEnded = true
+			entry.MatchEnded = true
+		}
+
+		if !entry.SetEnded {
+			entry.Score = current.Score
+		}
+		entry.SetsWon = setsWon
+		entry.Serving = serving
+		timeline.Entries = append(timeline.Entries, entry)
+		if matchEnded {
+			return timeline
+		}
+	}
+
+	if current.Score.Home > 0 || current.Score.Away > 0 {
+		timeline.Sets = append(timeline.Sets, current)
+	}
+	return timeline
+}
+
+// Text renders the timeline compactly, one line per event.
+func (t *Timeline) Text() string {
+	var text strings.Builder
+
+	scores := make([]string, 0, len(t.Result.Sets))
+	for _, set := range t.Result.Sets {
+		scores = append(scores, fmt.Sprintf("%d-%d", set.Home, set.Away))
+	}
+	fmt.Fprintf(&text, "Match %s: %d-%d (%s)", t.MatchID, t.Result.Result.Home, t.Result.Result.Away, strings.Join(scores, ", "))
+	if t.Result.Walkover {
+		text.WriteString(" walkover")
+	}
+	text.WriteString("\n")
+
+	for _, entry := range t.Entries {
+		fmt.Fprintf(&text, "%s S%d %2d-%-2d %s\n", entry.At.UTC().Format("15:04:05"), entry.Set, entry.Score.Home, entry.Score.Away, t.describe(entry))
+	}
+	return text.String()
+}
+
+func (t *Timeline) describe(entry TimelineEntry) string {
+	var notes []string
+	switch entry.EventType {
+	case "SCORE":
+		notes = append(notes, "point "+entry.Team)
+		if entry.PlayerID != 0 {
+			notes = append(notes, fmt.Sprintf("#%d", entry.PlayerID))
+		}
+		if entry.ServedBy != "" {
+			notes = append(notes, "served by "+entry.ServedBy)
+		}
+		if entry.SideSwitch {
+			notes = append(notes, "[switch sides]")
+		}
+		if entry.TechnicalTimeout {
+			notes = append(notes, "[technical timeout]")
+		}
+	case "FORFEIT", "NO_SHOW", "RETIREMENT":
+		notes = append(notes, entry.Team, strings.ToLower(strings.ReplaceAll(entry.EventType, "_", " ")))
+	default:
+		notes = append(notes, strings.ToLower(strings.ReplaceAll(entry.EventType, "_", " ")))
+	}
+
+	if entry.SetEnded {
+		set := t.Sets[entry.Set-1]
+		notes = append(notes, fmt.Sprintf("set %d to %s", set.Number, set.Winner))
+		if set.DurationSeconds > 0 {
+			notes = append(notes, "in "+(time.Duration(set.DurationSeconds)*time.Second).String())
+		}
+	}
+	return strings.Join(notes, " ")
+}
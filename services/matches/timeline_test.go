@@ -0,0 +1,125 @@
+package matches
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/xorcare/pointer"
+)
+
+// rally builds SCORE events one second apart, in seconds like older clients.
+func rally(startTS int64, teams string, prefix string) []Event {
+	events := make([]Event, 0, len(teams))
+	for i, team := range teams {
+		name := "HOME"
+		if team == 'A' {
+			name = "AWAY"
+		}
+		events = append(events, Event{ID: fmt.Sprintf("%s-%d", prefix, i), EventType: "SCORE", Team: name, Timestamp: startTS + int64(i)})
+	}
+	return events
+}
+
+func TestBuildTimeline(t *testing.T) {
+	startTS := int64(1_700_000_000)
+	events := rally(startTS, strings.Repeat("HA", 10)+"H", "set1")
+	events = append(events,
+		Event{ID: "set1-undone", EventType: "SCORE", Team: "AWAY", Timestamp: startTS + 30},
+		Event{ID: "undo", EventType: "UNDO", Reference: "set1-undone", Timestamp: startTS + 31},
+		Event{ID: "set1-final", EventType: "SET_FINALIZED", Timestamp: startTS + 1100},
+	)
+	events = append(events, rally(startTS+1200, "AAAAA", "set2")...)
+
+	timeline := buildTimeline("scoreboard-1", events, scoring.Beach())
+
+	if len(timeline.Entries) != 27 {
+		t.Fatalf("expected 27 entries without the undone point and the undo, got %d", len(timeline.Entries))
+	}
+
+	first := timeline.Entries[0]
+	if first.ServedBy != "" || first.Serving != "HOME" || first.Score != (profixio.Result{Home: 1}) {
+		t.Fatalf("expected an unknown first server and HOME serving next, got %+v", first)
+	}
+	second := timeline.Entries[1]
+	if second.ServedBy != "HOME" || second.Serving != "AWAY" || second.Score != (profixio.Result{Home: 1, Away: 1}) {
+		t.Fatalf("expected AWAY to side out, got %+v", second)
+	}
+	if !timeline.Entries[6].SideSwitch || timeline.Entries[5].SideSwitch || !timeline.Entries[13].SideSwitch {
+		t.Fatal("expected side switches after 7 and 14 points")
+	}
+	if !timeline.Entries[20].TechnicalTimeout || !timeline.Entries[20].SideSwitch {
+		t.Fatalf("expected a technical timeout and side switch at 21 points, got %+v", timeline.Entries[20])
+	}
+
+	setEnd := timeline.Entries[21]
+	if !setEnd.SetEnded || setEnd.Set != 1 || setEnd.Score != (profixio.Result{Home: 11, Away: 10}) || setEnd.SetsWon != (profixio.Result{Home: 1}) || setEnd.Serving != "" {
+		t.Fatalf("expected set 1 to end 11-10 with the serve reset, got %+v", setEnd)
+	}
+	if timeline.Sets[0].Winner != "HOME" || timeline.Sets[0].DurationSeconds != 1100 {
+		t.Fatalf("expected HOME to win set 1 in 1100 seconds, got %+v", timeline.Sets[0])
+	}
+
+	last := timeline.Entries[26]
+	if last.Set != 2 || last.Score != (profixio.Result{Away: 5}) || last.ServedBy != "AWAY" || last.SideSwitch {
+		t.Fatalf("expected set 2 at 0-5 with AWAY serving, got %+v", last)
+	}
+	if len(timeline.Sets) != 2 || timeline.Sets[1].Winner != "" || timeline.Sets[1].EndedAt != nil {
+		t.Fatalf("expected set 2 to be in play, got %+v", timeline.Sets)
+	}
+
+	text := timeline.Text()
+	for _, line := range []string{
+		"Match scoreboard-1: 1-1 (11-10, 0-5)\n",
+		"S1  4-3  point HOME served by AWAY [switch sides]\n",
+		"S1 11-10 set finalized set 1 to HOME in 18m20s\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("expected text timeline to contain %q, got\n%s", line, text)
+		}
+	}
+}
+
+func TestBuildTimelineForfeit(t *testing.T) {
+	events := append(rally(1_700_000_000, "HHA", "set1"),
+		Event{ID: "forfeit", EventType: "FORFEIT", Team: "AWAY", Timestamp: 1_700_000_010},
+		Event{ID: "after", EventType: "SCORE", Team: "HOME", Timestamp: 1_700_000_011},
+	)
+
+	timeline := buildTimeline("scoreboard-1", events, scoring.Beach())
+	last := timeline.Entries[len(timeline.Entries)-1]
+	if len(timeline.Entries) != 4 || !last.MatchEnded || last.Team != "AWAY" {
+		t.Fatalf("expected the timeline to end at the forfeit, got %+v", timeline.Entries)
+	}
+	if !timeline.Result.Walkover || !strings.HasPrefix(timeline.Text(), "Match scoreboard-1: 2-0 (21-0, 21-0) walkover\n") {
+		t.Fatalf("expected a walkover result, got %+v", timeline.Result)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	store := storage.NewMemory()
+	seedReportMatch(store, rally(1_700_000_000, "HHHHH", "set1"))
+	store.SeedMatch("beach-cup", storage.Match{
+		Match:        profixio.Match{ID: pointer.Int64(77), Number: pointer.String("12"), MatchCategory: &profixio.Category{Name: pointer.String("U15")}},
+		ScoreboardId: "scoreboard-1",
+	})
+	youth, _ := scoring.Preset(scoring.PresetYouth)
+	store.SeedTournament(storage.Tournament{Slug: "beach-cup", CategoryScoring: map[string]scoring.Rules{"U15": youth}})
+	service := NewMatchesService(store, nil, nil)
+
+	timeline, err := service.Timeline(newReportContext("user-1"), "scoreboard-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if timeline.Rules != youth || !timeline.Entries[4].SideSwitch {
+		t.Fatalf("expected youth rules with a side switch after 5 points, got %+v", timeline)
+	}
+
+	if _, err := service.Timeline(newReportContext("user-1"), "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+	}
+}
@@ -1,15 +1,9 @@
 package stats
 
-type Event struct {
-	Author    string `firestore:"author"`
-	EventType string `firestore:"eventType"`
-	ID        string `firestore:"id"`
-	PlayerID  int    `firestore:"playerId"`
-	Reference string `firestore:"reference"`
-	Team      string `firestore:"team"`
-	Timestamp int64  `firestore:"timestamp"`
-	Undone    string `firestore:"undone"`
-}
+import "github.com/nvbf/tournament-sync/repos/storage"
+
+// Event is a scoreboard event as stored in the match's events collection.
+type Event = storage.Event
 
 type Tournament struct {
 	Name      string  `firestore:"Name"`
@@ -23,12 +17,16 @@ type Tournament struct {
 type TournamentStats struct {
 	Name                string  `firestore:"Name"`
 	Slug                string  `firestore:"Slug"`
+	Source              string  `firestore:"Source"`
 	StartDate           string  `firestore:"StartDate"`
 	EndDate             string  `firestore:"EndDate"`
 	Matches             []Match `firestore:"Matches"`
 	NumberOfScoreboards int     `firestore:"NumberOfScoreboards"`
 	NumberOfMatches     int     `firestore:"NumberOfMatches"`
 	StatsWritten        bool    `firestore:"StatsWritten"`
+	// Metrics are the stats computed from the tournament's matches and
+	// events. They are written once the tournament has ended.
+	Metrics *storage.TournamentStats `firestore:"Metrics"`
 }
 
 type Match struct {
@@ -1,10 +1,14 @@
 package stats
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 // Router is the interface for a router.
@@ -16,8 +20,12 @@ type Router interface {
 
 // Greeter is the interface for a greeter service.
 type Stats interface {
-	GetStats(c *gin.Context) ([]*TournamentStats, error)
+	GetStats(c *gin.Context, query StatsQuery) (*StatsPage, error)
 	UpdateStats(c *gin.Context) error
+	GetTournamentStats(c *gin.Context, slug string) (*TournamentStats, error)
+	GetMatchPlayerStats(c *gin.Context, matchID string) (*PlayerStatsReport, error)
+	GetTournamentPlayerStats(c *gin.Context, slug string) (*PlayerStatsReport, error)
+	GetSeasonPlayerStats(c *gin.Context, season string) (*PlayerStatsReport, error)
 }
 
 // HTTPOptions contains all the options needed for the HTTP handler.
@@ -28,34 +36,84 @@ type HTTPOptions struct {
 
 	// The router instance to configure the HTTP routes.
 	Router Router
+
+	// Guard checks the caller's role in the tournament of the
+	// /tournament/:slug_id routes. The other routes are left to admins and
+	// services. Nil leaves all routes open.
+	Guard authz.Guard
+
+	// Public mounts only the aggregate stats of tournaments, for the routes
+	// served without authentication. Player stats and the route updating the
+	// stats are left out.
+	Public bool
 }
 
 // NewHTTPHandler creates a new HTTP handler.
 func NewHTTPHandler(opts HTTPOptions) {
 	r := opts.Router
 	h := &httpHandler{opts}
-	r.GET("/all", h.getStatsHandler)
-	r.GET("/update", h.updateStatsHandler)
+	r.GET("/all", h.guard(authz.ActionView), h.getStatsHandler)
+	r.GET("/tournament/:slug_id", h.guard(authz.ActionView), h.getTournamentStatsHandler)
+	if opts.Public {
+		return
+	}
+	r.GET("/update", h.guard(authz.ActionManage), h.updateStatsHandler)
+	r.GET("/match/:match_id/players", h.guard(authz.ActionView), h.matchPlayerStatsHandler)
+	r.GET("/tournament/:slug_id/players", h.guard(authz.ActionView), h.tournamentPlayerStatsHandler)
+	r.GET("/season/:season/players", h.guard(authz.ActionView), h.seasonPlayerStatsHandler)
 }
 
 type httpHandler struct {
 	HTTPOptions
 }
 
+func (s *httpHandler) guard(action authz.Action) gin.HandlerFunc {
+	if s.Guard == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return s.Guard(action)
+}
+
 func (s *httpHandler) getStatsHandler(c *gin.Context) {
-	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "getStats", "path": c.FullPath()}))
+	query := StatsQuery{
+		From:         c.Query("from"),
+		To:           c.Query("to"),
+		Season:       c.Query("season"),
+		Type:         c.Query("type"),
+		Organisation: c.Query("organisation"),
+		Source:       c.Query("source"),
+		Cursor:       c.Query("cursor"),
+	}
+	fields := log.Fields{"handler": "getStats", "path": c.FullPath(), "query": c.Request.URL.RawQuery}
+	log.Info("request start", log.WithRequest(c, fields))
 
-	stats, err := s.Service.GetStats(c)
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			log.Warning("request invalid", log.WithRequest(c, fields))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			c.Abort()
+			return
+		}
+	}
+
+	page, err := s.Service.GetStats(c, query)
+	if errors.Is(err, ErrInvalidQuery) {
+		log.Warning("request invalid", log.WithRequest(c, fields))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
 	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "getStats", "path": c.FullPath()}))
+		log.Error("request failed", err, log.WithRequest(c, fields))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
 		c.Abort()
 		return
 	}
 
-	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "getStats", "path": c.FullPath(), "count": len(stats)}))
-	c.JSON(http.StatusOK, gin.H{"stats": stats})
-	s.Service.GetStats(c)
+	fields["count"] = len(page.Tournaments)
+	log.Info("request completed", log.WithRequest(c, fields))
+	c.JSON(http.StatusOK, page)
 }
 
 func (s *httpHandler) updateStatsHandler(c *gin.Context) {
@@ -69,3 +127,78 @@ func (s *httpHandler) updateStatsHandler(c *gin.Context) {
 	}
 	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "updateStats", "path": c.FullPath()}))
 }
+
+func (s *httpHandler) getTournamentStatsHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	fields := log.Fields{"handler": "getTournamentStats", "path": c.FullPath(), "slug": slug}
+	log.Info("request start", log.WithRequest(c, fields))
+
+	stats, err := s.Service.GetTournamentStats(c, slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Warning("request invalid", log.WithRequest(c, fields))
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		log.Error("request failed", err, log.WithRequest(c, fields))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		c.Abort()
+		return
+	}
+
+	log.Info("request completed", log.WithRequest(c, fields))
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+func (s *httpHandler) matchPlayerStatsHandler(c *gin.Context) {
+	matchID := c.Param("match_id")
+	fields := log.Fields{"handler": "matchPlayerStats", "path": c.FullPath(), "matchId": matchID}
+	log.Info("request start", log.WithRequest(c, fields))
+
+	report, err := s.Service.GetMatchPlayerStats(c, matchID)
+	s.writePlayerStats(c, fields, report, err)
+}
+
+func (s *httpHandler) tournamentPlayerStatsHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	fields := log.Fields{"handler": "tournamentPlayerStats", "path": c.FullPath(), "slug": slug}
+	log.Info("request start", log.WithRequest(c, fields))
+
+	report, err := s.Service.GetTournamentPlayerStats(c, slug)
+	s.writePlayerStats(c, fields, report, err)
+}
+
+func (s *httpHandler) seasonPlayerStatsHandler(c *gin.Context) {
+	season := c.Param("season")
+	fields := log.Fields{"handler": "seasonPlayerStats", "path": c.FullPath(), "season": season}
+	log.Info("request start", log.WithRequest(c, fields))
+
+	report, err := s.Service.GetSeasonPlayerStats(c, season)
+	s.writePlayerStats(c, fields, report, err)
+}
+
+func (s *httpHandler) writePlayerStats(c *gin.Context, fields log.Fields, report *PlayerStatsReport, err error) {
+	switch {
+	case err == nil:
+		fields["matches"] = report.Matches
+		log.Info("request completed", log.WithRequest(c, fields))
+		c.JSON(http.StatusOK, gin.H{"stats": report})
+	case errors.Is(err, storage.ErrNotFound):
+		log.Warning("request invalid", log.WithRequest(c, fields))
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Abort()
+	case errors.Is(err, ErrMatchNotFinalized):
+		log.Warning("request invalid", log.WithRequest(c, fields))
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		c.Abort()
+	case errors.Is(err, ErrInvalidSeason):
+		log.Warning("request invalid", log.WithRequest(c, fields))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Abort()
+	default:
+		log.Error("request failed", err, log.WithRequest(c, fields))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testStatsService struct {
+	Stats
+	statsErr  error
+	calls     int
+	lastQuery StatsQuery
+}
+
+func (s *testStatsService) GetStats(_ *gin.Context, query StatsQuery) (*StatsPage, error) {
+	s.calls++
+	s.lastQuery = query
+	if s.statsErr != nil {
+		return nil, s.statsErr
+	}
+	return &StatsPage{Tournaments: []*TournamentStats{{Slug: "beach-cup"}}, Totals: StatsTotals{Tournaments: 1}, NextCursor: "next"}, nil
+}
+
+func TestGetStatsHandler(t *testing.T) {
+	cases := []struct {
+		name           string
+		path           string
+		statsErr       error
+		expectedStatus int
+		expectedCalls  int
+		expectedQuery  StatsQuery
+	}{
+		{
+			name:           "filters",
+			path:           "/all?season=2024&organisation=NVBF.NO.VB&type=Custom&from=2024-01-01&to=2024-12-31&source=NVBF.NO.VB/SVB&cursor=abc&limit=10",
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+			expectedQuery:  StatsQuery{From: "2024-01-01", To: "2024-12-31", Season: "2024", Type: "Custom", Organisation: "NVBF.NO.VB", Source: "NVBF.NO.VB/SVB", Cursor: "abc", Limit: 10},
+		},
+		{name: "invalid limit", path: "/all?limit=ten", expectedStatus: http.StatusBadRequest},
+		{name: "invalid query", path: "/all", statsErr: fmt.Errorf("%w: season must be a year", ErrInvalidQuery), expectedStatus: http.StatusBadRequest, expectedCalls: 1},
+		{name: "internal error", path: "/all", statsErr: errors.New("boom"), expectedStatus: http.StatusInternalServerError, expectedCalls: 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			service := &testStatsService{statsErr: c.statsErr}
+			NewHTTPHandler(HTTPOptions{Service: service, Router: r})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", c.expectedStatus, w.Code, w.Body)
+			}
+			if service.calls != c.expectedCalls {
+				t.Fatalf("expected GetStats to be called %d times, got %d", c.expectedCalls, service.calls)
+			}
+			if c.expectedStatus != http.StatusOK {
+				return
+			}
+			if service.lastQuery != c.expectedQuery {
+				t.Fatalf("expected query %+v, got %+v", c.expectedQuery, service.lastQuery)
+			}
+
+			var page StatsPage
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("failed to parse response body: %v", err)
+			}
+			if len(page.Tournaments) != 1 || page.Totals.Tournaments != 1 || page.NextCursor != "next" {
+				t.Fatalf("expected a page with totals and a cursor, got %+v", page)
+			}
+		})
+	}
+}
+
+func TestPublicStatsRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewHTTPHandler(HTTPOptions{Service: &testStatsService{}, Router: r.Group("/public/stats/v1"), Public: true})
+
+	cases := []struct {
+		path       string
+		statusCode int
+	}{
+		{path: "/public/stats/v1/all", statusCode: http.StatusOK},
+		{path: "/public/stats/v1/update", statusCode: http.StatusNotFound},
+		{path: "/public/stats/v1/tournament/beach-cup/players", statusCode: http.StatusNotFound},
+		{path: "/public/stats/v1/match/match-1/players", statusCode: http.StatusNotFound},
+		{path: "/public/stats/v1/season/2024/players", statusCode: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if w.Code != c.statusCode {
+				t.Fatalf("expected status %d, got %d", c.statusCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nvbf/tournament-sync/pkg/replay"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// matchSummary is what the events of one match tell about how it was played.
+type matchSummary struct {
+	startedAt time.Time
+	endedAt   time.Time
+	sets      int
+	deuceSets int
+}
+
+// summarizeMatch replays the active events of a match. Sets count once they
+// are ended; a set is a deuce set when both teams reached one point short of
+// winning it.
+func summarizeMatch(events []Event, rules scoring.Rules) matchSummary {
+	var summary matchSummary
+	home, away := 0, 0
+
+	for _, event := range replay.ActiveEvents(events) {
+		at := replay.EventTime(event.Timestamp)
+		switch event.EventType {
+		case "SCORE":
+			if event.Team == "HOME" {
+				home++
+			} else if event.Team == "AWAY" {
+				away++
+			} else {
+				continue
+			}
+			if summary.startedAt.IsZero() {
+				summary.startedAt = at
+			}
+
+		case "SET_FINALIZED", "SET_TIME_EXPIRED", "MATCH_FINALIZED":
+			if home == 0 && away == 0 {
+				if event.EventType == "MATCH_FINALIZED" && summary.sets > 0 {
+					summary.endedAt = at
+				}
+				continue
+			}
+			deuce := rules.PointsToWin(summary.sets) - 1
+			if deuce > 0 && home >= deuce && away >= deuce {
+				summary.deuceSets++
+			}
+			summary.sets++
+			summary.endedAt = at
+			home, away = 0, 0
+
+		case "FORFEIT", "NO_SHOW", "RETIREMENT":
+			if event.Team == "HOME" || event.Team == "AWAY" {
+				summary.endedAt = at
+				return summary
+			}
+		}
+	}
+	return summary
+}
+
+// duration is the time from the first point to the end of the match, or zero
+// if either is unknown.
+func (m matchSummary) duration() time.Duration {
+	if m.startedAt.IsZero() || m.endedAt.IsZero() || m.endedAt.Before(m.startedAt) {
+		return 0
+	}
+	return m.endedAt.Sub(m.startedAt)
+}
+
+// computeTournamentStats collects the metrics of a tournament from its
+// matches, their scoreboards and events.
+func (s *StatsService) computeTournamentStats(c *gin.Context, tournament *storage.Tournament) (*storage.TournamentStats, error) {
+	matches, err := s.store.ListMatches(c, tournament.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &storage.TournamentStats{
+		Slug:            tournament.Slug,
+		UpdatedAt:       time.Now().UTC(),
+		NumberOfMatches: len(matches),
+		Courts:          []storage.CourtStats{},
+	}
+	courts := map[string]int{}
+	var played, reported int
+	var playTime, reportDelay time.Duration
+
+	for _, match := range matches {
+		court := ""
+		if match.Field != nil && match.Field.Name != nil {
+			court = *match.Field.Name
+		}
+		if court != "" {
+			if _, ok := courts[court]; !ok {
+				courts[court] = len(stats.Courts)
+				stats.Courts = append(stats.Courts, storage.CourtStats{Court: court})
+			}
+			stats.Courts[courts[court]].Matches++
+		}
+
+		autoReported := false
+		if match.ScoreboardId != "" {
+			stats.NumberOfScoreboards++
+			if court != "" {
+				stats.Courts[courts[court]].Scoreboards++
+			}
+
+			scoreboard, err := s.store.GetScoreboard(c, match.ScoreboardId)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return nil, err
+			}
+			events, err := s.store.ListEvents(c, match.ScoreboardId)
+			if err != nil {
+				return nil, err
+			}
+
+			category := ""
+			if match.MatchCategory != nil && match.MatchCategory.Name != nil {
+				category = *match.MatchCategory.Name
+			}
+			summary := summarizeMatch(events, tournament.ScoringRules(category))
+			stats.SetsPlayed += summary.sets
+			stats.DeuceSets += summary.deuceSets
+			if duration := summary.duration(); duration > 0 {
+				played++
+				playTime += duration
+			}
+
+			if scoreboard != nil {
+				autoReported = scoreboard.AutoReport
+				stats.AuthorMismatches += scoreboard.AuthorMissmatches
+				if scoreboard.Invalid {
+					stats.InvalidResults++
+				}
+				if autoReported && !summary.endedAt.IsZero() && scoreboard.ReportedAt.After(summary.endedAt) {
+					reported++
+					reportDelay += scoreboard.ReportedAt.Sub(summary.endedAt)
+				}
+			}
+		}
+
+		if autoReported {
+			stats.AutoReported++
+		} else if match.HasWinner != nil && *match.HasWinner {
+			stats.ManuallyReported++
+		}
+	}
+
+	for i, court := range stats.Courts {
+		stats.Courts[i].AdoptionPercentage = percentage(court.Scoreboards, court.Matches)
+	}
+	stats.DeuceSetPercentage = percentage(stats.DeuceSets, stats.SetsPlayed)
+	if played > 0 {
+		stats.AverageMatchDurationSeconds = int((playTime / time.Duration(played)).Seconds())
+	}
+	if reported > 0 {
+		stats.AverageReportDelaySeconds = int((reportDelay / time.Duration(reported)).Seconds())
+	}
+	return stats, nil
+}
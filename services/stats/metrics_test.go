@@ -0,0 +1,157 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xorcare/pointer"
+
+	"github.com/nvbf/tournament-sync/pkg/replay"
+	"github.com/nvbf/tournament-sync/pkg/scoring"
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+const metricsStart = int64(1_700_000_000_000)
+
+// setEvents returns the points of a set to home-away, one second apart from
+// ts, followed by a SET_FINALIZED event.
+func setEvents(prefix string, ts int64, home, away int) []Event {
+	points := strings.Repeat("H", home) + strings.Repeat("A", away)
+	events := make([]Event, 0, len(points)+1)
+	for i, point := range points {
+		team := "HOME"
+		if point == 'A' {
+			team = "AWAY"
+		}
+		events = append(events, Event{ID: fmt.Sprintf("%s-%d", prefix, i), EventType: "SCORE", Team: team, Timestamp: ts + int64(i)*1000})
+	}
+	return append(events, Event{ID: prefix + "-end", EventType: "SET_FINALIZED", Timestamp: ts + int64(len(points))*1000})
+}
+
+func TestSummarizeMatch(t *testing.T) {
+	events := setEvents("s1", metricsStart, 22, 20)
+	events = append(events, setEvents("s2", metricsStart+100_000, 21, 15)...)
+	events = append(events, Event{ID: "final", EventType: "MATCH_FINALIZED", Timestamp: metricsStart + 200_000})
+
+	cases := []struct {
+		name      string
+		events    []Event
+		sets      int
+		deuceSets int
+		duration  time.Duration
+	}{
+		{name: "two sets", events: events, sets: 2, deuceSets: 1, duration: 200 * time.Second},
+		{name: "deciding set deuce", events: setEvents("s3", metricsStart, 16, 14), sets: 1, duration: 30 * time.Second},
+		{name: "retirement", events: append(setEvents("s1", metricsStart, 20, 20)[:40], Event{ID: "retire", EventType: "RETIREMENT", Team: "AWAY", Timestamp: metricsStart + 60_000}), duration: 60 * time.Second},
+		{name: "no events"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			summary := summarizeMatch(c.events, scoring.Beach())
+			if summary.sets != c.sets || summary.deuceSets != c.deuceSets || summary.duration() != c.duration {
+				t.Fatalf("expected %d sets, %d deuce sets and %s, got %d, %d and %s", c.sets, c.deuceSets, c.duration, summary.sets, summary.deuceSets, summary.duration())
+			}
+		})
+	}
+
+	deciding := append(setEvents("s1", metricsStart, 21, 10), setEvents("s2", metricsStart+100_000, 10, 21)...)
+	deciding = append(deciding, setEvents("s3", metricsStart+200_000, 16, 14)...)
+	if summary := summarizeMatch(deciding, scoring.Beach()); summary.deuceSets != 1 {
+		t.Fatalf("expected the deciding set to 15 to be a deuce set, got %+v", summary)
+	}
+}
+
+func TestComputeTournamentStats(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "beach-cup", StartDate: "2020-01-01", EndDate: "2020-01-02"})
+	court := func(name string) *profixio.Field { return &profixio.Field{Name: pointer.String(name)} }
+
+	// Match 1 is auto-reported 90 seconds after it ended, match 2 has an
+	// invalid result and match 3 was reported in Profixio without a
+	// scoreboard.
+	events := setEvents("s1", metricsStart, 22, 20)
+	events = append(events, setEvents("s2", metricsStart+100_000, 21, 15)...)
+	events = append(events, Event{ID: "final", EventType: "MATCH_FINALIZED", Timestamp: metricsStart + 200_000})
+	store.SeedMatch("beach-cup", storage.Match{Match: profixio.Match{Number: pointer.String("1"), Field: court("Court 1"), HasWinner: pointer.Bool(true)}, ScoreboardId: "sb-1"})
+	store.SeedScoreboard("sb-1", storage.Scoreboard{AutoReport: true, AuthorMissmatches: 2, ReportedAt: replay.EventTime(metricsStart + 290_000)})
+	for _, event := range events {
+		_ = store.AppendEvent(context.Background(), "sb-1", storage.Event(event))
+	}
+
+	store.SeedMatch("beach-cup", storage.Match{Match: profixio.Match{Number: pointer.String("2"), Field: court("Court 1")}, ScoreboardId: "sb-2"})
+	store.SeedScoreboard("sb-2", storage.Scoreboard{Invalid: true, AuthorMissmatches: 1})
+	for _, event := range setEvents("s1", metricsStart, 21, 5) {
+		_ = store.AppendEvent(context.Background(), "sb-2", storage.Event(event))
+	}
+
+	store.SeedMatch("beach-cup", storage.Match{Match: profixio.Match{Number: pointer.String("3"), Field: court("Court 2"), HasWinner: pointer.Bool(true)}})
+	store.SeedMatch("beach-cup", storage.Match{Match: profixio.Match{Number: pointer.String("4"), Field: court("Court 2")}})
+
+	service := NewStatsService(store, nil)
+	if err := service.UpdateStats(newTestContext()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stats, err := service.GetTournamentStats(newTestContext(), "beach-cup")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	metrics := stats.Metrics
+	if metrics == nil {
+		t.Fatal("expected metrics to be written")
+	}
+	metrics.UpdatedAt = time.Time{}
+	expected := storage.TournamentStats{
+		Slug:                        "beach-cup",
+		NumberOfMatches:             4,
+		NumberOfScoreboards:         2,
+		AutoReported:                1,
+		ManuallyReported:            1,
+		InvalidResults:              1,
+		AuthorMismatches:            3,
+		AverageMatchDurationSeconds: 113,
+		SetsPlayed:                  3,
+		DeuceSets:                   1,
+		DeuceSetPercentage:          33.3,
+		AverageReportDelaySeconds:   90,
+		Courts: []storage.CourtStats{
+			{Court: "Court 1", Matches: 2, Scoreboards: 2, AdoptionPercentage: 100},
+			{Court: "Court 2", Matches: 2},
+		},
+	}
+	if !reflect.DeepEqual(*metrics, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, *metrics)
+	}
+
+	page, err := service.GetStats(newTestContext(), StatsQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page.Tournaments) != 1 || page.Tournaments[0].Metrics == nil || page.Tournaments[0].Metrics.AutoReported != 1 {
+		t.Fatalf("expected /all to include the written metrics, got %+v", page.Tournaments)
+	}
+}
+
+func TestGetTournamentStatsComputesUnwrittenStats(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "ongoing", EndDate: "2999-01-01"})
+	store.SeedMatch("ongoing", storage.Match{Match: profixio.Match{Number: pointer.String("1"), HasWinner: pointer.Bool(true)}})
+	service := NewStatsService(store, nil)
+
+	stats, err := service.GetTournamentStats(newTestContext(), "ongoing")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats.Metrics == nil || stats.Metrics.NumberOfMatches != 1 || stats.Metrics.ManuallyReported != 1 {
+		t.Fatalf("expected metrics computed from the current matches, got %+v", stats.Metrics)
+	}
+	if _, err := store.GetTournamentStats(context.Background(), "ongoing"); err == nil {
+		t.Fatal("expected computed stats not to be written")
+	}
+}
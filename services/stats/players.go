@@ -0,0 +1,211 @@
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/nvbf/tournament-sync/pkg/replay"
+)
+
+// Event types the scoreboard can use to attribute a rally to a player of
+// Team. They annotate the rally only; its SCORE event still carries the point.
+const (
+	eventAce   = "ACE"
+	eventBlock = "BLOCK"
+	eventError = "ERROR"
+)
+
+// PlayerStats aggregates the events attributed to one player. Players are
+// identified by their number within their team.
+type PlayerStats struct {
+	Team     string `json:"team"`
+	PlayerID int    `json:"playerId"`
+	Matches  int    `json:"matches"`
+	Points   int    `json:"points"`
+	Aces     int    `json:"aces"`
+	Blocks   int    `json:"blocks"`
+	Errors   int    `json:"errors"`
+}
+
+// TeamStats aggregates the events of one team. Rallies are only counted once
+// the serving team is known, which is from the second rally of every set.
+type TeamStats struct {
+	Team           string `json:"team"`
+	Matches        int    `json:"matches"`
+	Points         int    `json:"points"`
+	Aces           int    `json:"aces"`
+	Blocks         int    `json:"blocks"`
+	Errors         int    `json:"errors"`
+	ServingRallies int    `json:"servingRallies"`
+	BreakPoints    int    `json:"breakPoints"`
+	ReceiveRallies int    `json:"receiveRallies"`
+	SideOuts       int    `json:"sideOuts"`
+	// SideOutPercentage is the share of rallies won when receiving, and
+	// BreakPointPercentage the share won when serving.
+	SideOutPercentage    float64 `json:"sideOutPercentage"`
+	BreakPointPercentage float64 `json:"breakPointPercentage"`
+}
+
+// PlayerStatsReport holds the player and team aggregates of the finalized
+// matches of a match, tournament or season.
+type PlayerStatsReport struct {
+	Matches int            `json:"matches"`
+	Teams   []*TeamStats   `json:"teams"`
+	Players []*PlayerStats `json:"players"`
+}
+
+type playerKey struct {
+	team     string
+	playerID int
+}
+
+// playerAggregator sums the events of matches into a PlayerStatsReport.
+type playerAggregator struct {
+	matches int
+	teams   map[string]*TeamStats
+	players map[playerKey]*PlayerStats
+}
+
+func newPlayerAggregator() *playerAggregator {
+	return &playerAggregator{
+		teams:   map[string]*TeamStats{},
+		players: map[playerKey]*PlayerStats{},
+	}
+}
+
+// addMatch replays the active events of a match between the named teams.
+func (a *playerAggregator) addMatch(home, away string, events []Event) {
+	a.matches++
+	names := map[string]string{"HOME": home, "AWAY": away}
+	a.team(home).Matches++
+	a.team(away).Matches++
+	seen := map[playerKey]bool{}
+
+	player := func(event Event) *PlayerStats {
+		if event.PlayerID == 0 {
+			return nil
+		}
+		key := playerKey{team: names[event.Team], playerID: event.PlayerID}
+		stats, ok := a.players[key]
+		if !ok {
+			stats = &PlayerStats{Team: key.team, PlayerID: key.playerID}
+			a.players[key] = stats
+		}
+		if !seen[key] {
+			seen[key] = true
+			stats.Matches++
+		}
+		return stats
+	}
+
+	serving := ""
+	for _, event := range replay.ActiveEvents(events) {
+		switch event.EventType {
+		case "SCORE":
+			if names[event.Team] == "" {
+				continue
+			}
+			winner := a.team(names[event.Team])
+			winner.Points++
+			if stats := player(event); stats != nil {
+				stats.Points++
+			}
+			if serving != "" {
+				server := a.team(names[serving])
+				receiver := a.team(names[opponent(serving)])
+				server.ServingRallies++
+				receiver.ReceiveRallies++
+				if serving == event.Team {
+					server.BreakPoints++
+				} else {
+					receiver.SideOuts++
+				}
+			}
+			serving = event.Team
+
+		case eventAce, eventBlock, eventError:
+			if names[event.Team] == "" {
+				continue
+			}
+			team := a.team(names[event.Team])
+			stats := player(event)
+			if stats == nil {
+				stats = &PlayerStats{}
+			}
+			switch event.EventType {
+			case eventAce:
+				team.Aces++
+				stats.Aces++
+			case eventBlock:
+				team.Blocks++
+				stats.Blocks++
+			case eventError:
+				team.Errors++
+				stats.Errors++
+			}
+
+		case "SET_FINALIZED", "SET_TIME_EXPIRED":
+			serving = ""
+
+		case "MATCH_FINALIZED", "FORFEIT", "NO_SHOW", "RETIREMENT":
+			return
+		}
+	}
+}
+
+func (a *playerAggregator) team(name string) *TeamStats {
+	stats, ok := a.teams[name]
+	if !ok {
+		stats = &TeamStats{Team: name}
+		a.teams[name] = stats
+	}
+	return stats
+}
+
+// report returns the aggregates with teams sorted by name and players by
+// points scored.
+func (a *playerAggregator) report() *PlayerStatsReport {
+	report := &PlayerStatsReport{
+		Matches: a.matches,
+		Teams:   make([]*TeamStats, 0, len(a.teams)),
+		Players: make([]*PlayerStats, 0, len(a.players)),
+	}
+	for _, team := range a.teams {
+		team.SideOutPercentage = percentage(team.SideOuts, team.ReceiveRallies)
+		team.BreakPointPercentage = percentage(team.BreakPoints, team.ServingRallies)
+		report.Teams = append(report.Teams, team)
+	}
+	for _, player := range a.players {
+		report.Players = append(report.Players, player)
+	}
+
+	sort.Slice(report.Teams, func(i, j int) bool {
+		return report.Teams[i].Team < report.Teams[j].Team
+	})
+	sort.Slice(report.Players, func(i, j int) bool {
+		pi, pj := report.Players[i], report.Players[j]
+		if pi.Points != pj.Points {
+			return pi.Points > pj.Points
+		}
+		if pi.Team != pj.Team {
+			return pi.Team < pj.Team
+		}
+		return pi.PlayerID < pj.PlayerID
+	})
+	return report
+}
+
+func opponent(team string) string {
+	if team == "HOME" {
+		return "AWAY"
+	}
+	return "HOME"
+}
+
+// percentage returns part of total as a percentage with one decimal.
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/xorcare/pointer"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// rally returns a SCORE event for each H or A in points, starting at ts.
+func rally(ts int64, points string, playerID int) []Event {
+	events := make([]Event, 0, len(points))
+	for i, point := range points {
+		team := "HOME"
+		if point == 'A' {
+			team = "AWAY"
+		}
+		events = append(events, Event{ID: fmt.Sprintf("p%d-%d", ts, i), EventType: "SCORE", Team: team, PlayerID: playerID, Timestamp: ts + int64(i)})
+	}
+	return events
+}
+
+func TestPlayerAggregator(t *testing.T) {
+	events := rally(100, "HHAH", 1)
+	events = append(events,
+		Event{ID: "ace", EventType: eventAce, Team: "HOME", PlayerID: 2, Timestamp: 110},
+		Event{ID: "block", EventType: eventBlock, Team: "AWAY", PlayerID: 1, Timestamp: 111},
+		Event{ID: "error", EventType: eventError, Team: "AWAY", Timestamp: 112},
+		Event{ID: "undone", EventType: "SCORE", Team: "AWAY", PlayerID: 2, Timestamp: 113},
+		Event{ID: "undo", EventType: "UNDO", Reference: "undone", Timestamp: 114},
+		Event{ID: "set", EventType: "SET_FINALIZED", Timestamp: 120},
+	)
+	events = append(events, rally(200, "AA", 2)...)
+	events = append(events,
+		Event{ID: "final", EventType: "MATCH_FINALIZED", Timestamp: 300},
+		Event{ID: "late", EventType: "SCORE", Team: "HOME", PlayerID: 1, Timestamp: 301},
+	)
+
+	aggregator := newPlayerAggregator()
+	aggregator.addMatch("Home", "Away", events)
+	report := aggregator.report()
+
+	if report.Matches != 1 || len(report.Teams) != 2 {
+		t.Fatalf("expected 1 match between 2 teams, got %+v", report)
+	}
+	away, home := *report.Teams[0], *report.Teams[1]
+	expectedHome := TeamStats{Team: "Home", Matches: 1, Points: 3, Aces: 1, ServingRallies: 2, BreakPoints: 1, ReceiveRallies: 2, SideOuts: 1, SideOutPercentage: 50, BreakPointPercentage: 50}
+	expectedAway := TeamStats{Team: "Away", Matches: 1, Points: 3, Blocks: 1, Errors: 1, ServingRallies: 2, BreakPoints: 1, ReceiveRallies: 2, SideOuts: 1, SideOutPercentage: 50, BreakPointPercentage: 50}
+	if home != expectedHome {
+		t.Errorf("expected home %+v, got %+v", expectedHome, home)
+	}
+	if away != expectedAway {
+		t.Errorf("expected away %+v, got %+v", expectedAway, away)
+	}
+
+	expectedPlayers := []PlayerStats{
+		{Team: "Home", PlayerID: 1, Matches: 1, Points: 3},
+		{Team: "Away", PlayerID: 2, Matches: 1, Points: 2},
+		{Team: "Away", PlayerID: 1, Matches: 1, Points: 1, Blocks: 1},
+		{Team: "Home", PlayerID: 2, Matches: 1, Aces: 1},
+	}
+	if len(report.Players) != len(expectedPlayers) {
+		t.Fatalf("expected %d players, got %d", len(expectedPlayers), len(report.Players))
+	}
+	for i, expected := range expectedPlayers {
+		if *report.Players[i] != expected {
+			t.Errorf("player %d: expected %+v, got %+v", i, expected, *report.Players[i])
+		}
+	}
+}
+
+func seedFinalizedMatch(store *storage.Memory, slug, number string, finalized bool, events []Event) {
+	id := "scoreboard-" + slug + "-" + number
+	store.SeedMatch(slug, storage.Match{
+		Match: profixio.Match{
+			Number:   pointer.String(number),
+			HomeTeam: &profixio.Team{Name: "Home " + slug},
+			AwayTeam: &profixio.Team{Name: "Away " + slug},
+		},
+		ScoreboardId: id,
+		IsFinalized:  finalized,
+	})
+	store.SeedScoreboard(id, storage.Scoreboard{MatchNumber: number, TournamentSlug: slug})
+	for _, event := range events {
+		_ = store.AppendEvent(context.Background(), id, storage.Event(event))
+	}
+}
+
+func TestPlayerStatsScopes(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "spring", StartDate: "2024-04-01", NumberOfScoreboards: 2})
+	store.SeedTournament(storage.Tournament{Slug: "autumn", StartDate: "2024-09-01", NumberOfScoreboards: 1})
+	store.SeedTournament(storage.Tournament{Slug: "old", StartDate: "2023-06-01", NumberOfScoreboards: 1})
+	seedFinalizedMatch(store, "spring", "1", true, rally(100, "HHA", 1))
+	seedFinalizedMatch(store, "spring", "2", false, rally(100, "AAAA", 1))
+	seedFinalizedMatch(store, "autumn", "1", true, rally(100, "AH", 2))
+	seedFinalizedMatch(store, "old", "1", true, rally(100, "H", 1))
+	service := NewStatsService(store, nil)
+
+	cases := []struct {
+		name        string
+		get         func() (*PlayerStatsReport, error)
+		err         error
+		matches     int
+		totalPoints int
+	}{
+		{name: "match", get: func() (*PlayerStatsReport, error) {
+			return service.GetMatchPlayerStats(newTestContext(), "scoreboard-spring-1")
+		}, matches: 1, totalPoints: 3},
+		{name: "match not finalized", get: func() (*PlayerStatsReport, error) {
+			return service.GetMatchPlayerStats(newTestContext(), "scoreboard-spring-2")
+		}, err: ErrMatchNotFinalized},
+		{name: "unknown match", get: func() (*PlayerStatsReport, error) {
+			return service.GetMatchPlayerStats(newTestContext(), "missing")
+		}, err: storage.ErrNotFound},
+		{name: "tournament", get: func() (*PlayerStatsReport, error) {
+			return service.GetTournamentPlayerStats(newTestContext(), "spring")
+		}, matches: 1, totalPoints: 3},
+		{name: "unknown tournament", get: func() (*PlayerStatsReport, error) {
+			return service.GetTournamentPlayerStats(newTestContext(), "missing")
+		}, err: storage.ErrNotFound},
+		{name: "season", get: func() (*PlayerStatsReport, error) {
+			return service.GetSeasonPlayerStats(newTestContext(), "2024")
+		}, matches: 2, totalPoints: 5},
+		{name: "invalid season", get: func() (*PlayerStatsReport, error) {
+			return service.GetSeasonPlayerStats(newTestContext(), "24")
+		}, err: ErrInvalidSeason},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report, err := c.get()
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			points := 0
+			for _, team := range report.Teams {
+				points += team.Points
+			}
+			if report.Matches != c.matches || points != c.totalPoints {
+				t.Fatalf("expected %d matches and %d points, got %d and %d", c.matches, c.totalPoints, report.Matches, points)
+			}
+		})
+	}
+}
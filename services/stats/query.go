@@ -0,0 +1,131 @@
+package stats
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+// ErrInvalidQuery is returned when the filters or cursor of a stats query
+// cannot be used.
+var ErrInvalidQuery = errors.New("invalid stats query")
+
+// Page sizes of GetStats.
+const (
+	defaultStatsLimit = 50
+	maxStatsLimit     = 200
+)
+
+// StatsQuery filters and pages the tournaments returned by GetStats. Zero
+// values do not filter.
+type StatsQuery struct {
+	// From and To keep tournaments starting within the YYYY-MM-DD dates,
+	// both included.
+	From string
+	To   string
+	// Season keeps tournaments starting in the year, such as "2024".
+	Season string
+	Type   string
+	// Organisation keeps tournaments synced from the Profixio organisation,
+	// such as "NVBF.NO.VB", and Source those synced from one source of it.
+	Organisation string
+	Source       string
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+// StatsPage is one page of tournament stats with the totals of all
+// tournaments matching the query.
+type StatsPage struct {
+	Tournaments []*TournamentStats `json:"stats"`
+	Totals      StatsTotals        `json:"totals"`
+	// NextCursor fetches the next page. It is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// StatsTotals sums the scoreboard counts of the tournaments matching a query.
+type StatsTotals struct {
+	Tournaments         int     `json:"tournaments"`
+	NumberOfScoreboards int     `json:"numberOfScoreboards"`
+	NumberOfMatches     int     `json:"numberOfMatches"`
+	AdoptionPercentage  float64 `json:"adoptionPercentage"`
+}
+
+// validate checks the query and fills in the default limit.
+func (q *StatsQuery) validate() error {
+	var problems []string
+	for name, date := range map[string]string{"from": q.From, "to": q.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be a YYYY-MM-DD date", name))
+		}
+	}
+	if q.From != "" && q.To != "" && q.From > q.To {
+		problems = append(problems, "from must not be after to")
+	}
+	if q.Season != "" && !seasonPattern.MatchString(q.Season) {
+		problems = append(problems, "season must be a year")
+	}
+	if q.Limit < 0 || q.Limit > maxStatsLimit {
+		problems = append(problems, fmt.Sprintf("limit must be between 1 and %d", maxStatsLimit))
+	}
+	if q.Cursor != "" {
+		if _, _, err := decodeCursor(q.Cursor); err != nil {
+			problems = append(problems, "cursor is invalid")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidQuery, strings.Join(problems, "; "))
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultStatsLimit
+	}
+	return nil
+}
+
+// filter is the storage filter of the query. The season narrows the From and
+// To dates.
+func (q StatsQuery) filter() storage.TournamentFilter {
+	filter := storage.TournamentFilter{
+		HasScoreboards: true,
+		Source:         q.Source,
+		Type:           q.Type,
+		Organisation:   q.Organisation,
+		StartsFrom:     q.From,
+		StartsTo:       q.To,
+	}
+	if q.Season != "" {
+		filter.StartsFrom = max(filter.StartsFrom, q.Season+"-01-01")
+		if filter.StartsTo == "" || filter.StartsTo > q.Season+"-12-31" {
+			filter.StartsTo = q.Season + "-12-31"
+		}
+	}
+	return filter
+}
+
+// Tournaments are paged in StartDate and slug order. The cursor holds the
+// position of the last tournament of the previous page.
+
+func encodeCursor(stats *TournamentStats) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(stats.StartDate + "\x00" + stats.Slug))
+}
+
+func decodeCursor(cursor string) (startDate string, slug string, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", err
+	}
+	startDate, slug, ok := strings.Cut(string(decoded), "\x00")
+	if !ok || slug == "" {
+		return "", "", errors.New("malformed cursor")
+	}
+	return startDate, slug, nil
+}
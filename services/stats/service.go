@@ -1,82 +1,111 @@
 package stats
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"regexp"
 	"strings"
 
-	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	"github.com/gin-gonic/gin"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	timehelper "github.com/nvbf/tournament-sync/pkg/timeHelper"
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/xorcare/pointer"
 )
 
+var (
+	// ErrMatchNotFinalized is returned when stats are requested for a match
+	// that is still in play.
+	ErrMatchNotFinalized = errors.New("match is not finalized")
+	// ErrInvalidSeason is returned when a season is not a year.
+	ErrInvalidSeason = errors.New("invalid season")
+)
+
+var seasonPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
 type StatsService struct {
-	firestoreClient *firestore.Client
-	firebaseApp     *firebase.App
-	profixioService *profixio.Service
+	store       storage.Store
+	firebaseApp *firebase.App
 }
 
-func NewStatsService(firestoreClient *firestore.Client, firebaseApp *firebase.App) *StatsService {
+func NewStatsService(store storage.Store, firebaseApp *firebase.App) *StatsService {
 	return &StatsService{
-		firestoreClient: firestoreClient,
-		firebaseApp:     firebaseApp,
+		store:       store,
+		firebaseApp: firebaseApp,
 	}
 }
 
-func (s *StatsService) GetStats(c *gin.Context) ([]*TournamentStats, error) {
-	var tournaments []*TournamentStats
-
-	docs, err := s.firestoreClient.Collection("Tournaments").
-		Where("NumberOfScoreboards", ">", 0).
-		Documents(c).
-		GetAll()
+// GetStats returns a page of the stats of tournaments with scoreboards that
+// match the query, in StartDate order, with totals across all of them.
+func (s *StatsService) GetStats(c *gin.Context, query StatsQuery) (*StatsPage, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
 
+	filter := query.filter()
+	totals, err := s.store.SumTournaments(c, filter)
 	if err != nil {
-		log.Printf("Failed to write tournament to Firestore: %v\n", err)
+		log.Printf("Failed to sum tournaments from Firestore: %v\n", err)
 		return nil, err
 	}
 
-	log.Printf("Length of list %d", len(docs))
-
-	for _, doc := range docs {
-		tournament, err := docToTournamentStats(doc)
-		if err != nil {
-			return nil, err
-		}
-
-		tournaments = append(tournaments, tournament)
+	page := &StatsPage{Tournaments: []*TournamentStats{}}
+	page.Totals = StatsTotals{
+		Tournaments:         totals.Tournaments,
+		NumberOfScoreboards: totals.NumberOfScoreboards,
+		NumberOfMatches:     totals.NumberOfMatches,
+		AdoptionPercentage:  percentage(totals.NumberOfScoreboards, totals.NumberOfMatches),
 	}
+	log.Printf("Total scoreboards: %d / %d in %d tournaments", page.Totals.NumberOfScoreboards, page.Totals.NumberOfMatches, page.Totals.Tournaments)
 
-	sort.Slice(tournaments, func(i, j int) bool {
-		return tournaments[i].StartDate < tournaments[j].StartDate
-	})
+	// One more than the limit tells whether there is a next page.
+	position := storage.TournamentPage{Limit: query.Limit + 1}
+	if query.Cursor != "" {
+		position.AfterStartDate, position.AfterSlug, _ = decodeCursor(query.Cursor)
+	}
+	docs, err := s.store.PageTournaments(c, filter, position)
+	if err != nil {
+		log.Printf("Failed to list tournaments from Firestore: %v\n", err)
+		return nil, err
+	}
+	more := len(docs) > query.Limit
+	if more {
+		docs = docs[:query.Limit]
+	}
 
-	total := 0
-	totalScoreboards := 0
-	for i, v := range tournaments {
-		log.Printf("#%d\t%s\t-\t%s\tscoreboards: %d / %d", i, v.StartDate, v.Name, v.NumberOfScoreboards, v.NumberOfMatches)
-		total = v.NumberOfMatches + total
-		totalScoreboards = v.NumberOfScoreboards + totalScoreboards
+	slugs := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		page.Tournaments = append(page.Tournaments, toTournamentStats(doc))
+		slugs = append(slugs, doc.Slug)
+	}
+	if more {
+		page.NextCursor = encodeCursor(page.Tournaments[len(page.Tournaments)-1])
 	}
-	log.Printf("Total scoreboards: %d / %d", totalScoreboards, total)
 
-	// Send the processed tournament to the channel
-	return tournaments, nil
+	metrics, err := s.store.ListTournamentStats(c, slugs)
+	if err != nil {
+		return nil, err
+	}
+	metricsBySlug := make(map[string]*storage.TournamentStats, len(metrics))
+	for _, m := range metrics {
+		metricsBySlug[m.Slug] = m
+	}
+	for _, tournament := range page.Tournaments {
+		tournament.Metrics = metricsBySlug[tournament.Slug]
+	}
+	return page, nil
 }
 
 func (s *StatsService) UpdateStats(c *gin.Context) error {
 
 	var tournaments []*TournamentStats
 
-	docs, err := s.firestoreClient.Collection("Tournaments").
-		Where("EndDate", "<", timehelper.GetTodaysDateString()).
-		Where("StatsWritten", "==", false).
-		Documents(c).
-		GetAll()
-
+	docs, err := s.store.ListTournaments(c, storage.TournamentFilter{
+		EndsBefore:   timehelper.GetTodaysDateString(),
+		StatsWritten: pointer.Bool(false),
+	})
 	if err != nil {
 		log.Printf("Failed to write tournament to Firestore: %v\n", err)
 		return err
@@ -85,10 +114,7 @@ func (s *StatsService) UpdateStats(c *gin.Context) error {
 	log.Printf("Length of list %d", len(docs))
 
 	for _, doc := range docs {
-		tournament, err := docToTournamentStats(doc)
-		if err != nil {
-			return err
-		}
+		tournament := toTournamentStats(doc)
 		if !tournament.StatsWritten {
 			tournaments = append(tournaments, tournament)
 		}
@@ -100,42 +126,34 @@ func (s *StatsService) UpdateStats(c *gin.Context) error {
 			continue
 		}
 
-		matchDocs, err := s.firestoreClient.Collection("Tournaments").Doc(v.Slug).Collection("Matches").Documents(c).GetAll()
+		matches, err := s.store.ListMatches(c, v.Slug)
 		if err != nil {
 			log.Printf("Failed to write tournament to Firestore: %v\n", err)
 			return err
 		}
 
-		if len(matchDocs) == 0 {
+		if len(matches) == 0 {
 			continue
 		}
 
-		scoreboards := 0
-		totalMatches := 0
-		for _, matchDoc := range matchDocs {
-			var match Match
-			if err := matchDoc.DataTo(&match); err != nil {
-				// If this fails, we have an inconsistency error as we control both the data written to
-				// Firestore and the shape of our `fsIntegration` struct.
-				return fmt.Errorf(
-					"consistency error. Converting %+v to internal integration struct failed: %w",
-					matchDoc,
-					err,
-				)
-			}
-			totalMatches++
-			if match.ScoreboardId != "" {
-				scoreboards++
-			}
+		tournament, err := s.store.GetTournament(c, v.Slug)
+		if err != nil {
+			return err
+		}
+
+		metrics, err := s.computeTournamentStats(c, tournament)
+		if err != nil {
+			log.Printf("Failed to compute stats for %s: %v\n", v.Slug, err)
+			return err
 		}
 
-		updates := []firestore.Update{
-			{Path: "NumberOfScoreboards", Value: scoreboards},
-			{Path: "NumberOfMatches", Value: totalMatches},
-			{Path: "StatsWritten", Value: true},
+		err = s.store.PutTournamentStats(c, *metrics)
+		if err != nil {
+			log.Printf("Failed to write stats to Firestore: %v\n", err)
+			return err
 		}
 
-		_, err = s.firestoreClient.Collection("Tournaments").Doc(v.Slug).Update(c, updates)
+		err = s.store.WriteStats(c, v.Slug, metrics.NumberOfScoreboards, metrics.NumberOfMatches)
 		if err != nil {
 			log.Printf("Failed to update tournament to Firestore: %v\n", err)
 			return err
@@ -145,23 +163,133 @@ func (s *StatsService) UpdateStats(c *gin.Context) error {
 	return nil
 }
 
-func docToTournamentStats(doc *firestore.DocumentSnapshot) (*TournamentStats, error) {
-	var tournament TournamentStats
-	if err := doc.DataTo(&tournament); err != nil {
-		// If this fails, we have an inconsistency error as we control both the data written to
-		// Firestore and the shape of our `fsIntegration` struct.
-		return nil, fmt.Errorf(
-			"consistency error. Converting %+v to internal integration struct failed: %w",
-			doc,
-			err,
-		)
+func toTournamentStats(tournament *storage.Tournament) *TournamentStats {
+	return &TournamentStats{
+		Name:                tournament.Name,
+		Slug:                tournament.Slug,
+		Source:              tournament.Source,
+		StartDate:           tournament.StartDate,
+		EndDate:             tournament.EndDate,
+		NumberOfScoreboards: tournament.NumberOfScoreboards,
+		NumberOfMatches:     tournament.NumberOfMatches,
+		StatsWritten:        tournament.StatsWritten,
 	}
+}
 
-	return &tournament, nil
+// GetTournamentStats returns the stats of a tournament. Tournaments whose
+// stats are not written yet get them computed from their current matches.
+func (s *StatsService) GetTournamentStats(c *gin.Context, slug string) (*TournamentStats, error) {
+	tournament, err := s.store.GetTournament(c, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := toTournamentStats(tournament)
+	stats.Metrics, err = s.store.GetTournamentStats(c, slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		stats.Metrics, err = s.computeTournamentStats(c, tournament)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
 }
 
-type stats struct {
-	NumberOfScoreboards int  `firestore:"NumberOfScoreboards"`
-	NumberOfMatches     int  `firestore:"NumberOfMatches"`
-	StatsWritten        bool `firestore:"StatsWritten"`
+// GetMatchPlayerStats returns the player and team stats of a finalized match,
+// identified by its scoreboard ID.
+func (s *StatsService) GetMatchPlayerStats(c *gin.Context, matchID string) (*PlayerStatsReport, error) {
+	scoreboard, err := s.store.GetScoreboard(c, matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := s.store.GetMatch(c, scoreboard.TournamentSlug, scoreboard.MatchNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !match.IsFinalized {
+		return nil, ErrMatchNotFinalized
+	}
+
+	aggregator := newPlayerAggregator()
+	if err := s.addMatchEvents(c, aggregator, match); err != nil {
+		return nil, err
+	}
+	return aggregator.report(), nil
+}
+
+// GetTournamentPlayerStats returns the player and team stats of the finalized
+// matches of a tournament.
+func (s *StatsService) GetTournamentPlayerStats(c *gin.Context, slug string) (*PlayerStatsReport, error) {
+	if _, err := s.store.GetTournament(c, slug); err != nil {
+		return nil, err
+	}
+
+	aggregator := newPlayerAggregator()
+	if err := s.addTournamentEvents(c, aggregator, slug); err != nil {
+		return nil, err
+	}
+	return aggregator.report(), nil
+}
+
+// GetSeasonPlayerStats returns the player and team stats of the finalized
+// matches of all tournaments starting in the season, a year such as "2024".
+func (s *StatsService) GetSeasonPlayerStats(c *gin.Context, season string) (*PlayerStatsReport, error) {
+	if !seasonPattern.MatchString(season) {
+		return nil, fmt.Errorf("%w: %q is not a year", ErrInvalidSeason, season)
+	}
+
+	tournaments, err := s.store.ListTournaments(c, storage.TournamentFilter{HasScoreboards: true})
+	if err != nil {
+		return nil, err
+	}
+
+	aggregator := newPlayerAggregator()
+	for _, tournament := range tournaments {
+		if !strings.HasPrefix(tournament.StartDate, season+"-") {
+			continue
+		}
+		if err := s.addTournamentEvents(c, aggregator, tournament.Slug); err != nil {
+			return nil, err
+		}
+	}
+	return aggregator.report(), nil
+}
+
+func (s *StatsService) addTournamentEvents(c *gin.Context, aggregator *playerAggregator, slug string) error {
+	matches, err := s.store.ListMatches(c, slug)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if !match.IsFinalized {
+			continue
+		}
+		if err := s.addMatchEvents(c, aggregator, match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StatsService) addMatchEvents(c *gin.Context, aggregator *playerAggregator, match *storage.Match) error {
+	if match.ScoreboardId == "" {
+		return nil
+	}
+
+	events, err := s.store.ListEvents(c, match.ScoreboardId)
+	if err != nil {
+		return err
+	}
+
+	aggregator.addMatch(teamName(match.HomeTeam, "HOME"), teamName(match.AwayTeam, "AWAY"), events)
+	return nil
+}
+
+func teamName(team *profixio.Team, fallback string) string {
+	if team == nil || strings.TrimSpace(team.Name) == "" {
+		return fallback
+	}
+	return team.Name
 }
@@ -0,0 +1,164 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xorcare/pointer"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func newTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/stats/v1/update", nil)
+	return c
+}
+
+func seedMatches(store *storage.Memory, slug string, numbers []string, scoreboards int) {
+	for i, number := range numbers {
+		match := storage.Match{Match: profixio.Match{Number: pointer.String(number)}}
+		if i < scoreboards {
+			match.ScoreboardId = "scoreboard-" + slug + "-" + number
+		}
+		store.SeedMatch(slug, match)
+	}
+}
+
+func TestUpdateStats(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "past", Name: "Past", StartDate: "2020-01-01", EndDate: "2020-01-02"})
+	store.SeedTournament(storage.Tournament{Slug: "future", EndDate: "2999-01-01"})
+	store.SeedTournament(storage.Tournament{Slug: "empty", EndDate: "2020-01-02"})
+	seedMatches(store, "past", []string{"1", "2", "3"}, 2)
+	seedMatches(store, "future", []string{"1"}, 1)
+
+	service := NewStatsService(store, nil)
+	if err := service.UpdateStats(newTestContext()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cases := []struct {
+		slug         string
+		written      bool
+		scoreboards  int
+		totalMatches int
+	}{
+		{slug: "past", written: true, scoreboards: 2, totalMatches: 3},
+		{slug: "future"},
+		{slug: "empty"},
+	}
+	for _, c := range cases {
+		tournament, err := store.GetTournament(context.Background(), c.slug)
+		if err != nil {
+			t.Fatalf("%s: expected tournament, got %v", c.slug, err)
+		}
+		if tournament.StatsWritten != c.written || tournament.NumberOfScoreboards != c.scoreboards || tournament.NumberOfMatches != c.totalMatches {
+			t.Errorf("%s: expected written=%v scoreboards=%d matches=%d, got %+v", c.slug, c.written, c.scoreboards, c.totalMatches, tournament)
+		}
+	}
+
+	page, err := service.GetStats(newTestContext(), StatsQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(page.Tournaments) != 1 || page.Tournaments[0].Slug != "past" || page.Tournaments[0].Name != "Past" {
+		t.Fatalf("expected stats for the past tournament only, got %+v", page.Tournaments)
+	}
+}
+
+func TestGetStatsQuery(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "beach-cup", StartDate: "2024-06-01", Source: "NVBF.NO.VB/SVB", NumberOfScoreboards: 2, NumberOfMatches: 4})
+	store.SeedTournament(storage.Tournament{Slug: "indoor-cup", StartDate: "2024-10-01", Source: "NVBF.NO.VB/VB", NumberOfScoreboards: 1, NumberOfMatches: 4})
+	store.SeedTournament(storage.Tournament{Slug: "spring-cup", StartDate: "2024-04-01", Source: "OTHER/SVB", NumberOfScoreboards: 3, NumberOfMatches: 3})
+	store.SeedTournament(storage.Tournament{Slug: "nevza", StartDate: "2023-06-18", Type: storage.TournamentTypeCustom, NumberOfScoreboards: 4, NumberOfMatches: 8})
+	store.SeedTournament(storage.Tournament{Slug: "no-scoreboards", StartDate: "2024-05-01", NumberOfMatches: 10})
+	service := NewStatsService(store, nil)
+
+	cases := []struct {
+		name     string
+		query    StatsQuery
+		expected []string
+		totals   StatsTotals
+		err      error
+	}{
+		{name: "all", expected: []string{"nevza", "spring-cup", "beach-cup", "indoor-cup"}, totals: StatsTotals{Tournaments: 4, NumberOfScoreboards: 10, NumberOfMatches: 19, AdoptionPercentage: 52.6}},
+		{name: "source", query: StatsQuery{Source: "NVBF.NO.VB/VB"}, expected: []string{"indoor-cup"}, totals: StatsTotals{Tournaments: 1, NumberOfScoreboards: 1, NumberOfMatches: 4, AdoptionPercentage: 25}},
+		{name: "unknown source", query: StatsQuery{Source: "NVBF.NO.VB/SAND"}},
+		{name: "organisation", query: StatsQuery{Organisation: "NVBF.NO.VB"}, expected: []string{"beach-cup", "indoor-cup"}, totals: StatsTotals{Tournaments: 2, NumberOfScoreboards: 3, NumberOfMatches: 8, AdoptionPercentage: 37.5}},
+		{name: "season", query: StatsQuery{Season: "2023"}, expected: []string{"nevza"}, totals: StatsTotals{Tournaments: 1, NumberOfScoreboards: 4, NumberOfMatches: 8, AdoptionPercentage: 50}},
+		{name: "season and date range", query: StatsQuery{Season: "2024", From: "2023-01-01", To: "2024-05-31"}, expected: []string{"spring-cup"}, totals: StatsTotals{Tournaments: 1, NumberOfScoreboards: 3, NumberOfMatches: 3, AdoptionPercentage: 100}},
+		{name: "date range", query: StatsQuery{From: "2024-04-01", To: "2024-06-01"}, expected: []string{"spring-cup", "beach-cup"}, totals: StatsTotals{Tournaments: 2, NumberOfScoreboards: 5, NumberOfMatches: 7, AdoptionPercentage: 71.4}},
+		{name: "type", query: StatsQuery{Type: storage.TournamentTypeCustom}, expected: []string{"nevza"}, totals: StatsTotals{Tournaments: 1, NumberOfScoreboards: 4, NumberOfMatches: 8, AdoptionPercentage: 50}},
+		{name: "invalid date", query: StatsQuery{From: "01.06.2024"}, err: ErrInvalidQuery},
+		{name: "reversed range", query: StatsQuery{From: "2024-06-01", To: "2024-01-01"}, err: ErrInvalidQuery},
+		{name: "invalid season", query: StatsQuery{Season: "24"}, err: ErrInvalidQuery},
+		{name: "invalid cursor", query: StatsQuery{Cursor: "not a cursor"}, err: ErrInvalidQuery},
+		{name: "limit too large", query: StatsQuery{Limit: maxStatsLimit + 1}, err: ErrInvalidQuery},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, err := service.GetStats(newTestContext(), c.query)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var slugs []string
+			for _, tournament := range page.Tournaments {
+				slugs = append(slugs, tournament.Slug)
+			}
+			if strings.Join(slugs, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("expected %v, got %v", c.expected, slugs)
+			}
+			if page.Totals != c.totals {
+				t.Errorf("expected totals %+v, got %+v", c.totals, page.Totals)
+			}
+		})
+	}
+}
+
+func TestGetStatsPagination(t *testing.T) {
+	store := storage.NewMemory()
+	for _, slug := range []string{"a", "b", "c", "d", "e"} {
+		store.SeedTournament(storage.Tournament{Slug: slug, StartDate: "2024-06-01", NumberOfScoreboards: 1, NumberOfMatches: 2})
+	}
+	service := NewStatsService(store, nil)
+
+	var slugs []string
+	query := StatsQuery{Limit: 2}
+	for pages := 1; ; pages++ {
+		page, err := service.GetStats(newTestContext(), query)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if page.Totals.Tournaments != 5 || page.Totals.NumberOfMatches != 10 {
+			t.Fatalf("expected totals across all pages, got %+v", page.Totals)
+		}
+		for _, tournament := range page.Tournaments {
+			slugs = append(slugs, tournament.Slug)
+		}
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Fatalf("expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if strings.Join(slugs, ",") != "a,b,c,d,e" {
+		t.Fatalf("expected every tournament once in order, got %v", slugs)
+	}
+}
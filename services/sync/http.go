@@ -1,12 +1,15 @@
 package sync
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	authz "github.com/nvbf/tournament-sync/pkg/auth"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	"github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/storage"
 )
 
 // Router is the interface for a router.
@@ -19,12 +22,11 @@ type Router interface {
 
 // Greeter is the interface for a greeter service.
 type Sync interface {
-	FetchTournaments(c *gin.Context) error
-	CleanupTournaments(c *gin.Context) error
+	FetchTournaments(c *gin.Context, sources []string) (*storage.SyncJob, error)
 	SyncTournamentMatches(c *gin.Context, slug string, force bool) error
 	SyncTournamentMatch(c *gin.Context, slug string, matchID string) error
-	UpdateCustomTournament(c *gin.Context, slug string, tournament profixio.CustomTournament) error
-	CreateIfNoExisting(c *gin.Context, slug string) error
+	GetSyncJob(c *gin.Context, id string) (*storage.SyncJob, error)
+	ListSyncJobs(c *gin.Context, slug string) ([]*storage.SyncJob, error)
 }
 
 // HTTPOptions contains all the options needed for the HTTP handler.
@@ -35,41 +37,55 @@ type HTTPOptions struct {
 
 	// The router instance to configure the HTTP routes.
 	Router Router
+
+	// Guard checks the caller's role in the tournament of the
+	// /tournament/:slug_id routes. The other routes act on every tournament
+	// and are left to admins and services. Nil leaves all routes open.
+	Guard authz.Guard
 }
 
 // NewHTTPHandler creates a new HTTP handler.
 func NewHTTPHandler(opts HTTPOptions) {
 	r := opts.Router
 	h := &httpHandler{opts}
-	r.GET("/tournaments", h.syncTournamentsHandler)
-	r.GET("/tournament/:slug_id", h.syncTournamentMatchesHandler)
-	r.GET("/tournament/:slug_id/match/:match_id", h.syncTournamentMatchHandler)
-	r.POST("/custom/tournament/:slug_id", h.updateCustomTournamentHandler)
+	r.GET("/tournaments", h.guard(authz.ActionSync), h.syncTournamentsHandler)
+	r.GET("/tournament/:slug_id", h.guard(authz.ActionSync), h.syncTournamentMatchesHandler)
+	r.GET("/tournament/:slug_id/match/:match_id", h.guard(authz.ActionSync), h.syncTournamentMatchHandler)
+	r.GET("/tournament/:slug_id/jobs", h.guard(authz.ActionView), h.listSyncJobsHandler)
+	r.GET("/jobs/:id", h.guard(authz.ActionView), h.getSyncJobHandler)
 }
 
 type httpHandler struct {
 	HTTPOptions
 }
 
+func (s *httpHandler) guard(action authz.Action) gin.HandlerFunc {
+	if s.Guard == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return s.Guard(action)
+}
+
 func (s *httpHandler) syncTournamentsHandler(c *gin.Context) {
-	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath()}))
-	err := s.Service.FetchTournaments(c)
-	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath()}))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+	sources := c.QueryArray("source")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath(), "sources": sources}))
+	job, err := s.Service.FetchTournaments(c, sources)
+	if errors.Is(err, profixio.ErrUnknownSource) {
+		log.Warning("request failed", log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath(), "sources": sources, "reason": err.Error()}))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		c.Abort()
 		return
 	}
-	err = s.Service.CleanupTournaments(c)
 	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath(), "cleanup": true}))
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath()}))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
 		c.Abort()
 		return
 	}
-	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath()}))
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "syncTournaments", "path": c.FullPath(), "jobID": job.ID}))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Async function started",
+		"jobID":   job.ID,
 	})
 }
 
@@ -114,34 +130,38 @@ func (s *httpHandler) syncTournamentMatchHandler(c *gin.Context) {
 	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "syncTournamentMatch", "path": c.FullPath(), "slug": slug, "matchID": matchID}))
 }
 
-func (s *httpHandler) updateCustomTournamentHandler(c *gin.Context) {
-	// slug := c.Param("slug_id")
-	slug := "nevza_oddanesand_24"
-	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "slug": slug}))
+func (s *httpHandler) getSyncJobHandler(c *gin.Context) {
+	id := c.Param("id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "getSyncJob", "path": c.FullPath(), "jobID": id}))
 
-	err := s.Service.CreateIfNoExisting(c, slug)
-	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "step": "createIfNoExisting", "slug": slug}))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+	job, err := s.Service.GetSyncJob(c, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Warning("request failed", log.WithRequest(c, log.Fields{"handler": "getSyncJob", "path": c.FullPath(), "jobID": id, "reason": "not found"}))
+		c.JSON(http.StatusNotFound, gin.H{"error": "sync job not found"})
 		c.Abort()
 		return
 	}
-
-	var request profixio.CustomTournament
-	if err = c.ShouldBindJSON(&request); err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "step": "bindJSON", "slug": slug}))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err != nil {
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "getSyncJob", "path": c.FullPath(), "jobID": id}))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
 		c.Abort()
 		return
 	}
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "getSyncJob", "path": c.FullPath(), "jobID": id, "status": job.Status}))
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+func (s *httpHandler) listSyncJobsHandler(c *gin.Context) {
+	slug := c.Param("slug_id")
+	log.Info("request start", log.WithRequest(c, log.Fields{"handler": "listSyncJobs", "path": c.FullPath(), "slug": slug}))
 
-	err = s.Service.UpdateCustomTournament(c, slug, request)
+	jobs, err := s.Service.ListSyncJobs(c, slug)
 	if err != nil {
-		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "step": "updateCustomTournament", "slug": slug}))
+		log.Error("request failed", err, log.WithRequest(c, log.Fields{"handler": "listSyncJobs", "path": c.FullPath(), "slug": slug}))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
 		c.Abort()
 		return
 	}
-	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "updateCustomTournament", "path": c.FullPath(), "slug": slug}))
-	c.JSON(http.StatusOK, gin.H{"slug": slug})
+	log.Info("request completed", log.WithRequest(c, log.Fields{"handler": "listSyncJobs", "path": c.FullPath(), "slug": slug, "count": len(jobs)}))
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
 }
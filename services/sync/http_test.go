@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func setupSyncRouter(store storage.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewHTTPHandler(HTTPOptions{Service: NewSyncService(store, nil, nil), Router: r})
+	return r
+}
+
+func performRequest(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetSyncJobHandler(t *testing.T) {
+	store := storage.NewMemory()
+	store.SaveSyncJob(context.Background(), storage.SyncJob{ID: "job-1", Kind: storage.SyncJobMatches, Slug: "beach-cup", Status: storage.SyncJobFailed, Error: "profixio unavailable"})
+	r := setupSyncRouter(store)
+
+	cases := []struct {
+		name       string
+		path       string
+		statusCode int
+	}{
+		{name: "found", path: "/jobs/job-1", statusCode: http.StatusOK},
+		{name: "not found", path: "/jobs/missing", statusCode: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := performRequest(r, http.MethodGet, c.path)
+			if w.Code != c.statusCode {
+				t.Fatalf("expected status %d, got %d", c.statusCode, w.Code)
+			}
+		})
+	}
+
+	w := performRequest(r, http.MethodGet, "/jobs/job-1")
+	var body struct {
+		Job storage.SyncJob `json:"job"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if body.Job.Status != storage.SyncJobFailed || body.Job.Error != "profixio unavailable" {
+		t.Fatalf("expected failed job with error, got %+v", body.Job)
+	}
+}
+
+func TestListSyncJobsHandler(t *testing.T) {
+	store := storage.NewMemory()
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	store.SaveSyncJob(context.Background(), storage.SyncJob{ID: "old", Slug: "beach-cup", StartedAt: start})
+	store.SaveSyncJob(context.Background(), storage.SyncJob{ID: "new", Slug: "beach-cup", StartedAt: start.Add(time.Hour)})
+	store.SaveSyncJob(context.Background(), storage.SyncJob{ID: "other", Slug: "other-cup", StartedAt: start})
+	r := setupSyncRouter(store)
+
+	w := performRequest(r, http.MethodGet, "/tournament/beach-cup/jobs")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var body struct {
+		Jobs []storage.SyncJob `json:"jobs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(body.Jobs) != 2 || body.Jobs[0].ID != "new" || body.Jobs[1].ID != "old" {
+		t.Fatalf("expected jobs [new old], got %+v", body.Jobs)
+	}
+}
+
+func TestSyncTournamentsHandlerUnknownSource(t *testing.T) {
+	store := storage.NewMemory()
+	server := profixiotest.NewServer(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewHTTPHandler(HTTPOptions{Service: NewSyncService(store, nil, profixio.NewService(store, "", server.Options()...)), Router: r})
+
+	w := performRequest(r, http.MethodGet, "/tournaments?source=NVBF.NO.VB/SAND")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if jobs, _ := store.ListSyncJobs(context.Background(), ""); len(jobs) != 0 {
+		t.Fatalf("expected no sync job, got %+v", jobs)
+	}
+}
+
+func TestSyncTournamentsHandler(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "empty-past", EndDate: "2020-01-01"})
+	server := profixiotest.NewServer(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewHTTPHandler(HTTPOptions{Service: NewSyncService(store, nil, profixio.NewService(store, "", server.Options()...)), Router: r})
+
+	w := performRequest(r, http.MethodGet, "/tournaments")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var body struct {
+		JobID string `json:"jobID"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a single JSON body, got %q: %v", w.Body.String(), err)
+	}
+	if body.JobID == "" {
+		t.Fatalf("expected a job ID, got %q", w.Body.String())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		job, err := store.GetSyncJob(context.Background(), body.JobID)
+		_, missing := store.GetTournament(context.Background(), "empty-past")
+		if err == nil && job.Status == storage.SyncJobSucceeded && errors.Is(missing, storage.ErrNotFound) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the job to succeed and clean up empty-past, got job %+v (%v) and tournament error %v", job, err, missing)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,223 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+	_ "time/tzdata" // Europe/Oslo must load in minimal container images
+
+	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/samborkent/uuidv7"
+)
+
+// schedulerLease is the lease that elects the instance running the scheduler.
+const schedulerLease = "scheduler"
+
+// errLeaseLost stops a tick once another instance has taken the lease.
+var errLeaseLost = errors.New("scheduler lease lost")
+
+// SchedulerOptions configures the Scheduler.
+type SchedulerOptions struct {
+	// Tick is how often the scheduler wakes up to look for due work.
+	Tick time.Duration
+	// LeaseTTL is how long leadership is held without being renewed. The
+	// lease is renewed between syncs, so it must outlast the longest single
+	// sync.
+	LeaseTTL time.Duration
+	// Location is the time zone of the hours below.
+	Location *time.Location
+	// TournamentSyncHour is the hour of day from which the daily tournament
+	// list sync runs.
+	TournamentSyncHour int
+	// TournamentSyncRetry is the time before a failed tournament list sync
+	// is tried again.
+	TournamentSyncRetry time.Duration
+	// MatchHoursStart and MatchHoursEnd bound the hours, [start, end), when
+	// matches are played and active tournaments are polled more often.
+	MatchHoursStart int
+	MatchHoursEnd   int
+	// MatchHoursInterval and OffHoursInterval are the time between match
+	// syncs of an active tournament in and outside match hours.
+	MatchHoursInterval time.Duration
+	OffHoursInterval   time.Duration
+}
+
+// DefaultSchedulerOptions polls active tournaments every 2 minutes between
+// 08:00 and 22:00 Norwegian time and every 30 minutes otherwise, and syncs
+// the tournament list once a day from 04:00, retrying every 30 minutes if it
+// fails.
+func DefaultSchedulerOptions() SchedulerOptions {
+	location, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		log.Error("scheduler load location failed", err, log.Fields{"operation": "scheduler", "location": "Europe/Oslo"})
+		location = time.UTC
+	}
+
+	return SchedulerOptions{
+		Tick:                time.Minute,
+		LeaseTTL:            3 * time.Minute,
+		Location:            location,
+		TournamentSyncHour:  4,
+		TournamentSyncRetry: 30 * time.Minute,
+		MatchHoursStart:     8,
+		MatchHoursEnd:       22,
+		MatchHoursInterval:  2 * time.Minute,
+		OffHoursInterval:    30 * time.Minute,
+	}
+}
+
+// Scheduler keeps tournaments and matches in sync without an external cron.
+// Only the instance holding the scheduler lease does any work, so several
+// instances can run it at once.
+type Scheduler struct {
+	service *SyncService
+	opts    SchedulerOptions
+	holder  string
+	now     func() time.Time
+}
+
+func NewScheduler(service *SyncService, opts SchedulerOptions) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		service: service,
+		opts:    opts,
+		holder:  hostname + "/" + uuidv7.New().String(),
+		now:     time.Now,
+	}
+}
+
+// Run ticks until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Info("scheduler start", log.Fields{"operation": "scheduler", "holder": s.holder, "tick": s.opts.Tick.String()})
+
+	ticker := time.NewTicker(s.opts.Tick)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("scheduler stop", log.Fields{"operation": "scheduler", "holder": s.holder})
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	if err := s.renewLease(ctx); err != nil {
+		if !errors.Is(err, errLeaseLost) {
+			log.Error("scheduler acquire lease failed", err, log.Fields{"operation": "scheduler", "holder": s.holder})
+		}
+		return
+	}
+
+	if err := s.syncTournaments(ctx); err != nil {
+		log.Error("scheduler tournament sync failed", err, log.Fields{"operation": "scheduler"})
+		if errors.Is(err, errLeaseLost) {
+			return
+		}
+	}
+	if err := s.syncActiveTournaments(ctx); err != nil {
+		log.Error("scheduler match sync failed", err, log.Fields{"operation": "scheduler"})
+	}
+}
+
+// renewLease takes or renews the scheduler lease. It returns errLeaseLost if
+// another instance holds it. Ticks renew it between syncs, so a long tick
+// keeps it and a second instance never polls Profixio at the same time.
+func (s *Scheduler) renewLease(ctx context.Context) error {
+	leader, err := s.service.store.AcquireLease(ctx, schedulerLease, s.holder, s.opts.LeaseTTL)
+	if err != nil {
+		return err
+	}
+	if !leader {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// syncTournaments runs the tournament list sync and cleanup once a day. The
+// date of the last successful run is kept in the scheduler state, so a failed
+// run is retried after TournamentSyncRetry and a finished one is not repeated
+// after restarts and leader changes.
+func (s *Scheduler) syncTournaments(ctx context.Context) error {
+	now := s.now()
+	local := now.In(s.opts.Location)
+	if local.Hour() < s.opts.TournamentSyncHour {
+		return nil
+	}
+
+	state, err := s.service.store.GetSchedulerState(ctx)
+	if err != nil {
+		return err
+	}
+	today := local.Format("2006-01-02")
+	if state.TournamentsSyncedOn == today || now.Sub(state.TournamentsAttemptedAt) < s.opts.TournamentSyncRetry {
+		return nil
+	}
+
+	state.TournamentsAttemptedAt = now
+	if err := s.service.store.SaveSchedulerState(ctx, *state); err != nil {
+		return err
+	}
+
+	log.Info("scheduler tournament sync start", log.Fields{"operation": "scheduler", "date": today})
+	job := s.service.startJob(ctx, storage.SyncJob{Kind: storage.SyncJobTournaments})
+	if err := s.service.fetchTournaments(ctx, job); err != nil {
+		return err
+	}
+	if err := s.renewLease(ctx); err != nil {
+		return err
+	}
+	if err := s.service.cleanupTournaments(ctx); err != nil {
+		return err
+	}
+
+	state.TournamentsSyncedOn = today
+	return s.service.store.SaveSchedulerState(ctx, *state)
+}
+
+// syncActiveTournaments syncs the matches of every tournament played today
+// whose last sync is older than the current polling interval. It renews the
+// lease before each tournament and stops if it was lost.
+func (s *Scheduler) syncActiveTournaments(ctx context.Context) error {
+	now := s.now()
+	local := now.In(s.opts.Location)
+
+	interval := s.opts.OffHoursInterval
+	if local.Hour() >= s.opts.MatchHoursStart && local.Hour() < s.opts.MatchHoursEnd {
+		interval = s.opts.MatchHoursInterval
+	}
+
+	tournaments, err := s.service.store.ListTournaments(ctx, storage.TournamentFilter{
+		ActiveOn: local.Format("2006-01-02"),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, tournament := range tournaments {
+		if tournament.Type == storage.TournamentTypeCustom {
+			continue
+		}
+		if sinceLastRequest(tournament.LastRequest, now) < interval {
+			continue
+		}
+
+		if err := s.renewLease(ctx); err != nil {
+			return err
+		}
+
+		claimed, _, ok := s.service.claimMatchSync(ctx, tournament.Slug, false)
+		if !ok {
+			continue
+		}
+		log.Info("scheduler match sync start", log.Fields{"operation": "scheduler", "slug": tournament.Slug, "jobID": claimed.job.ID, "interval": interval.String()})
+		s.service.fetchMatches(ctx, claimed.job, claimed.lastSync, claimed.windowEnd)
+	}
+	return nil
+}
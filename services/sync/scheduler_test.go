@@ -0,0 +1,178 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func testSchedulerOptions() SchedulerOptions {
+	return SchedulerOptions{
+		Tick:                time.Minute,
+		LeaseTTL:            3 * time.Minute,
+		Location:            time.UTC,
+		TournamentSyncHour:  0,
+		TournamentSyncRetry: 30 * time.Minute,
+		MatchHoursStart:     0,
+		MatchHoursEnd:       24,
+		MatchHoursInterval:  2 * time.Minute,
+		OffHoursInterval:    30 * time.Minute,
+	}
+}
+
+func newTestScheduler(t *testing.T, store *storage.Memory, opts SchedulerOptions) (*Scheduler, *profixiotest.Server) {
+	t.Helper()
+	server := profixiotest.NewServer(t)
+	service := NewSyncService(store, nil, profixio.NewService(store, "", server.Options()...))
+	return NewScheduler(service, opts), server
+}
+
+func matchRequests(server *profixiotest.Server, tournamentID string) int {
+	count := 0
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "GET /app/api/tournaments/"+tournamentID+"/matches") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSchedulerSyncsActiveTournaments(t *testing.T) {
+	today := time.Now().UTC().Format("2006-01-02")
+	recent := time.Now().Add(-5 * time.Minute).Format(lastRequestLayout)
+
+	cases := []struct {
+		name     string
+		opts     func(opts *SchedulerOptions)
+		expected map[string]int
+	}{
+		{
+			name:     "match hours",
+			opts:     func(opts *SchedulerOptions) {},
+			expected: map[string]int{"1": 1, "2": 1, "3": 0, "4": 0},
+		},
+		{
+			name: "off hours",
+			opts: func(opts *SchedulerOptions) {
+				opts.MatchHoursEnd = 0
+			},
+			expected: map[string]int{"1": 1, "2": 0, "3": 0, "4": 0},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			store.SeedTournament(storage.Tournament{ID: 1, Slug: "never-synced", StartDate: today, EndDate: today})
+			store.SeedTournament(storage.Tournament{ID: 2, Slug: "recently-synced", StartDate: today, EndDate: today, LastRequest: recent})
+			store.SeedTournament(storage.Tournament{ID: 3, Slug: "finished", StartDate: "2020-01-01", EndDate: "2020-01-02"})
+			store.SeedTournament(storage.Tournament{ID: 4, Slug: "custom", Type: "Custom", StartDate: today, EndDate: today})
+
+			opts := testSchedulerOptions()
+			c.opts(&opts)
+			scheduler, server := newTestScheduler(t, store, opts)
+
+			if err := scheduler.syncActiveTournaments(context.Background()); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for tournamentID, expected := range c.expected {
+				if got := matchRequests(server, tournamentID); got != expected {
+					t.Errorf("tournament %s: expected %d match requests, got %d", tournamentID, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerSyncsTournamentsOncePerDay(t *testing.T) {
+	store := storage.NewMemory()
+	scheduler, _ := newTestScheduler(t, store, testSchedulerOptions())
+
+	scheduler.tick(context.Background())
+	scheduler.tick(context.Background())
+
+	jobs, err := store.ListSyncJobs(context.Background(), "")
+	if err != nil {
+		t.Fatalf("expected sync jobs, got %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Kind != storage.SyncJobTournaments || jobs[0].Status != storage.SyncJobSucceeded {
+		t.Fatalf("expected a single succeeded tournament job, got %+v", jobs)
+	}
+}
+
+func TestSchedulerRetriesFailedTournamentSync(t *testing.T) {
+	store := storage.NewMemory()
+	scheduler, server := newTestScheduler(t, store, testSchedulerOptions())
+	now := time.Now()
+	scheduler.now = func() time.Time { return now }
+
+	server.Throttle(1, http.StatusInternalServerError, "")
+	if err := scheduler.syncTournaments(context.Background()); err == nil {
+		t.Fatal("expected the tournament sync to fail")
+	}
+
+	now = now.Add(time.Minute)
+	if err := scheduler.syncTournaments(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if state, _ := store.GetSchedulerState(context.Background()); state.TournamentsSyncedOn != "" {
+		t.Fatalf("expected no retry before TournamentSyncRetry, got %+v", state)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := scheduler.syncTournaments(context.Background()); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	state, _ := store.GetSchedulerState(context.Background())
+	if state.TournamentsSyncedOn != now.UTC().Format("2006-01-02") {
+		t.Fatalf("expected the sync to be recorded for today, got %+v", state)
+	}
+
+	jobs, _ := store.ListSyncJobs(context.Background(), "")
+	if len(jobs) != 2 || jobs[0].Status != storage.SyncJobSucceeded || jobs[1].Status != storage.SyncJobFailed {
+		t.Fatalf("expected a failed and a succeeded job, got %+v", jobs)
+	}
+}
+
+func TestSchedulerStopsWhenLeaseIsLost(t *testing.T) {
+	today := time.Now().UTC().Format("2006-01-02")
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{ID: 1, Slug: "active", StartDate: today, EndDate: today})
+	scheduler, server := newTestScheduler(t, store, testSchedulerOptions())
+
+	if acquired, _ := store.AcquireLease(context.Background(), schedulerLease, "other", time.Minute); !acquired {
+		t.Fatal("expected the other instance to take the lease")
+	}
+	if err := scheduler.syncActiveTournaments(context.Background()); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("expected %v, got %v", errLeaseLost, err)
+	}
+	if got := matchRequests(server, "1"); got != 0 {
+		t.Fatalf("expected no match requests without the lease, got %d", got)
+	}
+}
+
+func TestSchedulerRequiresLease(t *testing.T) {
+	store := storage.NewMemory()
+	leader, _ := newTestScheduler(t, store, testSchedulerOptions())
+	follower, followerServer := newTestScheduler(t, store, testSchedulerOptions())
+
+	leader.tick(context.Background())
+	follower.tick(context.Background())
+
+	if requests := followerServer.Requests(); len(requests) != 0 {
+		t.Fatalf("expected follower to stay idle, got requests %v", requests)
+	}
+
+	acquired, err := store.AcquireLease(context.Background(), schedulerLease, leader.holder, time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("expected leader to renew the lease, got %v %v", acquired, err)
+	}
+}
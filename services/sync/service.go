@@ -2,172 +2,219 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	"github.com/gin-gonic/gin"
 	log "github.com/nvbf/tournament-sync/pkg/cloudlog"
 	timehelper "github.com/nvbf/tournament-sync/pkg/timeHelper"
 	profixio "github.com/nvbf/tournament-sync/repos/profixio"
-	"github.com/xorcare/pointer"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/nvbf/tournament-sync/repos/storage"
+	"github.com/samborkent/uuidv7"
 )
 
+// Profixio is the part of the Profixio integration the sync service relies on.
+type Profixio interface {
+	profixio.TournamentSource
+}
+
 type SyncService struct {
-	firestoreClient *firestore.Client
+	store           storage.Store
 	firebaseApp     *firebase.App
-	profixioService *profixio.Service
+	profixioService Profixio
 }
 
-func NewSyncService(firestoreClient *firestore.Client, firebaseApp *firebase.App, profixioService *profixio.Service) *SyncService {
+func NewSyncService(store storage.Store, firebaseApp *firebase.App, profixioService Profixio) *SyncService {
 	return &SyncService{
-		firestoreClient: firestoreClient,
+		store:           store,
 		firebaseApp:     firebaseApp,
 		profixioService: profixioService,
 	}
 }
 
-func (s *SyncService) FetchTournaments(c *gin.Context) error {
+// FetchTournaments starts a sync of the tournaments of the given sources, or
+// of every configured source when none are given, and returns its job. Once
+// the fetch succeeds the job cleans up tournaments that ended without matches.
+func (s *SyncService) FetchTournaments(c *gin.Context, sources []string) (*storage.SyncJob, error) {
 	ctx := context.Background()
-	log.Info("fetch tournaments start", log.Fields{"operation": "fetchTournaments"})
-	go s.profixioService.FetchTournaments(ctx, 1)
+	log.Info("fetch tournaments start", log.Fields{"operation": "fetchTournaments", "sources": sources})
+	if err := s.checkSources(sources); err != nil {
+		return nil, err
+	}
+	job := s.startJob(ctx, storage.SyncJob{Kind: storage.SyncJobTournaments, Sources: sources})
+	go func() {
+		if err := s.fetchTournaments(ctx, job); err != nil {
+			return
+		}
+		if err := s.cleanupTournaments(ctx); err != nil {
+			log.Error("fetch tournaments cleanup failed", err, log.Fields{"operation": "fetchTournaments", "jobID": job.ID})
+		}
+	}()
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Async function started",
-	})
-	log.Info("fetch tournaments dispatched async job", log.Fields{"operation": "fetchTournaments"})
-	return nil
+	log.Info("fetch tournaments dispatched async job", log.Fields{"operation": "fetchTournaments", "jobID": job.ID})
+	return &job, nil
+}
+
+// lastRequestLayout is the format of Tournament.LastRequest and LastSynced.
+const lastRequestLayout = "2006-01-02 15:04:05"
+
+// minRequestInterval is the shortest time allowed between two match syncs of
+// the same tournament.
+const minRequestInterval = 30 * time.Second
+
+// matchSync is a match sync that has been claimed for a tournament and is
+// ready to run.
+type matchSync struct {
+	job       storage.SyncJob
+	lastSync  string
+	windowEnd string
 }
 
 func (s *SyncService) SyncTournamentMatches(c *gin.Context, slug string, force bool) error {
-	layout := "2006-01-02 15:04:05"
 	log.Info("sync tournament matches start", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "force": force})
 
-	if s.profixioService.IsCustomTournament(c, slug) {
+	if s.isCustomTournament(c, slug) {
 		log.Info("sync tournament matches skipped custom tournament", log.Fields{"operation": "syncTournamentMatches", "slug": slug})
 		return nil
 	}
 
-	t := time.Now()
-	t_m := time.Now().Add(-10 * time.Minute)
-	now := t.Format(layout)
-	now_m := t_m.Format(layout)
-
 	ctx := context.Background()
-	lastSync := s.profixioService.GetLastSynced(ctx, slug)
-	lastReq := s.profixioService.GetLastRequest(ctx, slug)
-	if lastReq == "" || force {
-		lastReq = layout
-	}
-	lastRequestTime, err := time.Parse(layout, lastReq)
-	if err != nil {
-		log.Warning("sync tournament matches parse last request failed", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "lastRequestRaw": lastReq, "parseError": err.Error()})
-		lastRequestTime = t.Add(-24 * time.Hour)
-	}
-	newTime := t.Add(0 * time.Hour)
-	diff := newTime.Sub(lastRequestTime)
-	if diff < 0*time.Second {
-		newTime = t.Add(2 * time.Hour)
-		diff = newTime.Sub(lastRequestTime)
-	}
-
-	log.Info("sync tournament matches computed timing", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "lastSync": lastSync, "diff": diff.String()})
-
-	if diff < 30*time.Second {
+	claimed, diff, ok := s.claimMatchSync(ctx, slug, force)
+	if !ok {
 		c.JSON(http.StatusOK, gin.H{
 			"message": fmt.Sprintf("Seconds since last req: %s", diff),
 		})
 		log.Info("sync tournament matches throttled", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "diff": diff.String()})
 	} else {
-		s.profixioService.SetLastRequest(ctx, slug, now)
+		go s.fetchMatches(ctx, claimed.job, claimed.lastSync, claimed.windowEnd)
 		if force {
-			go s.profixioService.FetchMatches(ctx, 1, slug, "", now_m)
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Async function started forced sync",
+				"jobID":   claimed.job.ID,
 			})
-			log.Info("sync tournament matches dispatched forced sync", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "windowEnd": now_m})
+			log.Info("sync tournament matches dispatched forced sync", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "windowEnd": claimed.windowEnd, "jobID": claimed.job.ID})
 		} else {
-			go s.profixioService.FetchMatches(ctx, 1, slug, lastSync, now_m)
 			c.JSON(http.StatusOK, gin.H{
-				"message": fmt.Sprintf("Async function started sync from lastSync: %s", lastSync),
+				"message": fmt.Sprintf("Async function started sync from lastSync: %s", claimed.lastSync),
+				"jobID":   claimed.job.ID,
 			})
-			log.Info("sync tournament matches dispatched sync", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "from": lastSync, "to": now_m})
+			log.Info("sync tournament matches dispatched sync", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "from": claimed.lastSync, "to": claimed.windowEnd, "jobID": claimed.job.ID})
 		}
 	}
 	log.Info("sync tournament matches done", log.Fields{"operation": "syncTournamentMatches", "slug": slug})
 	return nil
 }
 
+// claimMatchSync applies the request throttle to the tournament. When a sync
+// may run it records the request and starts a job; otherwise it returns false
+// with the time since the last request.
+func (s *SyncService) claimMatchSync(ctx context.Context, slug string, force bool) (matchSync, time.Duration, bool) {
+	t := time.Now()
+	now := t.Format(lastRequestLayout)
+	windowEnd := t.Add(-10 * time.Minute).Format(lastRequestLayout)
+
+	var lastSync, lastReq string
+	tournament, err := s.store.GetTournament(ctx, slug)
+	if err != nil {
+		log.Error("sync tournament matches get tournament failed", err, log.Fields{"operation": "syncTournamentMatches", "slug": slug})
+	} else {
+		lastSync = tournament.LastSynced
+		lastReq = tournament.LastRequest
+	}
+	if force {
+		lastReq = ""
+		lastSync = ""
+	}
+
+	diff := sinceLastRequest(lastReq, t)
+	log.Info("sync tournament matches computed timing", log.Fields{"operation": "syncTournamentMatches", "slug": slug, "lastSync": lastSync, "diff": diff.String()})
+
+	if diff < minRequestInterval {
+		return matchSync{}, diff, false
+	}
+
+	if err := s.store.SetLastRequest(ctx, slug, now); err != nil {
+		log.Error("sync tournament matches set last request failed", err, log.Fields{"operation": "syncTournamentMatches", "slug": slug})
+	}
+
+	return matchSync{
+		job:       s.startJob(ctx, storage.SyncJob{Kind: storage.SyncJobMatches, Slug: slug}),
+		lastSync:  lastSync,
+		windowEnd: windowEnd,
+	}, diff, true
+}
+
+// sinceLastRequest is the time between lastReq and now. A missing or
+// unparsable lastReq counts as long ago.
+func sinceLastRequest(lastReq string, now time.Time) time.Duration {
+	if lastReq == "" {
+		lastReq = lastRequestLayout
+	}
+	lastRequestTime, err := time.Parse(lastRequestLayout, lastReq)
+	if err != nil {
+		log.Warning("sync tournament matches parse last request failed", log.Fields{"operation": "syncTournamentMatches", "lastRequestRaw": lastReq, "parseError": err.Error()})
+		lastRequestTime = now.Add(-24 * time.Hour)
+	}
+	diff := now.Sub(lastRequestTime)
+	if diff < 0*time.Second {
+		diff = now.Add(2 * time.Hour).Sub(lastRequestTime)
+	}
+	return diff
+}
+
 func (s *SyncService) SyncTournamentMatch(c *gin.Context, slug string, matchID string) error {
 	log.Info("sync tournament match start", log.Fields{"operation": "syncTournamentMatch", "slug": slug, "matchID": matchID})
-	doc, err := s.firestoreClient.Collection("TournamentSecrets").Doc(slug).Get(c)
+	secrets, err := s.store.GetTournamentSecrets(c, slug)
 	if err != nil {
 		log.Error("sync tournament match get tournament secret failed", err, log.Fields{"operation": "syncTournamentMatch", "slug": slug, "matchID": matchID})
 		return err
 	}
 
-	data := doc.Data()
-	fieldValue, ok := data["ID"]
-	if !ok {
+	if secrets.ID == 0 {
 		log.Warning("sync tournament match missing field", log.Fields{"operation": "syncTournamentMatch", "collection": "TournamentSecrets", "field": "ID", "slug": slug, "matchID": matchID})
-	}
-
-	tournamentSecretID, ok := fieldValue.(int64)
-	if !ok {
-		log.Warning("sync tournament match invalid field type", log.Fields{"operation": "syncTournamentMatch", "collection": "TournamentSecrets", "field": "ID", "expected": "int64", "slug": slug, "matchID": matchID, "value": fieldValue})
 		return nil
 	}
 
-	doc, err = s.firestoreClient.Collection("Tournaments").Doc(slug).Collection("Matches").Doc(matchID).Get(c)
+	match, err := s.store.GetMatch(c, slug, matchID)
 	if err != nil {
 		log.Error("sync tournament match get match failed", err, log.Fields{"operation": "syncTournamentMatch", "slug": slug, "matchID": matchID})
 		return err
 	}
 
-	data = doc.Data()
-	fieldValue, ok = data["ID"]
-	if !ok {
+	if match.ID == nil {
 		log.Warning("sync tournament match missing field", log.Fields{"operation": "syncTournamentMatch", "collection": "Matches", "field": "ID", "slug": slug, "matchID": matchID})
-	}
-
-	matchSecretID, ok := fieldValue.(int64)
-	if !ok {
-		log.Warning("sync tournament match invalid field type", log.Fields{"operation": "syncTournamentMatch", "collection": "Matches", "field": "ID", "expected": "int64", "slug": slug, "matchID": matchID, "value": fieldValue})
 		return nil
 	}
 
-	s.profixioService.FetchMatch(c, slug, matchID, int(tournamentSecretID), int(matchSecretID))
+	err = s.profixioService.FetchMatch(c, slug, matchID, secrets.ID, int(*match.ID))
+	if err != nil {
+		log.Error("sync tournament match fetch failed", err, log.Fields{"operation": "syncTournamentMatch", "slug": slug, "matchID": matchID})
+		return err
+	}
 	log.Info("sync tournament match dispatched fetch", log.Fields{"operation": "syncTournamentMatch", "slug": slug, "matchID": matchID})
 	return nil
 }
 
-func (s *SyncService) CleanupTournaments(c *gin.Context) error {
+func (s *SyncService) cleanupTournaments(c context.Context) error {
 	log.Info("cleanup tournaments start", log.Fields{"operation": "cleanupTournaments"})
 
-	var tournaments []*Tournament
-
-	docs, err := s.firestoreClient.Collection("Tournaments").
-		Where("EndDate", "<", timehelper.GetTodaysDateString()).
-		Documents(c).
-		GetAll()
+	var tournaments []*storage.Tournament
 
+	candidates, err := s.store.ListTournaments(c, storage.TournamentFilter{
+		EndsBefore: timehelper.GetTodaysDateString(),
+	})
 	if err != nil {
 		log.Error("cleanup tournaments list tournaments failed", err, log.Fields{"operation": "cleanupTournaments"})
 		return err
 	}
 
-	log.Info("cleanup tournaments loaded candidates", log.Fields{"operation": "cleanupTournaments", "total": len(docs)})
+	log.Info("cleanup tournaments loaded candidates", log.Fields{"operation": "cleanupTournaments", "total": len(candidates)})
 
-	for _, doc := range docs {
-		tournament, err := docToTournament(doc)
-		if err != nil {
-			return err
-		}
+	for _, tournament := range candidates {
 		if !tournament.StatsWritten {
 			tournaments = append(tournaments, tournament)
 		}
@@ -181,15 +228,15 @@ func (s *SyncService) CleanupTournaments(c *gin.Context) error {
 			continue
 		}
 
-		matchDocs, err := s.firestoreClient.Collection("Tournaments").Doc(v.Slug).Collection("Matches").Documents(c).GetAll()
+		numberOfMatches, err := s.store.CountMatches(c, v.Slug)
 		if err != nil {
 			log.Error("cleanup tournaments list matches failed", err, log.Fields{"operation": "cleanupTournaments", "slug": v.Slug})
 			return err
 		}
 
-		if len(matchDocs) == 0 {
+		if numberOfMatches == 0 {
 			log.Info("cleanup tournaments deleting empty tournament", log.Fields{"operation": "cleanupTournaments", "slug": v.Slug})
-			_, err = s.firestoreClient.Collection("Tournaments").Doc(v.Slug).Delete(c)
+			err = s.store.DeleteTournament(c, v.Slug)
 			if err != nil {
 				log.Error("cleanup tournaments delete tournament failed", err, log.Fields{"operation": "cleanupTournaments", "slug": v.Slug})
 				return err
@@ -198,41 +245,40 @@ func (s *SyncService) CleanupTournaments(c *gin.Context) error {
 		}
 	}
 
-	var tournamentSecrets []*TournamentSecrets
-
-	docs, err = s.firestoreClient.Collection("TournamentSecrets").
-		Documents(c).
-		GetAll()
+	var tournamentSecrets []*storage.TournamentSecrets
 
+	allSecrets, err := s.store.ListTournamentSecrets(c)
 	if err != nil {
 		log.Error("cleanup tournaments list tournament secrets failed", err, log.Fields{"operation": "cleanupTournaments"})
 		return err
 	}
 
-	for _, doc := range docs {
-		secrets, err := docToTournamentSecrets(doc)
+	for _, secrets := range allSecrets {
+		_, err := s.store.GetTournament(c, secrets.Slug)
 		if err != nil {
-			log.Error("cleanup tournaments parse tournament secret failed", err, log.Fields{"operation": "cleanupTournaments"})
-			return err
-		}
-		docRef, err := s.firestoreClient.Collection("Tournaments").Doc(secrets.Slug).Get(c)
-		if err != nil {
-			if status.Code(err) == codes.NotFound {
+			if errors.Is(err, storage.ErrNotFound) {
 				tournamentSecrets = append(tournamentSecrets, secrets)
-			} else {
-				log.Error("cleanup tournaments get tournament failed", err, log.Fields{"operation": "cleanupTournaments", "slug": secrets.Slug})
-				return err
+				continue
 			}
-		}
-		if !docRef.Exists() {
-			tournamentSecrets = append(tournamentSecrets, secrets)
+			log.Error("cleanup tournaments get tournament failed", err, log.Fields{"operation": "cleanupTournaments", "slug": secrets.Slug})
+			return err
 		}
 	}
 
-	log.Info("cleanup tournaments tournament secrets to delete", log.Fields{"operation": "cleanupTournaments", "deleteCount": len(tournamentSecrets), "total": len(docs)})
+	log.Info("cleanup tournaments tournament secrets to delete", log.Fields{"operation": "cleanupTournaments", "deleteCount": len(tournamentSecrets), "total": len(allSecrets)})
 
 	for _, secret := range tournamentSecrets {
-		_, err = s.firestoreClient.Collection("TournamentSecrets").Doc(secret.Slug).Delete(c)
+		// Members and access codes outlive the secrets document, and would
+		// come back with a tournament reusing the slug.
+		if err := s.store.DeleteMembers(c, secret.Slug); err != nil {
+			log.Error("cleanup tournaments delete members failed", err, log.Fields{"operation": "cleanupTournaments", "slug": secret.Slug})
+			return err
+		}
+		if err := s.store.DeleteAccessCodes(c, secret.Slug); err != nil {
+			log.Error("cleanup tournaments delete access codes failed", err, log.Fields{"operation": "cleanupTournaments", "slug": secret.Slug})
+			return err
+		}
+		err = s.store.DeleteTournamentSecrets(c, secret.Slug)
 		if err != nil {
 			log.Error("cleanup tournaments delete tournament secret failed", err, log.Fields{"operation": "cleanupTournaments", "slug": secret.Slug})
 			return err
@@ -244,78 +290,97 @@ func (s *SyncService) CleanupTournaments(c *gin.Context) error {
 	return nil
 }
 
-func (s *SyncService) UpdateCustomTournament(c *gin.Context, slug string, tournament profixio.CustomTournament) error {
-	log.Info("update custom tournament start", log.Fields{"operation": "updateCustomTournament", "slug": slug})
-	go s.profixioService.ProcessCustomTournament(c, slug, tournament)
-	log.Info("update custom tournament dispatched async job", log.Fields{"operation": "updateCustomTournament", "slug": slug})
+func (s *SyncService) GetSyncJob(c *gin.Context, id string) (*storage.SyncJob, error) {
+	return s.store.GetSyncJob(c, id)
+}
 
-	return nil
+func (s *SyncService) ListSyncJobs(c *gin.Context, slug string) ([]*storage.SyncJob, error) {
+	return s.store.ListSyncJobs(c, slug)
 }
 
-func (s *SyncService) CreateIfNoExisting(c *gin.Context, slug string) error {
-	log.Info("create if no existing start", log.Fields{"operation": "createIfNoExisting", "slug": slug})
+// startJob records job as a new running sync job. A failure to store it is
+// logged and does not stop the sync.
+func (s *SyncService) startJob(ctx context.Context, job storage.SyncJob) storage.SyncJob {
+	job.ID = uuidv7.New().String()
+	job.Status = storage.SyncJobRunning
+	job.StartedAt = time.Now()
+	if err := s.store.SaveSyncJob(ctx, job); err != nil {
+		log.Error("sync job create failed", err, log.Fields{"operation": "startJob", "jobID": job.ID, "kind": job.Kind, "slug": job.Slug})
+	}
+	return job
+}
 
-	tournament := profixio.Tournament{
-		ID:        pointer.Int(2405),
-		Name:      pointer.String("Nevza Oddanesand 2024"),
-		Slug:      pointer.String(slug),
-		StartDate: pointer.String("2024-06-18"),
-		EndDate:   pointer.String("2024-06-20"),
-		Type:      pointer.String("Custom"),
+// finishJob records the result of a sync job.
+func (s *SyncService) finishJob(ctx context.Context, job storage.SyncJob, stats profixio.SyncStats, err error) {
+	job.FinishedAt = time.Now()
+	job.PagesFetched = stats.PagesFetched
+	job.MatchesCreated = stats.MatchesCreated
+	job.MatchesUpdated = stats.MatchesUpdated
+	job.MatchesUnchanged = stats.MatchesUnchanged
+	job.Status = storage.SyncJobSucceeded
+	if err != nil {
+		job.Status = storage.SyncJobFailed
+		job.Error = err.Error()
+	}
+	if err := s.store.SaveSyncJob(ctx, job); err != nil {
+		log.Error("sync job update failed", err, log.Fields{"operation": "finishJob", "jobID": job.ID, "kind": job.Kind, "slug": job.Slug})
 	}
+}
 
-	s.profixioService.SetCustomTournament(c, tournament)
-	log.Info("create if no existing set custom tournament", log.Fields{"operation": "createIfNoExisting", "slug": slug})
+// checkSources returns profixio.ErrUnknownSource if a source is not
+// configured.
+func (s *SyncService) checkSources(sources []string) error {
+	configured := map[string]bool{}
+	for _, source := range s.profixioService.Sources() {
+		configured[source.ID()] = true
+	}
+	for _, source := range sources {
+		if !configured[source] {
+			return fmt.Errorf("%w: %s", profixio.ErrUnknownSource, source)
+		}
+	}
 	return nil
 }
 
-func docToTournament(doc *firestore.DocumentSnapshot) (*Tournament, error) {
-	var tournament Tournament
-	if err := doc.DataTo(&tournament); err != nil {
-		// If this fails, we have an inconsistency error as we control both the data written to
-		// Firestore and the shape of our `fsIntegration` struct.
-		return nil, fmt.Errorf(
-			"consistency error. Converting %+v to internal integration struct failed: %w",
-			doc,
-			err,
-		)
+// fetchTournaments runs the tournament list sync and records its outcome on
+// the job.
+func (s *SyncService) fetchTournaments(ctx context.Context, job storage.SyncJob) error {
+	stats, err := s.profixioService.FetchTournaments(ctx, 1, job.Sources...)
+	if err != nil {
+		log.Error("fetch tournaments failed", err, log.Fields{"operation": "fetchTournaments", "jobID": job.ID})
 	}
-
-	return &tournament, nil
+	s.finishJob(ctx, job, stats, err)
+	return err
 }
 
-func docToTournamentSecrets(doc *firestore.DocumentSnapshot) (*TournamentSecrets, error) {
-	var tournamentSecrets TournamentSecrets
-	if err := doc.DataTo(&tournamentSecrets); err != nil {
-		// If this fails, we have an inconsistency error as we control both the data written to
-		// Firestore and the shape of our `fsIntegration` struct.
-		return nil, fmt.Errorf(
-			"consistency error. Converting %+v to internal integration struct failed: %w",
-			doc,
-			err,
-		)
+// fetchMatches runs the match sync for the job's tournament and records its
+// outcome on the job and the tournament.
+func (s *SyncService) fetchMatches(ctx context.Context, job storage.SyncJob, lastSync string, timeNow string) {
+	slug := job.Slug
+	outcome := storage.SyncOutcome{
+		Status: storage.SyncStatusSucceeded,
+		At:     time.Now().Format("2006-01-02 15:04:05"),
 	}
 
-	return &tournamentSecrets, nil
-}
+	stats, err := s.profixioService.FetchMatches(ctx, 1, slug, lastSync, timeNow)
+	if err != nil {
+		log.Error("sync tournament matches failed", err, log.Fields{"operation": "fetchMatches", "slug": slug, "from": lastSync, "to": timeNow, "jobID": job.ID})
+		outcome.Status = storage.SyncStatusFailed
+		outcome.Error = err.Error()
+	}
+	s.finishJob(ctx, job, stats, err)
 
-type Tournament struct {
-	Name         string  `firestore:"Name"`
-	Type         string  `firestore:"Type"`
-	Slug         string  `firestore:"Slug"`
-	StartDate    string  `firestore:"StartDate"`
-	EndDate      string  `firestore:"EndDate"`
-	Matches      []Match `firestore:"Matches"`
-	StatsWritten bool    `firestore:"StatsWritten"`
+	if err := s.store.SetSyncOutcome(ctx, slug, outcome); err != nil {
+		log.Error("sync tournament matches record outcome failed", err, log.Fields{"operation": "fetchMatches", "slug": slug, "status": outcome.Status})
+	}
 }
 
-type TournamentSecrets struct {
-	ID     int    `firestore:"ID"`
-	Slug   string `firestore:"Slug"`
-	Secret string `firestore:"Secret"`
-}
+func (s *SyncService) isCustomTournament(ctx context.Context, slug string) bool {
+	tournament, err := s.store.GetTournament(ctx, slug)
+	if err != nil {
+		log.Printf("firestore read tournament failed slug=%s err=%v", slug, err)
+		return false
+	}
 
-type Match struct {
-	ScoreboardId string `firestore:"ScoreboardId"`
-	Number       string `firestore:"Number"`
+	return tournament.Type == storage.TournamentTypeCustom
 }
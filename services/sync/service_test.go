@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xorcare/pointer"
+
+	profixio "github.com/nvbf/tournament-sync/repos/profixio"
+	"github.com/nvbf/tournament-sync/repos/profixio/profixiotest"
+	"github.com/nvbf/tournament-sync/repos/storage"
+)
+
+func TestCleanupTournaments(t *testing.T) {
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{Slug: "empty-past", EndDate: "2020-01-01"})
+	store.SeedTournament(storage.Tournament{Slug: "played-past", EndDate: "2020-01-01"})
+	store.SeedTournament(storage.Tournament{Slug: "empty-future", EndDate: "2999-01-01"})
+	store.SeedTournament(storage.Tournament{Slug: "stats-written", EndDate: "2020-01-01", StatsWritten: true})
+	store.SeedMatch("played-past", storage.Match{Match: profixio.Match{Number: pointer.String("1")}})
+	store.SeedTournamentSecrets(storage.TournamentSecrets{ID: 1, Slug: "played-past"})
+	store.SeedTournamentSecrets(storage.TournamentSecrets{ID: 2, Slug: "orphaned"})
+	store.SeedTournamentSecrets(storage.TournamentSecrets{ID: 3, Slug: "empty-past"})
+	for _, slug := range []string{"played-past", "empty-past"} {
+		_ = store.PutMember(context.Background(), slug, storage.Member{UserID: "director", Role: storage.RoleDirector})
+		_ = store.SaveAccessCode(context.Background(), storage.AccessCode{Nonce: slug + "-code", Slug: slug})
+	}
+
+	service := NewSyncService(store, nil, nil)
+	if err := service.cleanupTournaments(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cases := []struct {
+		slug    string
+		deleted bool
+	}{
+		{slug: "empty-past", deleted: true},
+		{slug: "played-past"},
+		{slug: "empty-future"},
+		{slug: "stats-written"},
+	}
+	for _, c := range cases {
+		_, err := store.GetTournament(context.Background(), c.slug)
+		if deleted := errors.Is(err, storage.ErrNotFound); deleted != c.deleted {
+			t.Errorf("%s: expected deleted=%v, got err %v", c.slug, c.deleted, err)
+		}
+	}
+
+	if _, err := store.GetTournamentSecrets(context.Background(), "played-past"); err != nil {
+		t.Errorf("expected secrets for played-past to be kept, got %v", err)
+	}
+	if _, err := store.GetTournamentSecrets(context.Background(), "orphaned"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected orphaned secrets to be deleted, got %v", err)
+	}
+
+	if _, err := store.GetMember(context.Background(), "played-past", "director"); err != nil {
+		t.Errorf("expected members of played-past to be kept, got %v", err)
+	}
+	if _, err := store.GetMember(context.Background(), "empty-past", "director"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected members of empty-past to be deleted, got %v", err)
+	}
+	if _, err := store.UseAccessCode(context.Background(), "empty-past-code", "someone", time.Now()); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected access codes of empty-past to be deleted, got %v", err)
+	}
+}
+
+func TestFetchMatchesRecordsOutcome(t *testing.T) {
+	cases := []struct {
+		name          string
+		page          []byte
+		status        string
+		expectedError bool
+	}{
+		{name: "succeeded", page: []byte(`{"data":[{"id":1,"number":"1"}],"meta":{"last_page":1}}`), status: storage.SyncStatusSucceeded},
+		{name: "failed", page: []byte("<html>Bad Gateway</html>"), status: storage.SyncStatusFailed, expectedError: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := profixiotest.NewServer(t)
+			server.AddRawMatchesPage(2405, 1, c.page)
+			store := storage.NewMemory()
+			store.SeedTournament(storage.Tournament{ID: 2405, Slug: "beach-cup"})
+			service := NewSyncService(store, nil, profixio.NewService(store, "", server.Options()...))
+
+			job := service.startJob(context.Background(), storage.SyncJob{Kind: storage.SyncJobMatches, Slug: "beach-cup"})
+			service.fetchMatches(context.Background(), job, "", "2024-06-02 10:00:00")
+
+			tournament, err := store.GetTournament(context.Background(), "beach-cup")
+			if err != nil {
+				t.Fatalf("expected tournament, got %v", err)
+			}
+			if tournament.LastSyncStatus != c.status || tournament.LastSyncAt == "" {
+				t.Fatalf("expected status %s with a timestamp, got %+v", c.status, tournament)
+			}
+			if (tournament.LastSyncError != "") != c.expectedError {
+				t.Fatalf("expected error recorded=%v, got %q", c.expectedError, tournament.LastSyncError)
+			}
+
+			stored, err := store.GetSyncJob(context.Background(), job.ID)
+			if err != nil {
+				t.Fatalf("expected sync job, got %v", err)
+			}
+			if stored.Status != c.status || stored.FinishedAt.IsZero() || (stored.Error != "") != c.expectedError {
+				t.Fatalf("expected finished job with status %s, got %+v", c.status, stored)
+			}
+		})
+	}
+}
+
+func TestFetchMatchesJobCounts(t *testing.T) {
+	server := profixiotest.NewServer(t)
+	server.AddRawMatchesPage(2405, 1, []byte(`{"data":[{"id":1,"number":"1"},{"id":2,"number":"2"}],"meta":{"last_page":2}}`))
+	server.AddRawMatchesPage(2405, 2, []byte(`{"data":[{"id":3,"number":"3"}],"meta":{"last_page":2}}`))
+	store := storage.NewMemory()
+	store.SeedTournament(storage.Tournament{ID: 2405, Slug: "beach-cup"})
+	store.SeedMatch("beach-cup", storage.Match{Match: profixio.Match{Number: pointer.String("2")}})
+	service := NewSyncService(store, nil, profixio.NewService(store, "", server.Options()...))
+
+	job := service.startJob(context.Background(), storage.SyncJob{Kind: storage.SyncJobMatches, Slug: "beach-cup"})
+	service.fetchMatches(context.Background(), job, "", "2024-06-02 10:00:00")
+
+	jobs, err := store.ListSyncJobs(context.Background(), "beach-cup")
+	if err != nil {
+		t.Fatalf("expected sync jobs, got %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 sync job, got %d", len(jobs))
+	}
+	got := jobs[0]
+	if got.PagesFetched != 2 || got.MatchesCreated != 2 || got.MatchesUpdated != 1 || got.Status != storage.SyncJobSucceeded {
+		t.Fatalf("expected 2 pages, 2 created and 1 updated, got %+v", got)
+	}
+}